@@ -1,12 +1,24 @@
 package main
 
-import "github.com/fernandoocampo/users-micro/internal/application"
+import (
+	"os"
+
+	"github.com/fernandoocampo/users-micro/internal/application"
+)
 
 func main() {
 	newInstance := application.NewInstance()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		defer newInstance.Stop()
+		err := newInstance.Migrate(os.Args[2:])
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+	defer newInstance.Stop()
 	err := newInstance.Run()
 	if err != nil {
 		panic(err)
 	}
-	defer newInstance.Stop()
 }
@@ -1,6 +1,6 @@
 module github.com/fernandoocampo/users-micro
 
-go 1.13
+go 1.16
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
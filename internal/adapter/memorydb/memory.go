@@ -1,23 +1,95 @@
 package memorydb
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 )
 
-const recordsLimit = 100
+// DefaultCapacity number of records a dry run repository keeps if no other
+// capacity is given.
+const DefaultCapacity = 100
+
+// EvictionPolicy what a dry run repository does when a record is saved and it
+// is full.
+type EvictionPolicy string
+
+// Eviction policies.
+const (
+	// RejectWhenFull rejects the new record.
+	RejectWhenFull EvictionPolicy = "reject"
+	// EvictLeastRecentlyUsed removes the record that was read or written the
+	// longest time ago to make room for the new one.
+	EvictLeastRecentlyUsed EvictionPolicy = "lru"
+)
+
+// ErrCapacityExceeded the repository is full and its policy rejects new records.
+var ErrCapacityExceeded = errors.New("cannot save given entity, the limit of allowed records was exceeded")
 
 // DryRunRepository is the repository handler for s in a relational db.
+// It is safe for concurrent use.
 type DryRunRepository struct {
-	storage map[string]interface{}
+	mu       sync.RWMutex
+	capacity int
+	eviction EvictionPolicy
+	// storage element of the recency list of every entity id.
+	storage map[string]*list.Element
+	// recency entries from the most recently used to the least.
+	recency *list.List
+	// onEvict is called with the id of every evicted entity, while the
+	// repository is locked.
+	onEvict func(entityID string)
+}
+
+// entry entity kept in the recency list.
+type entry struct {
+	entityID string
+	entity   interface{}
+}
+
+// Option sets an optional setting of the dry run repository.
+type Option func(*DryRunRepository)
+
+// WithCapacity sets the number of records the repository keeps, zero or less
+// means there is no limit.
+func WithCapacity(capacity int) Option {
+	return func(u *DryRunRepository) {
+		u.capacity = capacity
+	}
+}
+
+// WithEviction sets what the repository does when it is full, unknown policies
+// are ignored.
+func WithEviction(policy EvictionPolicy) Option {
+	return func(u *DryRunRepository) {
+		if policy != RejectWhenFull && policy != EvictLeastRecentlyUsed {
+			log.Println("level", "WARN", "msg", "unknown eviction policy, records are rejected when the repository is full", "policy", policy)
+			return
+		}
+		u.eviction = policy
+	}
+}
+
+// withEvictionHandler sets the function called with the id of every evicted entity.
+func withEvictionHandler(onEvict func(entityID string)) Option {
+	return func(u *DryRunRepository) {
+		u.onEvict = onEvict
+	}
 }
 
 // NewDryRunRepository creates a new  repository that will use a rdb.
-func NewDryRunRepository() *DryRunRepository {
+func NewDryRunRepository(options ...Option) *DryRunRepository {
 	newRepo := DryRunRepository{
-		storage: make(map[string]interface{}),
+		capacity: DefaultCapacity,
+		eviction: RejectWhenFull,
+		storage:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+	for _, option := range options {
+		option(&newRepo)
 	}
 	return &newRepo
 }
@@ -25,15 +97,25 @@ func NewDryRunRepository() *DryRunRepository {
 // Save store the given entity
 func (u *DryRunRepository) Save(ctx context.Context, entityID string, entity interface{}) error {
 	log.Println("level", "DEBUG", "msg", "storing entity", "method", "memory.DryRunRepository.Save", "entity", entity)
-	if len(u.storage) >= recordsLimit {
-		log.Println("level", "ERROR", "msg", "cannot save given entity, because the limit of allowed records was exceeded", "limit", recordsLimit)
-		return errors.New("cannot save given entity, contact administrator")
-	}
 	if entityID == "" {
 		log.Println("level", "ERROR", "msg", "cannot save given entity, because it doesn't contain a valid id", "entity", entity)
 		return fmt.Errorf("cannot save given entity %v, because it doesn't contain a valid id", entity)
 	}
-	u.storage[entityID] = entity
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if element, ok := u.storage[entityID]; ok {
+		element.Value = entry{entityID: entityID, entity: entity}
+		u.recency.MoveToFront(element)
+		return nil
+	}
+	if u.full() {
+		if u.eviction != EvictLeastRecentlyUsed {
+			log.Println("level", "ERROR", "msg", "cannot save given entity, because the limit of allowed records was exceeded", "limit", u.capacity)
+			return ErrCapacityExceeded
+		}
+		u.evict()
+	}
+	u.storage[entityID] = u.recency.PushFront(entry{entityID: entityID, entity: entity})
 	return nil
 }
 
@@ -44,36 +126,96 @@ func (u *DryRunRepository) Update(ctx context.Context, entityID string, entity i
 		log.Println("level", "ERROR", "msg", "cannot update given entity, because it doesn't contain a valid id", "entity", entity)
 		return fmt.Errorf("cannot update given entity %v, because it doesn't contain a valid id", entity)
 	}
-	entity, ok := u.storage[entityID]
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	element, ok := u.storage[entityID]
 	if !ok {
 		return errors.New("given entity doesn't exist")
 	}
-	u.storage[entityID] = entity
+	element.Value = entry{entityID: entityID, entity: entity}
+	u.recency.MoveToFront(element)
+	return nil
+}
+
+// Delete removes the entity with the given id from the memory storage.
+func (u *DryRunRepository) Delete(ctx context.Context, entityID string) error {
+	log.Println("level", "DEBUG", "msg", "deleting entity", "method", "memory.DryRunRepository.Delete", "entity id", entityID)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	element, ok := u.storage[entityID]
+	if !ok {
+		return errors.New("given entity doesn't exist")
+	}
+	u.recency.Remove(element)
+	delete(u.storage, entityID)
 	return nil
 }
 
 // FindByID finds a entity with the given id in the memory storate of this dry run database.
 func (u *DryRunRepository) FindByID(ctx context.Context, entityID string) (interface{}, error) {
 	log.Println("level", "DEBUG", "msg", "reading entity", "method", "memory.DryRunRepository.FindByID", "entity id", entityID)
-	entity, ok := u.storage[entityID]
+	// reads change the recency of the entity when it is used to evict.
+	if u.eviction == EvictLeastRecentlyUsed {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+	} else {
+		u.mu.RLock()
+		defer u.mu.RUnlock()
+	}
+	element, ok := u.storage[entityID]
 	if !ok {
 		return nil, nil
 	}
+	if u.eviction == EvictLeastRecentlyUsed {
+		u.recency.MoveToFront(element)
+	}
+	entity := element.Value.(entry).entity
 	log.Println("level", "DEBUG", "msg", "entity found", "method", "memory.DryRunRepository.FindByID", "entity id", entityID, "entity", entity)
 	return entity, nil
 }
 
-// FindAll return all entities
+// FindAll return all entities, from the most recently used to the least. It
+// doesn't change the recency of any of them.
 func (u *DryRunRepository) FindAll(ctx context.Context) ([]interface{}, error) {
 	log.Println("level", "DEBUG", "msg", "reading all entities", "method", "memory.DryRunRepository.FindAll")
-	result := make([]interface{}, 0)
-	for _, v := range u.storage {
-		result = append(result, v)
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	result := make([]interface{}, 0, len(u.storage))
+	for element := u.recency.Front(); element != nil; element = element.Next() {
+		result = append(result, element.Value.(entry).entity)
 	}
 	return result, nil
 }
 
 // Count counts records in the memory repo
 func (u *DryRunRepository) Count() int {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
 	return len(u.storage)
 }
+
+// Fits returns true if the given number of new records can be saved.
+func (u *DryRunRepository) Fits(records int) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.capacity <= 0 || u.eviction == EvictLeastRecentlyUsed || len(u.storage)+records <= u.capacity
+}
+
+// full returns true if there is no room for another record.
+func (u *DryRunRepository) full() bool {
+	return u.capacity > 0 && len(u.storage) >= u.capacity
+}
+
+// evict removes the least recently used entity.
+func (u *DryRunRepository) evict() {
+	element := u.recency.Back()
+	if element == nil {
+		return
+	}
+	evicted := u.recency.Remove(element).(entry)
+	delete(u.storage, evicted.entityID)
+	log.Println("level", "DEBUG", "msg", "entity evicted", "method", "memory.DryRunRepository.Save", "entity id", evicted.entityID)
+	if u.onEvict != nil {
+		u.onEvict(evicted.entityID)
+	}
+}
@@ -3,10 +3,13 @@ package memorydb_test
 import (
 	"context"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/fernandoocampo/users-micro/internal/adapter/memorydb"
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +20,7 @@ func TestCreateUserInMemoryDB(t *testing.T) {
 		FirstName: "users-micro",
 		LastName:  "Wayne",
 		City:      "Medellin",
-		Skills:    []string{"work"},
+		Skills:    repository.Skills{{Name: "work"}},
 	}
 	newDB := memorydb.NewDryRunRepository()
 	ctx := context.TODO()
@@ -37,7 +40,7 @@ func TestCreateUserWithRepository(t *testing.T) {
 		FirstName: "users-micro",
 		LastName:  "Wayne",
 		City:      "Medellin",
-		Skills:    []string{"work"},
+		Skills:    repository.Skills{{Name: "work"}},
 	}
 	newDB := memorydb.NewUserDryRunRepository()
 	ctx := context.TODO()
@@ -47,9 +50,40 @@ func TestCreateUserWithRepository(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NoError(t, readErr)
+	newUser.Version = 1
 	assert.Equal(t, &newUser, savedUser)
 }
 
+func TestSaveAllUsersWithRepository(t *testing.T) {
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	err := newDB.Save(ctx, repository.User{ID: "1", FirstName: "Alicia", LastName: "Mendez"})
+	assert.NoError(t, err)
+
+	userErrs, err := newDB.SaveAll(ctx, []repository.User{
+		{ID: "2", FirstName: "Oliver", LastName: "Vasquez"},
+		{ID: "1", FirstName: "Cecilia", LastName: "Mendez"},
+		{ID: "3", FirstName: "Cecilia", LastName: "Mendez"},
+		{ID: "3", FirstName: "Mario", LastName: "Mendez"},
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, userErrs, 4) {
+		assert.NoError(t, userErrs[0])
+		assert.Equal(t, users.ErrorKindConflict, users.KindOf(userErrs[1]))
+		assert.NoError(t, userErrs[2])
+		assert.Equal(t, users.ErrorKindConflict, users.KindOf(userErrs[3]))
+	}
+	existingUser, readErr := newDB.FindByID(ctx, "1")
+	assert.NoError(t, readErr)
+	assert.Equal(t, "Alicia", existingUser.FirstName)
+	savedUser, readErr := newDB.FindByID(ctx, "3")
+	assert.NoError(t, readErr)
+	assert.Equal(t, "Cecilia", savedUser.FirstName)
+	assert.Equal(t, 1, savedUser.Version)
+	assert.Equal(t, []string{"1", "2", "3"}, searchUserIDs(t, newDB, repository.UserFilter{}))
+}
+
 func TestCreateUserInMemoryDBWithLimit(t *testing.T) {
 	newDB := memorydb.NewDryRunRepository()
 	ctx := context.TODO()
@@ -57,7 +91,7 @@ func TestCreateUserInMemoryDBWithLimit(t *testing.T) {
 		FirstName: "users-micro",
 		LastName:  "Wayne",
 		City:      "Medellin",
-		Skills:    []string{"work"},
+		Skills:    repository.Skills{{Name: "work"}},
 	}
 
 	for i := 0; i < 100; i++ {
@@ -73,3 +107,651 @@ func TestCreateUserInMemoryDBWithLimit(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, 100, newDB.Count())
 }
+
+func TestEvictLeastRecentlyUsedUser(t *testing.T) {
+	newDB := memorydb.NewUserDryRunRepository(
+		memorydb.WithCapacity(2),
+		memorydb.WithEviction(memorydb.EvictLeastRecentlyUsed),
+	)
+	ctx := context.TODO()
+
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "1", FirstName: "Fernando"}))
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "2", FirstName: "Alicia"}))
+	_, err := newDB.FindByID(ctx, "1")
+	assert.NoError(t, err)
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "3", FirstName: "Oliver"}))
+
+	_, err = newDB.FindByID(ctx, "2")
+	assert.Equal(t, users.ErrUserNotFound, err)
+	history, err := newDB.FindHistory(ctx, repository.HistoryFilter{UserID: "2", Page: 1, RowsPerPage: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, history.Entries)
+	assert.Equal(t, []string{"1", "3"}, searchUserIDs(t, newDB, repository.UserFilter{}))
+}
+
+func TestRejectUsersWhenRepositoryIsFull(t *testing.T) {
+	newDB := memorydb.NewUserDryRunRepository(memorydb.WithCapacity(2))
+	ctx := context.TODO()
+
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "1", FirstName: "Fernando"}))
+	_, err := newDB.SaveAll(ctx, []repository.User{{ID: "2"}, {ID: "3"}})
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(err))
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "2", FirstName: "Alicia"}))
+	err = newDB.Save(ctx, repository.User{ID: "3", FirstName: "Oliver"})
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(err))
+
+	assert.Equal(t, []string{"1", "2"}, searchUserIDs(t, newDB, repository.UserFilter{}))
+}
+
+func TestUseRepositoryConcurrently(t *testing.T) {
+	newDB := memorydb.NewUserDryRunRepository(
+		memorydb.WithCapacity(50),
+		memorydb.WithEviction(memorydb.EvictLeastRecentlyUsed),
+	)
+	ctx := context.TODO()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				userID := strconv.Itoa(worker*10 + j)
+				assert.NoError(t, newDB.Save(ctx, repository.User{ID: userID, FirstName: "Fernando", City: "Cali"}))
+				user, err := newDB.FindByID(ctx, userID)
+				if err == nil {
+					user.City = "Bogota"
+					_ = newDB.Update(ctx, *user)
+				}
+				_, err = newDB.SearchWithFilters(ctx, repository.UserFilter{City: "Cali", Page: 1, RowsPerPage: 10})
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	found, err := newDB.SearchWithFilters(ctx, repository.UserFilter{Page: 1, RowsPerPage: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 50, found.Total)
+}
+
+func TestDeleteAndRestoreUserWithRepository(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+
+	err = newDB.Delete(ctx, repository.UserStateChange{ID: userID})
+	assert.NoError(t, err)
+	deletedUser, readErr := newDB.FindByID(ctx, userID)
+	assert.Equal(t, users.ErrUserNotFound, readErr)
+	assert.Nil(t, deletedUser)
+	assert.Error(t, newDB.Delete(ctx, repository.UserStateChange{ID: userID}))
+	assert.Error(t, newDB.Update(ctx, newUser))
+
+	err = newDB.Restore(ctx, repository.UserStateChange{ID: userID})
+	assert.NoError(t, err)
+	restoredUser, readErr := newDB.FindByID(ctx, userID)
+	assert.NoError(t, readErr)
+	newUser.Version = 1
+	assert.Equal(t, &newUser, restoredUser)
+}
+
+func TestRollbackTransactionWithRepository(t *testing.T) {
+	existingUser := repository.User{ID: "1", FirstName: "Bruce", City: "Medellin"}
+	newUser := repository.User{ID: "2", FirstName: "Selina", City: "Cali"}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	err := newDB.Save(ctx, existingUser)
+	assert.NoError(t, err)
+
+	// WHEN
+	txErr := newDB.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := newDB.Save(ctx, newUser)
+		if err != nil {
+			return err
+		}
+		err = newDB.Delete(ctx, repository.UserStateChange{ID: existingUser.ID})
+		if err != nil {
+			return err
+		}
+		return newDB.Save(ctx, newUser)
+	})
+
+	assert.Equal(t, users.ErrorKindConflict, users.KindOf(txErr))
+	notSavedUser, readErr := newDB.FindByID(ctx, newUser.ID)
+	assert.Equal(t, users.ErrUserNotFound, readErr)
+	assert.Nil(t, notSavedUser)
+	notDeletedUser, readErr := newDB.FindByID(ctx, existingUser.ID)
+	assert.NoError(t, readErr)
+	assert.Equal(t, "Bruce", notDeletedUser.FirstName)
+	history, historyErr := newDB.FindHistory(ctx, repository.HistoryFilter{UserID: newUser.ID, Page: 1, RowsPerPage: 10})
+	assert.NoError(t, historyErr)
+	assert.Equal(t, 0, history.Total)
+}
+
+func TestCommitTransactionWithRepository(t *testing.T) {
+	newUser := repository.User{ID: "2", FirstName: "Selina", City: "Cali"}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	// WHEN
+	txErr := newDB.WithinTransaction(ctx, func(ctx context.Context) error {
+		return newDB.Save(ctx, newUser)
+	})
+
+	assert.NoError(t, txErr)
+	savedUser, readErr := newDB.FindByID(ctx, newUser.ID)
+	assert.NoError(t, readErr)
+	assert.Equal(t, "Selina", savedUser.FirstName)
+}
+
+func TestDeleteRestoreAndPurgeUserAreAudited(t *testing.T) {
+	deletedAt := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	restoredAt := deletedAt.Add(time.Hour)
+	purgedAt := restoredAt.Add(time.Hour)
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "1", FirstName: "Fernando", CreatedBy: "recruiter-1"}))
+
+	assert.NoError(t, newDB.Delete(ctx, repository.UserStateChange{ID: "1", UpdatedAt: deletedAt, UpdatedBy: "recruiter-2"}))
+	assert.NoError(t, newDB.Restore(ctx, repository.UserStateChange{ID: "1", UpdatedAt: restoredAt, UpdatedBy: "recruiter-3"}))
+	restoredUser, readErr := newDB.FindByID(ctx, "1")
+	assert.NoError(t, newDB.Purge(ctx, repository.UserStateChange{ID: "1", UpdatedAt: purgedAt, UpdatedBy: "recruiter-4"}))
+
+	assert.NoError(t, readErr)
+	assert.Equal(t, restoredAt, restoredUser.UpdatedAt)
+	assert.Equal(t, "recruiter-3", restoredUser.UpdatedBy)
+	history, err := newDB.FindHistory(ctx, repository.HistoryFilter{UserID: "1", Page: 1, RowsPerPage: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, history.Entries, 4) {
+		assert.Equal(t, repository.AuditEntry{UserID: "1", Operation: repository.AuditPurge, Actor: "recruiter-4", ChangedAt: purgedAt, Changes: repository.FieldChanges{}}, history.Entries[0])
+		assert.Equal(t, repository.AuditEntry{UserID: "1", Operation: repository.AuditRestore, Actor: "recruiter-3", ChangedAt: restoredAt, Changes: repository.FieldChanges{}}, history.Entries[1])
+		assert.Equal(t, repository.AuditEntry{UserID: "1", Operation: repository.AuditDelete, Actor: "recruiter-2", ChangedAt: deletedAt, Changes: repository.FieldChanges{}}, history.Entries[2])
+		// the values of the user are not kept after the purge.
+		assert.Equal(t, repository.AuditCreate, history.Entries[3].Operation)
+		assert.Empty(t, history.Entries[3].Changes)
+	}
+}
+
+func TestPurgeUserWithRepository(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+	err = newDB.Delete(ctx, repository.UserStateChange{ID: userID})
+	assert.NoError(t, err)
+
+	err = newDB.Purge(ctx, repository.UserStateChange{ID: userID})
+
+	assert.NoError(t, err)
+	assert.Error(t, newDB.Restore(ctx, repository.UserStateChange{ID: userID}))
+}
+
+func TestUpdateUserWithRepository(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	updatedUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "work"}, {Name: "paint"}},
+		Version:   1,
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+
+	err = newDB.Update(ctx, updatedUser)
+	savedUser, readErr := newDB.FindByID(ctx, userID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, readErr)
+	updatedUser.Version = 2
+	assert.Equal(t, &updatedUser, savedUser)
+}
+
+func TestUpdateUserKeepsCreationStamp(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	createdAt := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		CreatedAt: createdAt,
+		CreatedBy: "recruiter-1",
+		UpdatedAt: createdAt,
+		UpdatedBy: "recruiter-1",
+	}
+	updatedUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Cali",
+		Version:   1,
+		UpdatedAt: updatedAt,
+		UpdatedBy: "recruiter-2",
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+	err = newDB.Update(ctx, updatedUser)
+	assert.NoError(t, err)
+	savedUser, err := newDB.FindByID(ctx, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, createdAt, savedUser.CreatedAt)
+	assert.Equal(t, "recruiter-1", savedUser.CreatedBy)
+	assert.Equal(t, updatedAt, savedUser.UpdatedAt)
+	assert.Equal(t, "recruiter-2", savedUser.UpdatedBy)
+}
+
+func TestUpdateUserWithOldVersion(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+	newUser.Version = 1
+	err = newDB.Update(ctx, newUser)
+	assert.NoError(t, err)
+
+	newUser.City = "Cali"
+	err = newDB.Update(ctx, newUser)
+
+	assert.Equal(t, users.ErrVersionConflict, err)
+}
+
+func TestPatchUserWithRepository(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	newCity := "Cali"
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	expectedUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "work"}},
+		Version:   2,
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+
+	err = newDB.Patch(ctx, repository.UserPatch{ID: userID, Version: 1, City: &newCity})
+	savedUser, readErr := newDB.FindByID(ctx, userID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, readErr)
+	assert.Equal(t, &expectedUser, savedUser)
+}
+
+func TestCreateExistingUserWithRepository(t *testing.T) {
+	newUser := repository.User{
+		ID:        "sfsfsf-sdfsf1234",
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+
+	err = newDB.Save(ctx, newUser)
+
+	assert.Error(t, err)
+	assert.Equal(t, users.ErrorKindConflict, users.KindOf(err))
+}
+
+func TestUserHistoryWithRepository(t *testing.T) {
+	userID := "sfsfsf-sdfsf1234"
+	changedAt := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	newUser := repository.User{
+		ID:        userID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		CreatedAt: changedAt,
+		CreatedBy: "recruiter-1",
+	}
+	updatedUser := newUser
+	updatedUser.Version = 1
+	updatedUser.Skills = repository.Skills{{Name: "work"}}
+	updatedUser.UpdatedAt = changedAt.Add(time.Hour)
+	updatedUser.UpdatedBy = "recruiter-2"
+	newCity := "Cali"
+	changes := repository.UserPatch{
+		ID:        userID,
+		Version:   2,
+		City:      &newCity,
+		UpdatedAt: changedAt.Add(2 * time.Hour),
+		UpdatedBy: "recruiter-3",
+	}
+	expectedResult := repository.FindHistoryResult{
+		Entries: []repository.AuditEntry{
+			{
+				UserID:    userID,
+				Operation: repository.AuditPatch,
+				Actor:     "recruiter-3",
+				ChangedAt: changedAt.Add(2 * time.Hour),
+				Changes: repository.FieldChanges{
+					{Field: "city", Before: "Medellin", After: "Cali"},
+				},
+			},
+			{
+				UserID:    userID,
+				Operation: repository.AuditUpdate,
+				Actor:     "recruiter-2",
+				ChangedAt: changedAt.Add(time.Hour),
+				Changes: repository.FieldChanges{
+					{Field: "skills", Before: repository.Skills(nil), After: repository.Skills{{Name: "work"}}},
+				},
+			},
+		},
+		Total:       3,
+		Page:        1,
+		RowsPerPage: 2,
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, newUser)
+	assert.NoError(t, err)
+	err = newDB.Update(ctx, updatedUser)
+	assert.NoError(t, err)
+	err = newDB.Patch(ctx, changes)
+	assert.NoError(t, err)
+	got, err := newDB.FindHistory(ctx, repository.HistoryFilter{UserID: userID, Page: 1, RowsPerPage: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedResult, got)
+}
+
+func TestSkillCatalogWithRepository(t *testing.T) {
+	expectedCatalog := []repository.Skill{
+		{Name: "Go", Aliases: repository.Aliases{"golang"}},
+		{Name: "PostgreSQL", Aliases: repository.Aliases{"postgres"}},
+	}
+	newDB := memorydb.NewSkillDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.Save(ctx, repository.Skill{Name: "PostgreSQL", Aliases: repository.Aliases{"postgres"}})
+	assert.NoError(t, err)
+	err = newDB.Save(ctx, repository.Skill{Name: "Go", Aliases: repository.Aliases{"golang"}})
+	assert.NoError(t, err)
+	err = newDB.Save(ctx, repository.Skill{Name: "Go"})
+	assert.Equal(t, users.ErrorKindConflict, users.KindOf(err))
+
+	catalog, err := newDB.FindAll(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedCatalog, catalog)
+}
+
+func TestSearchUsersBySkillModesWithRepository(t *testing.T) {
+	storedUsers := []repository.User{
+		{ID: "1", FirstName: "Alicia", LastName: "Mendez", City: "Cali", Skills: repository.Skills{{Name: "Go"}, {Name: "Java"}}},
+		{ID: "2", FirstName: "Oliver", LastName: "Vasquez", City: "Cali", Skills: repository.Skills{{Name: "Rust"}}},
+		{ID: "3", FirstName: "Cecilia", LastName: "Quiroga", City: "Cali", Skills: repository.Skills{{Name: "Java"}, {Name: "Kotlin"}}},
+		{ID: "4", FirstName: "Armando", LastName: "Lopez", City: "Bogota", Skills: repository.Skills{{Name: "Go", Level: "expert", Years: 5}}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, v := range storedUsers {
+		err := newDB.Save(ctx, v)
+		assert.NoError(t, err)
+	}
+	bogotaGoExperts := repository.UserFilter{
+		City:              "Bogota",
+		SkillRequirements: []repository.SkillRequirement{{Name: "Go", Levels: []string{"advanced", "expert"}, MinYears: 3}},
+	}
+
+	anyIDs := searchUserIDs(t, newDB, repository.UserFilter{SkillsAny: repository.Skills{{Name: "Go"}, {Name: "Rust"}}})
+	allIDs := searchUserIDs(t, newDB, repository.UserFilter{Skills: repository.Skills{{Name: "Go"}, {Name: "Java"}}})
+	noneIDs := searchUserIDs(t, newDB, repository.UserFilter{Skills: repository.Skills{{Name: "Java"}}, SkillsNone: repository.Skills{{Name: "Kotlin"}}})
+	requirementIDs := searchUserIDs(t, newDB, bogotaGoExperts)
+
+	assert.Equal(t, []string{"1", "2", "4"}, anyIDs)
+	assert.Equal(t, []string{"1"}, allIDs)
+	assert.Equal(t, []string{"1"}, noneIDs)
+	assert.Equal(t, []string{"4"}, requirementIDs)
+}
+
+func searchUserIDs(t *testing.T, db *memorydb.UserMemoryRepository, filter repository.UserFilter) []string {
+	t.Helper()
+	filter.Page = 1
+	filter.RowsPerPage = 10
+	got, err := db.SearchWithFilters(context.TODO(), filter)
+	assert.NoError(t, err)
+	ids := userIDs(got.Users)
+	assert.Equal(t, len(ids), got.Total)
+	return ids
+}
+
+func TestSearchUsersSortedWithRepository(t *testing.T) {
+	changedAt := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	storedUsers := []repository.User{
+		{ID: "1", FirstName: "Alicia", LastName: "Mendez", UpdatedAt: changedAt},
+		{ID: "2", FirstName: "Oliver", LastName: "Vasquez", UpdatedAt: changedAt},
+		{ID: "3", FirstName: "Cecilia", LastName: "Mendez", UpdatedAt: changedAt.Add(time.Hour)},
+		{ID: "4", FirstName: "Armando", LastName: "Mendez", UpdatedAt: changedAt},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, v := range storedUsers {
+		err := newDB.Save(ctx, v)
+		assert.NoError(t, err)
+	}
+	filter := repository.UserFilter{
+		Sort: []repository.SortField{
+			{Field: repository.SortByLastName},
+			{Field: repository.SortByUpdatedAt, Descending: true},
+		},
+	}
+
+	ids := searchUserIDs(t, newDB, filter)
+
+	assert.Equal(t, []string{"3", "1", "4", "2"}, ids)
+}
+
+func TestSearchUsersWithCursorWithRepository(t *testing.T) {
+	storedUsers := []repository.User{
+		{ID: "1", FirstName: "Alicia", LastName: "Mendez"},
+		{ID: "2", FirstName: "Oliver", LastName: "Vasquez"},
+		{ID: "3", FirstName: "Cecilia", LastName: "Mendez"},
+		{ID: "4", FirstName: "Armando", LastName: "Mendez"},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, v := range storedUsers {
+		err := newDB.Save(ctx, v)
+		assert.NoError(t, err)
+	}
+	filter := repository.UserFilter{
+		Sort:        []repository.SortField{{Field: repository.SortByLastName}},
+		Cursor:      &repository.Cursor{Values: []string{"Mendez", "1"}},
+		RowsPerPage: 2,
+	}
+
+	nextPage, err := newDB.SearchWithFilters(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3", "4"}, userIDs(nextPage.Users))
+	assert.True(t, nextPage.HasMore)
+	assert.Equal(t, 4, nextPage.Total)
+
+	filter.Cursor = &repository.Cursor{Values: []string{"Vasquez", "2"}, Backward: true}
+
+	prevPage, err := newDB.SearchWithFilters(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3", "4"}, userIDs(prevPage.Users))
+	assert.True(t, prevPage.HasMore)
+
+	filter.Cursor = &repository.Cursor{Values: []string{"Mendez", "3"}, Backward: true}
+
+	firstPage, err := newDB.SearchWithFilters(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, userIDs(firstPage.Users))
+	assert.False(t, firstPage.HasMore)
+}
+
+func TestSearchUsersByTextWithRepository(t *testing.T) {
+	storedUsers := []repository.User{
+		{ID: "1", FirstName: "Fernando", LastName: "Ocampo", Skills: repository.Skills{{Name: "Go"}}},
+		{ID: "2", FirstName: "Fernanda", LastName: "Ocampo", Skills: repository.Skills{{Name: "Rust"}}},
+		{ID: "3", FirstName: "Alicia", LastName: "Méndez", Skills: repository.Skills{{Name: "Go"}}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, v := range storedUsers {
+		err := newDB.Save(ctx, v)
+		assert.NoError(t, err)
+	}
+	byRelevance := []repository.SortField{{Field: repository.SortByRelevance, Descending: true}}
+
+	assert.Equal(t, []string{"1", "2"}, searchUserIDs(t, newDB, repository.UserFilter{Query: "fernando ocampo", Sort: byRelevance}))
+	assert.Equal(t, []string{"1", "2"}, searchUserIDs(t, newDB, repository.UserFilter{Query: "Fernado", Sort: byRelevance}))
+	assert.Equal(t, []string{"3"}, searchUserIDs(t, newDB, repository.UserFilter{Query: "mendez", Sort: byRelevance}))
+	assert.Equal(t, []string{"1", "3"}, searchUserIDs(t, newDB, repository.UserFilter{Query: "go"}))
+	assert.Empty(t, searchUserIDs(t, newDB, repository.UserFilter{Query: "fernando go rust"}))
+}
+
+func TestSearchUsersWithFacetsWithRepository(t *testing.T) {
+	storedUsers := []repository.User{
+		{ID: "1", City: "Bogotá", Skills: repository.Skills{{Name: "Go"}, {Name: "Python"}}},
+		{ID: "2", City: "Medellín", Skills: repository.Skills{{Name: "Go"}}},
+		{ID: "3", City: "Bogotá", Skills: repository.Skills{{Name: "Python"}, {Name: "Go"}}},
+		{ID: "4", City: "Cali", Skills: repository.Skills{{Name: "Rust"}}},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, v := range storedUsers {
+		err := newDB.Save(ctx, v)
+		assert.NoError(t, err)
+	}
+	filter := repository.UserFilter{
+		SkillsAny:   repository.Skills{{Name: "Go"}, {Name: "Python"}},
+		Facets:      []string{repository.FacetSkills, repository.FacetCity},
+		Page:        1,
+		RowsPerPage: 1,
+	}
+	expectedFacets := map[string][]repository.FacetValue{
+		repository.FacetSkills: {{Value: "Go", Count: 3}, {Value: "Python", Count: 2}},
+		repository.FacetCity:   {{Value: "Bogotá", Count: 2}, {Value: "Medellín", Count: 1}},
+	}
+
+	got, err := newDB.SearchWithFilters(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedFacets, got.Facets)
+	assert.Len(t, got.Users, 1)
+}
+
+func TestSearchUsersWithoutTotalWithRepository(t *testing.T) {
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, id := range []string{"1", "2", "3"} {
+		err := newDB.Save(ctx, repository.User{ID: id, City: "Cali"})
+		assert.NoError(t, err)
+	}
+
+	exact, err := newDB.SearchWithFilters(ctx, repository.UserFilter{TotalMode: repository.TotalEstimate, Page: 1, RowsPerPage: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, exact.Total)
+	assert.Equal(t, repository.TotalExact, exact.TotalMode)
+
+	skipped, err := newDB.SearchWithFilters(ctx, repository.UserFilter{TotalMode: repository.TotalNone, Page: 1, RowsPerPage: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, skipped.Total)
+	assert.Equal(t, repository.TotalNone, skipped.TotalMode)
+	assert.Len(t, skipped.Users, 2)
+	assert.True(t, skipped.HasMore)
+}
+
+func userIDs(found []repository.User) []string {
+	ids := make([]string, 0, len(found))
+	for _, v := range found {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+func TestExportUsersWithRepository(t *testing.T) {
+	storedUsers := []repository.User{
+		{ID: "3", FirstName: "Alicia", LastName: "Mendez", City: "Cali"},
+		{ID: "1", FirstName: "Fernando", LastName: "Ocampo", City: "Cali"},
+		{ID: "2", FirstName: "Oliver", LastName: "Vasquez", City: "Bogota"},
+	}
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	for _, v := range storedUsers {
+		err := newDB.Save(ctx, v)
+		assert.NoError(t, err)
+	}
+	givenFilter := repository.UserFilter{
+		City:        "Cali",
+		Sort:        []repository.SortField{{Field: repository.SortByID}},
+		Page:        2,
+		RowsPerPage: 1,
+	}
+
+	var exported []repository.User
+	err := newDB.ExportWithFilters(ctx, givenFilter, func(user repository.User) error {
+		exported = append(exported, user)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "3"}, userIDs(exported))
+}
@@ -0,0 +1,112 @@
+package memorydb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/skills"
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+// SkillMemoryRepository is the repository handler for the skill catalog in a memory db.
+type SkillMemoryRepository struct {
+	storage *DryRunRepository
+}
+
+// NewSkillDryRunRepository creates a new skill repository in a dry run repository.
+func NewSkillDryRunRepository() *SkillMemoryRepository {
+	return &SkillMemoryRepository{
+		storage: NewDryRunRepository(),
+	}
+}
+
+// FindByName look for the skill with the given canonical name.
+func (s *SkillMemoryRepository) FindByName(ctx context.Context, name string) (*repository.Skill, error) {
+	log.Println("level", "DEBUG", "msg", "reading skill", "method", "repository.SkillMemoryRepository.FindByName", "name", name)
+	result, err := s.storage.FindByID(ctx, name)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading skill", "method", "repository.SkillMemoryRepository.FindByName", "error", err)
+		return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "skill cannot be read", err)
+	}
+	if result == nil {
+		return nil, skills.ErrSkillNotFound
+	}
+	skill, ok := result.(repository.Skill)
+	if !ok {
+		return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "unexpected object in memory storage", fmt.Errorf("unexpected type %T", result))
+	}
+	return &skill, nil
+}
+
+// FindAll returns the whole skill catalog ordered by name.
+func (s *SkillMemoryRepository) FindAll(ctx context.Context) ([]repository.Skill, error) {
+	log.Println("level", "DEBUG", "msg", "reading skill catalog", "method", "repository.SkillMemoryRepository.FindAll")
+	results, err := s.storage.FindAll(ctx)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading skill catalog", "method", "repository.SkillMemoryRepository.FindAll", "error", err)
+		return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "skill catalog cannot be read", err)
+	}
+	catalog := make([]repository.Skill, 0, len(results))
+	for _, result := range results {
+		skill, ok := result.(repository.Skill)
+		if !ok {
+			return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "unexpected object in memory storage", fmt.Errorf("unexpected type %T", result))
+		}
+		catalog = append(catalog, skill)
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Name < catalog[j].Name
+	})
+	return catalog, nil
+}
+
+// Save stores the given skill in the catalog.
+func (s *SkillMemoryRepository) Save(ctx context.Context, skill repository.Skill) error {
+	log.Println("level", "DEBUG", "msg", "storing skill", "method", "repository.SkillMemoryRepository.Save", "data", skill)
+	existing, err := s.storage.FindByID(ctx, skill.Name)
+	if err != nil {
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "skill cannot be stored", err)
+	}
+	if existing != nil {
+		return users.NewConflictError(skills.ErrorCodeSkillConflict, "skill already exists", nil)
+	}
+	err = s.storage.Save(ctx, skill.Name, skill)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "storing skill", "method", "repository.SkillMemoryRepository.Save", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "skill cannot be stored", err)
+	}
+	return nil
+}
+
+// Update replaces the aliases of the given skill.
+func (s *SkillMemoryRepository) Update(ctx context.Context, skill repository.Skill) error {
+	log.Println("level", "DEBUG", "msg", "updating skill", "method", "repository.SkillMemoryRepository.Update", "data", skill)
+	_, err := s.FindByName(ctx, skill.Name)
+	if err != nil {
+		return err
+	}
+	err = s.storage.Update(ctx, skill.Name, skill)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "updating skill", "method", "repository.SkillMemoryRepository.Update", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "skill cannot be updated", err)
+	}
+	return nil
+}
+
+// Delete removes the skill with the given name from the catalog.
+func (s *SkillMemoryRepository) Delete(ctx context.Context, name string) error {
+	log.Println("level", "DEBUG", "msg", "deleting skill", "method", "repository.SkillMemoryRepository.Delete", "name", name)
+	_, err := s.FindByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	err = s.storage.Delete(ctx, name)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "deleting skill", "method", "repository.SkillMemoryRepository.Delete", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "skill cannot be deleted", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package memorydb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/google/uuid"
+)
+
+// fixturesActor actor the users of a fixtures file are created by when they
+// don't say it.
+const fixturesActor = "fixtures"
+
+// snapshot state of the user repository as it is written to disk.
+type snapshot struct {
+	// Users from the least recently used to the most.
+	Users   []snapshotUser                     `json:"users"`
+	History map[string][]repository.AuditEntry `json:"history"`
+}
+
+// snapshotUser user kept in a snapshot, deleted or not.
+type snapshotUser struct {
+	User      repository.User `json:"user"`
+	DeletedAt *time.Time      `json:"deleted_at,omitempty"`
+}
+
+// LoadFixtures saves the users of the given file, a JSON array of users or
+// one user per line. Users without id get a new one, the way created users
+// do, and users without creation stamp are stamped as created now by
+// fixtures.
+func (u *UserMemoryRepository) LoadFixtures(ctx context.Context, path string) error {
+	log.Println("level", "INFO", "msg", "loading user fixtures", "method", "repository.UserMemoryRepository.LoadFixtures", "path", path)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("fixtures cannot be opened: %w", err)
+	}
+	defer file.Close()
+	fixtures, err := readFixtures(file)
+	if err != nil {
+		return fmt.Errorf("fixtures in %s cannot be read: %w", path, err)
+	}
+	now := time.Now().UTC()
+	for i := range fixtures {
+		if fixtures[i].ID == "" {
+			fixtures[i].ID = uuid.New().String()
+		}
+		if fixtures[i].CreatedAt.IsZero() {
+			fixtures[i].CreatedAt = now
+		}
+		if fixtures[i].CreatedBy == "" {
+			fixtures[i].CreatedBy = fixturesActor
+		}
+		if fixtures[i].UpdatedAt.IsZero() {
+			fixtures[i].UpdatedAt = fixtures[i].CreatedAt
+		}
+		if fixtures[i].UpdatedBy == "" {
+			fixtures[i].UpdatedBy = fixtures[i].CreatedBy
+		}
+	}
+	fixtureErrs, err := u.SaveAll(ctx, fixtures)
+	if err != nil {
+		return fmt.Errorf("fixtures in %s cannot be saved: %w", path, err)
+	}
+	for i, fixtureErr := range fixtureErrs {
+		if fixtureErr != nil {
+			return fmt.Errorf("fixture %d in %s cannot be saved: %w", i+1, path, fixtureErr)
+		}
+	}
+	log.Println("level", "INFO", "msg", "user fixtures loaded", "method", "repository.UserMemoryRepository.LoadFixtures", "users", len(fixtures))
+	return nil
+}
+
+// readFixtures reads a JSON array of users or one user per line.
+func readFixtures(r io.Reader) ([]repository.User, error) {
+	reader := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			first = b
+			break
+		}
+	}
+	err := reader.UnreadByte()
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(reader)
+	var fixtures []repository.User
+	if first == '[' {
+		err := decoder.Decode(&fixtures)
+		return fixtures, err
+	}
+	for decoder.More() {
+		var fixture repository.User
+		err := decoder.Decode(&fixture)
+		if err != nil {
+			return nil, fmt.Errorf("user %d: %w", len(fixtures)+1, err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// SaveSnapshot writes every user, deleted or not, and their history to the
+// given file. The file is replaced at once, so a failed snapshot never
+// leaves a broken one behind. The snapshot waits for the running transaction
+// to end, so it only has committed changes, unless it is taken within the
+// transaction.
+func (u *UserMemoryRepository) SaveSnapshot(ctx context.Context, path string) error {
+	log.Println("level", "DEBUG", "msg", "saving snapshot", "method", "repository.UserMemoryRepository.SaveSnapshot", "path", path)
+	inTransaction := u.inTransaction(ctx)
+	if !inTransaction {
+		u.txMu.Lock()
+	}
+	u.mu.RLock()
+	state, err := u.snapshot(ctx)
+	u.mu.RUnlock()
+	if !inTransaction {
+		u.txMu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("snapshot cannot be encoded: %w", err)
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot cannot be created: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(content)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("snapshot cannot be written: %w", err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("snapshot cannot be written: %w", err)
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("snapshot cannot be replaced: %w", err)
+	}
+	log.Println("level", "DEBUG", "msg", "snapshot saved", "method", "repository.UserMemoryRepository.SaveSnapshot", "users", len(state.Users))
+	return nil
+}
+
+// snapshot returns the state of the repository, the caller must hold the lock.
+func (u *UserMemoryRepository) snapshot(ctx context.Context) (snapshot, error) {
+	records, err := u.storage.FindAll(ctx)
+	if err != nil {
+		return snapshot{}, err
+	}
+	state := snapshot{
+		Users:   make([]snapshotUser, 0, len(records)),
+		History: make(map[string][]repository.AuditEntry, len(u.history)),
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		record, ok := records[i].(userRecord)
+		if !ok {
+			return snapshot{}, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "unexpected object in memory storage", fmt.Errorf("unexpected type %T", records[i]))
+		}
+		state.Users = append(state.Users, snapshotUser{User: record.user, DeletedAt: record.deletedAt})
+	}
+	for userID, entries := range u.history {
+		state.History[userID] = entries
+	}
+	return state, nil
+}
+
+// LoadSnapshot replaces the state of the repository with the one of the given
+// snapshot file. It returns false if the file doesn't exist.
+func (u *UserMemoryRepository) LoadSnapshot(ctx context.Context, path string) (bool, error) {
+	log.Println("level", "INFO", "msg", "loading snapshot", "method", "repository.UserMemoryRepository.LoadSnapshot", "path", path)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("snapshot cannot be read: %w", err)
+	}
+	var state snapshot
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		return false, fmt.Errorf("snapshot in %s cannot be decoded: %w", path, err)
+	}
+	unlock := u.lock(ctx)
+	defer unlock()
+	err = u.restore(ctx, state)
+	if err != nil {
+		return false, err
+	}
+	log.Println("level", "INFO", "msg", "snapshot loaded", "method", "repository.UserMemoryRepository.LoadSnapshot", "users", len(state.Users))
+	return true, nil
+}
+
+// restore replaces the state of the repository with the given one, the caller
+// must hold the lock.
+func (u *UserMemoryRepository) restore(ctx context.Context, state snapshot) error {
+	records, err := u.storage.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range records {
+		if record, ok := v.(userRecord); ok {
+			_ = u.storage.Delete(ctx, record.user.ID)
+		}
+	}
+	u.history = make(map[string][]repository.AuditEntry, len(state.History))
+	for userID, entries := range state.History {
+		u.history[userID] = entries
+	}
+	for _, v := range state.Users {
+		err := u.storage.Save(ctx, v.User.ID, userRecord{user: v.User, deletedAt: v.DeletedAt})
+		if err != nil {
+			return fmt.Errorf("user %s of the snapshot cannot be restored: %w", v.User.ID, err)
+		}
+	}
+	return nil
+}
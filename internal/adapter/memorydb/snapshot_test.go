@@ -0,0 +1,194 @@
+package memorydb_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/memorydb"
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFixturesFromJSONArray(t *testing.T) {
+	fixtures := `[
+		{"id": "1", "first_name": "Fernando", "last_name": "Ocampo", "city": "Cali", "skills": [{"name": "Go", "level": "advanced"}]},
+		{"id": "2", "first_name": "Alicia", "last_name": "Mendez", "city": "Cali", "created_by": "tester"}
+	]`
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "users.json", fixtures)
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.LoadFixtures(ctx, path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, searchUserIDs(t, newDB, repository.UserFilter{}))
+	user, err := newDB.FindByID(ctx, "1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, repository.Skills{{Name: "Go", Level: "advanced"}}, user.Skills)
+		assert.Equal(t, "fixtures", user.CreatedBy)
+		assert.False(t, user.CreatedAt.IsZero())
+		assert.Equal(t, 1, user.Version)
+	}
+	user, err = newDB.FindByID(ctx, "2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "tester", user.CreatedBy)
+		assert.Equal(t, "tester", user.UpdatedBy)
+	}
+}
+
+func TestLoadFixturesFromNDJSON(t *testing.T) {
+	fixtures := `{"id": "1", "first_name": "Fernando", "last_name": "Ocampo"}
+{"id": "2", "first_name": "Alicia", "last_name": "Mendez"}
+
+{"id": "3", "first_name": "Oliver", "last_name": "Vasquez"}
+`
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "users.ndjson", fixtures)
+	newDB := memorydb.NewUserDryRunRepository()
+
+	err := newDB.LoadFixtures(context.TODO(), path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, searchUserIDs(t, newDB, repository.UserFilter{}))
+}
+
+func TestLoadFixturesWithoutID(t *testing.T) {
+	fixtures := `{"first_name": "Fernando", "last_name": "Ocampo"}
+{"first_name": "Alicia", "last_name": "Mendez"}
+`
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "users.ndjson", fixtures)
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+
+	err := newDB.LoadFixtures(ctx, path)
+
+	assert.NoError(t, err)
+	userIDs := searchUserIDs(t, newDB, repository.UserFilter{})
+	if assert.Len(t, userIDs, 2) {
+		assert.NotEmpty(t, userIDs[0])
+		assert.NotEqual(t, userIDs[0], userIDs[1])
+		user, err := newDB.FindByID(ctx, userIDs[0])
+		assert.NoError(t, err)
+		assert.Equal(t, userIDs[0], user.ID)
+	}
+}
+
+func TestLoadInvalidFixtures(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := writeTempFile(t, dir, "users.ndjson", `{"id": "1", "first_name": `)
+	newDB := memorydb.NewUserDryRunRepository()
+
+	err := newDB.LoadFixtures(context.TODO(), path)
+
+	assert.Error(t, err)
+	assert.Empty(t, searchUserIDs(t, newDB, repository.UserFilter{}))
+}
+
+func TestSaveAndLoadSnapshot(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "1", FirstName: "Fernando", City: "Cali"}))
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "2", FirstName: "Alicia", City: "Cali"}))
+	assert.NoError(t, newDB.Update(ctx, repository.User{ID: "1", FirstName: "Fernando", City: "Bogota", Version: 1}))
+	assert.NoError(t, newDB.Delete(ctx, repository.UserStateChange{ID: "2"}))
+
+	err := newDB.SaveSnapshot(ctx, path)
+	assert.NoError(t, err)
+
+	restoredDB := memorydb.NewUserDryRunRepository()
+	assert.NoError(t, restoredDB.Save(ctx, repository.User{ID: "3", FirstName: "Oliver"}))
+	restored, err := restoredDB.LoadSnapshot(ctx, path)
+
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	assert.Equal(t, []string{"1"}, searchUserIDs(t, restoredDB, repository.UserFilter{}))
+	user, err := restoredDB.FindByID(ctx, "1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Bogota", user.City)
+		assert.Equal(t, 2, user.Version)
+	}
+	_, err = restoredDB.FindByID(ctx, "2")
+	assert.Equal(t, users.ErrUserNotFound, err)
+	assert.NoError(t, restoredDB.Restore(ctx, repository.UserStateChange{ID: "2"}))
+	history, err := restoredDB.FindHistory(ctx, repository.HistoryFilter{UserID: "1", Page: 1, RowsPerPage: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, history.Entries, 2) {
+		assert.Equal(t, repository.AuditUpdate, history.Entries[0].Operation)
+	}
+}
+
+func TestSaveSnapshotWaitsForRunningTransaction(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+	newDB := memorydb.NewUserDryRunRepository()
+	ctx := context.TODO()
+	assert.NoError(t, newDB.Save(ctx, repository.User{ID: "1", FirstName: "Fernando"}))
+	snapshotErr := make(chan error, 1)
+
+	// WHEN
+	txErr := newDB.WithinTransaction(ctx, func(txCtx context.Context) error {
+		err := newDB.Save(txCtx, repository.User{ID: "2", FirstName: "Alicia"})
+		if err != nil {
+			return err
+		}
+		go func() {
+			snapshotErr <- newDB.SaveSnapshot(ctx, path)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("rolled back")
+	})
+
+	assert.Error(t, txErr)
+	assert.NoError(t, <-snapshotErr)
+	restoredDB := memorydb.NewUserDryRunRepository()
+	restored, err := restoredDB.LoadSnapshot(ctx, path)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+	assert.Equal(t, []string{"1"}, searchUserIDs(t, restoredDB, repository.UserFilter{}))
+}
+
+func TestLoadMissingSnapshot(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	newDB := memorydb.NewUserDryRunRepository()
+
+	restored, err := newDB.LoadSnapshot(context.TODO(), filepath.Join(dir, "snapshot.json"))
+
+	assert.NoError(t, err)
+	assert.False(t, restored)
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "memorydb")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return dir
+}
+
+func writeTempFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return path
+}
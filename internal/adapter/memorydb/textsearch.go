@@ -0,0 +1,118 @@
+package memorydb
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+)
+
+// accents letters with accents and the letter without them.
+var accents = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ñ': 'n', 'ç': 'c', 'ý': 'y', 'ÿ': 'y',
+}
+
+// searchTerms splits the given text in words, in lower case and without accents.
+func searchTerms(text string) []string {
+	folded := strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if plain, ok := accents[r]; ok {
+			return plain
+		}
+		return r
+	}, text)
+	return strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+	})
+}
+
+// textRelevance returns how well the user matches the given terms, every term
+// must match a word of the user's name or skills, exactly, as a prefix or with
+// a few typos. It returns false if any term does not match.
+func textRelevance(user repository.User, terms []string) (float64, bool) {
+	document := searchTerms(user.FirstName + " " + user.LastName)
+	for _, v := range user.Skills {
+		document = append(document, searchTerms(v.Name)...)
+	}
+	var relevance float64
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range document {
+			score := termScore(term, word)
+			if score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		relevance += best
+	}
+	return relevance, true
+}
+
+// termScore scores how similar the word is to the term, 1 if they are equal
+// and 0 if they are too different.
+func termScore(term, word string) float64 {
+	if term == word {
+		return 1
+	}
+	termLength := len([]rune(term))
+	if termLength >= 3 && strings.HasPrefix(word, term) {
+		return 0.75
+	}
+	distance := editDistance(term, word)
+	if distance > maxTypos(termLength) {
+		return 0
+	}
+	return 0.5 / float64(distance)
+}
+
+// maxTypos number of typos tolerated in a term of the given length, short
+// terms must be exact.
+func maxTypos(length int) int {
+	switch {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	}
+	return 2
+}
+
+// editDistance returns the Levenshtein distance between both words.
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+func min(values ...int) int {
+	smallest := values[0]
+	for _, v := range values[1:] {
+		if v < smallest {
+			smallest = v
+		}
+	}
+	return smallest
+}
@@ -0,0 +1,64 @@
+package memorydb
+
+import (
+	"context"
+	"log"
+)
+
+// txKey context key for the repository whose transaction the context carries.
+type txKey struct{}
+
+// WithinTransaction calls fn with a context that carries a transaction of the
+// repository. If fn fails every change it made is rolled back, the repository
+// is left as it was when the transaction started. Transactions run one at a
+// time and writes outside of them wait, but reads see the changes of the
+// running transaction before it ends. A context that already carries a
+// transaction of the repository joins it.
+func (u *UserMemoryRepository) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if u.inTransaction(ctx) {
+		return fn(ctx)
+	}
+	u.txMu.Lock()
+	defer u.txMu.Unlock()
+	u.mu.RLock()
+	state, err := u.snapshot(ctx)
+	u.mu.RUnlock()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "transaction cannot be started", "method", "repository.UserMemoryRepository.WithinTransaction", "error", err)
+		return err
+	}
+	err = fn(context.WithValue(ctx, txKey{}, u))
+	if err != nil {
+		u.mu.Lock()
+		rollbackErr := u.restore(ctx, state)
+		u.mu.Unlock()
+		if rollbackErr != nil {
+			log.Println("level", "ERROR", "msg", "transaction cannot be rolled back", "method", "repository.UserMemoryRepository.WithinTransaction", "error", rollbackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// inTransaction returns true if the given context carries a transaction of
+// the repository.
+func (u *UserMemoryRepository) inTransaction(ctx context.Context) bool {
+	repo, _ := ctx.Value(txKey{}).(*UserMemoryRepository)
+	return repo == u
+}
+
+// lock locks the repository for a write and returns the function that unlocks
+// it. Writes outside a transaction wait for the running one to end.
+func (u *UserMemoryRepository) lock(ctx context.Context) func() {
+	inTransaction := u.inTransaction(ctx)
+	if !inTransaction {
+		u.txMu.Lock()
+	}
+	u.mu.Lock()
+	return func() {
+		u.mu.Unlock()
+		if !inTransaction {
+			u.txMu.Unlock()
+		}
+	}
+}
@@ -2,67 +2,609 @@ package memorydb
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
 )
 
 // UserMemoryRepository is the repository handler for users in a memory db.
+// It is safe for concurrent use.
 type UserMemoryRepository struct {
+	// mu guards the reads and writes of a user so they happen as one.
+	mu sync.RWMutex
+	// txMu is held by the running transaction, writes outside of it wait so
+	// a rollback never undoes them.
+	txMu    sync.Mutex
 	storage *DryRunRepository
+	// history audit entries of every user, oldest first.
+	history map[string][]repository.AuditEntry
+}
+
+// userRecord is the way a user is kept in the memory storage.
+type userRecord struct {
+	user      repository.User
+	deletedAt *time.Time
 }
 
 // NewUserDryRunRepository creates a new user repository in a dry run repository
-func NewUserDryRunRepository() *UserMemoryRepository {
+// with the given capacity and eviction options. The history of evicted users
+// is removed too.
+func NewUserDryRunRepository(options ...Option) *UserMemoryRepository {
 	newRepo := UserMemoryRepository{
-		storage: NewDryRunRepository(),
+		history: make(map[string][]repository.AuditEntry),
 	}
+	// evictions happen while a write holds the lock of the user repository.
+	options = append(options, withEvictionHandler(func(userID string) {
+		delete(newRepo.history, userID)
+	}))
+	newRepo.storage = NewDryRunRepository(options...)
 	return &newRepo
 }
 
 // Save save the given user in the postgresql database.
 func (u *UserMemoryRepository) Save(ctx context.Context, user repository.User) error {
 	log.Println("level", "DEBUG", "msg", "storing user", "method", "repository.UserMemoryRepository.Save", "data", user)
-	err := u.storage.Save(ctx, user.ID, user)
+	unlock := u.lock(ctx)
+	defer unlock()
+	return u.save(ctx, user)
+}
+
+// save saves the given user, the caller must hold the lock.
+func (u *UserMemoryRepository) save(ctx context.Context, user repository.User) error {
+	record, err := u.findRecord(ctx, user.ID)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "storing user", "method", "repository.UserMemoryRepository.Save", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given user could not be stored", err)
+	}
+	if record != nil {
+		return users.NewConflictError(users.ErrorCodeUserAlreadyExists, "user already exists", nil)
+	}
+	user.Version = 1
+	err = u.storage.Save(ctx, user.ID, userRecord{user: user})
 	if err != nil {
 		log.Println("level", "ERROR", "msg", "storing user", "method", "repository.UserMemoryRepository.Save", "error", err)
-		return errors.New("given user could not be stored")
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given user could not be stored", err)
 	}
+	u.audit(repository.NewCreateAuditEntry(user))
 	return nil
 }
 
-// Update update the given user in the postgresql database.
+// Update update the given user in the memory database, the user version must
+// match the stored one.
 func (u *UserMemoryRepository) Update(ctx context.Context, user repository.User) error {
 	log.Println("level", "DEBUG", "msg", "updating user", "method", "repository.UserMemoryRepository.Update", "data", user)
-	err := u.storage.Update(ctx, user.ID, user)
+	unlock := u.lock(ctx)
+	defer unlock()
+	record, err := u.findRecord(ctx, user.ID)
 	if err != nil {
 		log.Println("level", "ERROR", "msg", "updating user", "method", "repository.UserMemoryRepository.Update", "error", err)
-		return errors.New("given user could not be updated")
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given user could not be updated", err)
+	}
+	if record == nil || record.deletedAt != nil {
+		return users.ErrUserNotFound
+	}
+	if record.user.Version != user.Version {
+		return users.ErrVersionConflict
+	}
+	user.CreatedAt = record.user.CreatedAt
+	user.CreatedBy = record.user.CreatedBy
+	user.Version++
+	err = u.storage.Update(ctx, user.ID, userRecord{user: user})
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "updating user", "method", "repository.UserMemoryRepository.Update", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given user could not be updated", err)
+	}
+	u.audit(repository.NewUpdateAuditEntry(repository.AuditUpdate, record.user, user))
+	return nil
+}
+
+// Patch applies the given changes on the stored user.
+func (u *UserMemoryRepository) Patch(ctx context.Context, changes repository.UserPatch) error {
+	log.Println("level", "DEBUG", "msg", "patching user", "method", "repository.UserMemoryRepository.Patch", "user id", changes.ID)
+	unlock := u.lock(ctx)
+	defer unlock()
+	record, err := u.findRecord(ctx, changes.ID)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "patching user", "method", "repository.UserMemoryRepository.Patch", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given user could not be patched", err)
+	}
+	if record == nil || record.deletedAt != nil {
+		return users.ErrUserNotFound
+	}
+	if record.user.Version != changes.Version {
+		return users.ErrVersionConflict
 	}
+	before := record.user
+	record.user = changes.Apply(record.user)
+	record.user.Version++
+	err = u.storage.Update(ctx, changes.ID, *record)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "patching user", "method", "repository.UserMemoryRepository.Patch", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given user could not be patched", err)
+	}
+	u.audit(repository.NewUpdateAuditEntry(repository.AuditPatch, before, record.user))
 	return nil
 }
 
+// SaveAll saves the given users, a user that cannot be saved is reported in
+// its position and the others are still saved. None of them is saved if they
+// don't fit in the repository.
+func (u *UserMemoryRepository) SaveAll(ctx context.Context, newUsers []repository.User) ([]error, error) {
+	log.Println("level", "DEBUG", "msg", "storing users", "method", "repository.UserMemoryRepository.SaveAll", "count", len(newUsers))
+	unlock := u.lock(ctx)
+	defer unlock()
+	if !u.storage.Fits(len(newUsers)) {
+		log.Println("level", "ERROR", "msg", "storing users", "method", "repository.UserMemoryRepository.SaveAll", "error", ErrCapacityExceeded)
+		return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "given users could not be stored", ErrCapacityExceeded)
+	}
+	userErrs := make([]error, len(newUsers))
+	for i, user := range newUsers {
+		userErrs[i] = u.save(ctx, user)
+	}
+	return userErrs, nil
+}
+
 // FindByID look for an user with the given id
 func (u *UserMemoryRepository) FindByID(ctx context.Context, userID string) (*repository.User, error) {
 	log.Println("level", "DEBUG", "msg", "reading user", "method", "repository.UserMemoryRepository.FindByID", "user id", userID)
-	result, err := u.storage.FindByID(ctx, userID)
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	record, err := u.findRecord(ctx, userID)
 	if err != nil {
 		log.Println("level", "ERROR", "msg", "reading user", "method", "repository.UserMemoryRepository.FindByID", "error", err)
-		return nil, errors.New("something went wrong trying to get the given user id")
+		return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "something went wrong trying to get the given user id", err)
+	}
+	if record == nil || record.deletedAt != nil {
+		return nil, users.ErrUserNotFound
+	}
+	user := record.user
+	return &user, nil
+}
+
+// Delete marks the user with the given id as deleted and audits it.
+func (u *UserMemoryRepository) Delete(ctx context.Context, change repository.UserStateChange) error {
+	log.Println("level", "DEBUG", "msg", "deleting user", "method", "repository.UserMemoryRepository.Delete", "user id", change.ID)
+	unlock := u.lock(ctx)
+	defer unlock()
+	record, err := u.findRecord(ctx, change.ID)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "deleting user", "method", "repository.UserMemoryRepository.Delete", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be deleted", err)
+	}
+	if record == nil || record.deletedAt != nil {
+		return users.ErrUserNotFound
+	}
+	deletedAt := change.UpdatedAt
+	record.deletedAt = &deletedAt
+	record.user.UpdatedAt = change.UpdatedAt
+	record.user.UpdatedBy = change.UpdatedBy
+	err = u.storage.Update(ctx, change.ID, *record)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "deleting user", "method", "repository.UserMemoryRepository.Delete", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be deleted", err)
+	}
+	u.audit(repository.NewStateAuditEntry(repository.AuditDelete, change))
+	return nil
+}
+
+// Restore unmarks the user with the given id as deleted and audits it.
+func (u *UserMemoryRepository) Restore(ctx context.Context, change repository.UserStateChange) error {
+	log.Println("level", "DEBUG", "msg", "restoring user", "method", "repository.UserMemoryRepository.Restore", "user id", change.ID)
+	unlock := u.lock(ctx)
+	defer unlock()
+	record, err := u.findRecord(ctx, change.ID)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "restoring user", "method", "repository.UserMemoryRepository.Restore", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be restored", err)
+	}
+	if record == nil || record.deletedAt == nil {
+		return users.ErrUserNotFound
+	}
+	record.deletedAt = nil
+	record.user.UpdatedAt = change.UpdatedAt
+	record.user.UpdatedBy = change.UpdatedBy
+	err = u.storage.Update(ctx, change.ID, *record)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "restoring user", "method", "repository.UserMemoryRepository.Restore", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be restored", err)
+	}
+	u.audit(repository.NewStateAuditEntry(repository.AuditRestore, change))
+	return nil
+}
+
+// Purge removes permanently the user with the given id, the field values of
+// its history are redacted and the purge is audited.
+func (u *UserMemoryRepository) Purge(ctx context.Context, change repository.UserStateChange) error {
+	log.Println("level", "DEBUG", "msg", "purging user", "method", "repository.UserMemoryRepository.Purge", "user id", change.ID)
+	unlock := u.lock(ctx)
+	defer unlock()
+	record, err := u.findRecord(ctx, change.ID)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "purging user", "method", "repository.UserMemoryRepository.Purge", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be purged", err)
+	}
+	if record == nil {
+		return users.ErrUserNotFound
+	}
+	err = u.storage.Delete(ctx, change.ID)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "purging user", "method", "repository.UserMemoryRepository.Purge", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be purged", err)
+	}
+	for i := range u.history[change.ID] {
+		u.history[change.ID][i].Changes = make(repository.FieldChanges, 0)
+	}
+	u.audit(repository.NewStateAuditEntry(repository.AuditPurge, change))
+	return nil
+}
+
+// SearchWithFilters look for the users that match the given filters, with the
+// same semantics of the postgresql search.
+func (u *UserMemoryRepository) SearchWithFilters(ctx context.Context, filter repository.UserFilter) (repository.FindUsersResult, error) {
+	log.Println("level", "DEBUG", "msg", "search users with filters", "method", "repository.UserMemoryRepository.SearchWithFilters", "filters", filter)
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	result := repository.FindUsersResult{
+		Page:        filter.Page,
+		RowsPerPage: filter.RowsPerPage,
+	}
+	found, err := u.findSorted(ctx, filter)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "search users with filters", "method", "repository.UserMemoryRepository.SearchWithFilters", "error", err)
+		return result, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "something went wrong trying to find some users", err)
+	}
+	// the users found are already in memory, they are counted exactly even
+	// if an estimate is enough.
+	result.Total = len(found)
+	result.TotalMode = repository.TotalExact
+	if filter.TotalMode == repository.TotalNone {
+		result.Total = 0
+		result.TotalMode = repository.TotalNone
+	}
+	result.Facets = countFacets(found, filter.Facets)
+	if filter.Cursor != nil {
+		result.Users, result.HasMore = seekUsers(found, repository.StableSort(filter.Sort), *filter.Cursor, filter.RowsPerPage)
+		return result, nil
+	}
+	start := filter.RowsPerPage * (filter.Page - 1)
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(found) && len(result.Users) < filter.RowsPerPage; i++ {
+		result.Users = append(result.Users, found[i])
+	}
+	result.HasMore = start+filter.RowsPerPage < len(found)
+	return result, nil
+}
+
+// ExportWithFilters calls fn with every user found with the given filter,
+// pagination of the filter is ignored.
+func (u *UserMemoryRepository) ExportWithFilters(ctx context.Context, filter repository.UserFilter, fn func(repository.User) error) error {
+	log.Println("level", "DEBUG", "msg", "export users with filters", "method", "repository.UserMemoryRepository.ExportWithFilters", "filters", filter)
+	// fn is called without the lock, so it can take its time.
+	u.mu.RLock()
+	found, err := u.findSorted(ctx, filter)
+	u.mu.RUnlock()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "export users with filters", "method", "repository.UserMemoryRepository.ExportWithFilters", "error", err)
+		return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "something went wrong trying to export users", err)
+	}
+	for _, v := range found {
+		err := fn(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findSorted returns every user that matches the filter in the order of its
+// sort fields.
+func (u *UserMemoryRepository) findSorted(ctx context.Context, filter repository.UserFilter) ([]repository.User, error) {
+	records, err := u.storage.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := searchTerms(filter.Query)
+	found := make([]repository.User, 0)
+	relevance := make(map[string]float64)
+	for _, v := range records {
+		record, ok := v.(userRecord)
+		if !ok || record.deletedAt != nil || !matchUser(filter, record.user) {
+			continue
+		}
+		if len(terms) > 0 {
+			score, matches := textRelevance(record.user, terms)
+			if !matches {
+				continue
+			}
+			relevance[record.user.ID] = score
+		}
+		found = append(found, record.user)
+	}
+	sortUsers(found, repository.StableSort(filter.Sort), relevance)
+	return found, nil
+}
+
+// seekUsers returns the page of the given sorted users that goes after the
+// cursor, or before it if the cursor goes backward, and if there are more
+// users beyond the page.
+func seekUsers(found []repository.User, fields []repository.SortField, cursor repository.Cursor, rowsPerPage int) ([]repository.User, bool) {
+	position := cursorUser(fields, cursor)
+	var page []repository.User
+	if cursor.Backward {
+		for _, v := range found {
+			if compareBySort(v, position, fields, nil) < 0 {
+				page = append(page, v)
+			}
+		}
+		if len(page) > rowsPerPage {
+			return page[len(page)-rowsPerPage:], true
+		}
+		return page, false
+	}
+	for _, v := range found {
+		if compareBySort(v, position, fields, nil) > 0 {
+			page = append(page, v)
+		}
+	}
+	if len(page) > rowsPerPage {
+		return page[:rowsPerPage], true
+	}
+	return page, false
+}
+
+// cursorUser builds a user with the sort values of the given cursor.
+func cursorUser(fields []repository.SortField, cursor repository.Cursor) repository.User {
+	var user repository.User
+	for i, v := range fields {
+		if i >= len(cursor.Values) {
+			break
+		}
+		value := cursor.Values[i]
+		switch v.Field {
+		case repository.SortByID:
+			user.ID = value
+		case repository.SortByFirstName:
+			user.FirstName = value
+		case repository.SortByLastName:
+			user.LastName = value
+		case repository.SortByCity:
+			user.City = value
+		case repository.SortByCreatedAt:
+			user.CreatedAt, _ = time.Parse(time.RFC3339Nano, value)
+		case repository.SortByUpdatedAt:
+			user.UpdatedAt, _ = time.Parse(time.RFC3339Nano, value)
+		}
+	}
+	return user
+}
+
+// FindHistory returns the audit entries of the given user, newest first.
+func (u *UserMemoryRepository) FindHistory(ctx context.Context, filter repository.HistoryFilter) (repository.FindHistoryResult, error) {
+	log.Println("level", "DEBUG", "msg", "reading user history", "method", "repository.UserMemoryRepository.FindHistory", "user id", filter.UserID)
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	entries := u.history[filter.UserID]
+	result := repository.FindHistoryResult{
+		Entries:     make([]repository.AuditEntry, 0),
+		Total:       len(entries),
+		Page:        filter.Page,
+		RowsPerPage: filter.RowsPerPage,
+	}
+	start := filter.RowsPerPage * (filter.Page - 1)
+	if start < 0 {
+		start = 0
+	}
+	for i := len(entries) - 1 - start; i >= 0 && len(result.Entries) < filter.RowsPerPage; i-- {
+		result.Entries = append(result.Entries, entries[i])
+	}
+	return result, nil
+}
+
+// audit keeps the given audit entry in the history of its user.
+func (u *UserMemoryRepository) audit(entry repository.AuditEntry) {
+	u.history[entry.UserID] = append(u.history[entry.UserID], entry)
+}
+
+// findRecord reads the record stored for the given user id, deleted or not.
+func (u *UserMemoryRepository) findRecord(ctx context.Context, userID string) (*userRecord, error) {
+	result, err := u.storage.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 	if result == nil {
 		return nil, nil
 	}
-	user, ok := result.(repository.User)
+	record, ok := result.(userRecord)
 	if !ok {
-		log.Println("level", "ERROR", "msg", "reading user", "method", "repository.UserMemoryRepository.FindByID", "error", "unexpected object", "object", result)
-		return nil, errors.New("something went wrong trying to get the given user id")
+		log.Println("level", "ERROR", "msg", "reading user", "method", "repository.UserMemoryRepository.findRecord", "error", "unexpected object", "object", result)
+		return nil, users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "unexpected object in memory storage", fmt.Errorf("unexpected type %T", result))
 	}
-	return &user, nil
+	return &record, nil
 }
 
-// SearchWithFilters memory search
-func (u *UserMemoryRepository) SearchWithFilters(ctx context.Context, filter repository.UserFilter) (repository.FindUsersResult, error) {
-	return repository.FindUsersResult{}, nil
+// countFacets counts the given users by every given facet, the most common
+// values go first.
+func countFacets(found []repository.User, facets []string) map[string][]repository.FacetValue {
+	if len(facets) == 0 {
+		return nil
+	}
+	result := make(map[string][]repository.FacetValue, len(facets))
+	for _, facet := range facets {
+		if facet != repository.FacetSkills && facet != repository.FacetCity {
+			continue
+		}
+		counts := make(map[string]int)
+		for _, user := range found {
+			if facet == repository.FacetCity {
+				if user.City != "" {
+					counts[user.City]++
+				}
+				continue
+			}
+			seen := make(map[string]bool)
+			for _, skill := range user.Skills {
+				if skill.Name == "" || seen[skill.Name] {
+					continue
+				}
+				seen[skill.Name] = true
+				counts[skill.Name]++
+			}
+		}
+		values := make([]repository.FacetValue, 0, len(counts))
+		for value, count := range counts {
+			values = append(values, repository.FacetValue{Value: value, Count: count})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		if len(values) > repository.FacetLimit {
+			values = values[:repository.FacetLimit]
+		}
+		result[facet] = values
+	}
+	return result
+}
+
+// sortUsers sorts the given users by the given fields, relevance has the
+// relevance of every user by id.
+func sortUsers(found []repository.User, fields []repository.SortField, relevance map[string]float64) {
+	sort.Slice(found, func(i, j int) bool {
+		return compareBySort(found[i], found[j], fields, relevance) < 0
+	})
+}
+
+// compareBySort compares both users by the given fields, it returns a negative
+// number if a goes first, a positive one if b goes first and 0 if they are equal.
+func compareBySort(a, b repository.User, fields []repository.SortField, relevance map[string]float64) int {
+	for _, field := range fields {
+		comparison := compareUsers(a, b, field.Field)
+		if field.Field == repository.SortByRelevance {
+			comparison = compareRelevance(relevance[a.ID], relevance[b.ID])
+		}
+		if comparison == 0 {
+			continue
+		}
+		if field.Descending {
+			return -comparison
+		}
+		return comparison
+	}
+	return 0
+}
+
+// compareUsers compares the given field of both users, it returns a negative
+// number if a goes first, a positive one if b goes first and 0 if they are equal.
+func compareUsers(a, b repository.User, field string) int {
+	switch field {
+	case repository.SortByID:
+		return strings.Compare(a.ID, b.ID)
+	case repository.SortByFirstName:
+		return strings.Compare(a.FirstName, b.FirstName)
+	case repository.SortByLastName:
+		return strings.Compare(a.LastName, b.LastName)
+	case repository.SortByCity:
+		return strings.Compare(a.City, b.City)
+	case repository.SortByCreatedAt:
+		return compareTimes(a.CreatedAt, b.CreatedAt)
+	case repository.SortByUpdatedAt:
+		return compareTimes(a.UpdatedAt, b.UpdatedAt)
+	}
+	return 0
+}
+
+func compareRelevance(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// matchUser checks if the given user matches every given filter.
+func matchUser(filter repository.UserFilter, user repository.User) bool {
+	if filter.City != "" && user.City != filter.City {
+		return false
+	}
+	if !filter.UpdatedSince.IsZero() && user.UpdatedAt.Before(filter.UpdatedSince) {
+		return false
+	}
+	for _, v := range filter.Skills {
+		if findSkill(user.Skills, v.Name) == nil {
+			return false
+		}
+	}
+	if len(filter.SkillsAny) > 0 {
+		anyFound := false
+		for _, v := range filter.SkillsAny {
+			if findSkill(user.Skills, v.Name) != nil {
+				anyFound = true
+				break
+			}
+		}
+		if !anyFound {
+			return false
+		}
+	}
+	for _, v := range filter.SkillsNone {
+		if findSkill(user.Skills, v.Name) != nil {
+			return false
+		}
+	}
+	for _, v := range filter.SkillRequirements {
+		if !meetsRequirement(user.Skills, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// meetsRequirement checks if any of the given skills meets the requirement.
+func meetsRequirement(skills repository.Skills, requirement repository.SkillRequirement) bool {
+	for _, skill := range skills {
+		if skill.Name != requirement.Name || skill.Years < requirement.MinYears {
+			continue
+		}
+		if len(requirement.Levels) == 0 {
+			return true
+		}
+		for _, level := range requirement.Levels {
+			if skill.Level == level {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findSkill returns the skill with the given name, nil if there is none.
+func findSkill(skills repository.Skills, name string) *repository.UserSkill {
+	for i := range skills {
+		if skills[i].Name == name {
+			return &skills[i]
+		}
+	}
+	return nil
 }
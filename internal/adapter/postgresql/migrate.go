@@ -0,0 +1,265 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// migrationFiles up and down SQL of every migration, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	createSchemaSQL = "CREATE SCHEMA IF NOT EXISTS %s"
+	// the extensions of the migrations live in public, it stays in the path so
+	// their functions and operator classes are found from any schema.
+	setSearchPathSQL         = "SET LOCAL search_path TO %s, public"
+	createMigrationsTableSQL = "CREATE TABLE IF NOT EXISTS {migrations} (version integer PRIMARY KEY, name text NOT NULL, applied_at timestamp with time zone NOT NULL DEFAULT now())"
+	selectMigrationsSQL      = "SELECT version FROM {migrations} ORDER BY version"
+	insertMigrationSQL       = "INSERT INTO {migrations}(version, name) VALUES ($1, $2)"
+	deleteMigrationSQL       = "DELETE FROM {migrations} WHERE version = $1"
+	// the advisory lock is held by the session, so every statement of a
+	// migration run goes through the same connection.
+	lockMigrationsSQL   = "SELECT pg_advisory_lock(hashtext('users-micro.schema_migrations'))"
+	unlockMigrationsSQL = "SELECT pg_advisory_unlock(hashtext('users-micro.schema_migrations'))"
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration a versioned change of the database schema.
+type Migration struct {
+	Version int
+	Name    string
+	// Up applies the change.
+	Up string
+	// Down reverts the change.
+	Down string
+}
+
+// Migrations returns the migrations compiled in the binary, oldest first.
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		parts := migrationFileName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migration file %q must be <version>_<name>.up.sql or <version>_<name>.down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(parts[1])
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, v := range byVersion {
+		if v.Up == "" || v.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have an up and a down file", v.Version, v.Name)
+		}
+		migrations = append(migrations, *v)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies the migrations on a database and keeps track of them in
+// the schema_migrations table. Concurrent migrators wait for each other, so
+// replicas can migrate at startup.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// schema where the migrations are applied, the search_path of the
+	// connection if it is empty.
+	schema string
+	tables *strings.Replacer
+}
+
+// NewMigrator creates a migrator with the migrations compiled in the binary,
+// the schema option sets where they are applied. The migrations only create
+// the default tables, so a table option with any other table is rejected.
+func NewMigrator(db *sql.DB, options ...Option) (*Migrator, error) {
+	names := newSettings(options).tables
+	if names.users != defaultUsersTable {
+		return nil, fmt.Errorf("migrations only create the %s table, table %s must be created by hand", defaultUsersTable, names.users)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		schema:     names.schema,
+		tables:     names.replacer(),
+	}, nil
+}
+
+// Up applies every migration that is not applied yet, oldest first. It
+// returns the number of migrations applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var applied int
+	err := m.withLock(ctx, func(conn *sql.Conn, versions map[int]bool) error {
+		for _, migration := range m.migrations {
+			if versions[migration.Version] {
+				continue
+			}
+			log.Println("level", "INFO", "msg", "applying migration", "method", "postgresql.Migrator.Up", "version", migration.Version, "name", migration.Name)
+			err := m.runMigration(ctx, conn, migration.Up, insertMigrationSQL, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s cannot be applied: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the given number of applied migrations, newest first. It
+// returns the number of migrations reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	var reverted int
+	err := m.withLock(ctx, func(conn *sql.Conn, versions map[int]bool) error {
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if !versions[migration.Version] {
+				continue
+			}
+			log.Println("level", "INFO", "msg", "reverting migration", "method", "postgresql.Migrator.Down", "version", migration.Version, "name", migration.Name)
+			err := m.runMigration(ctx, conn, migration.Down, deleteMigrationSQL, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s cannot be reverted: %w", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Version returns the version of the newest migration applied, zero if none
+// is applied.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+	err := m.withLock(ctx, func(conn *sql.Conn, versions map[int]bool) error {
+		for v := range versions {
+			if v > version {
+				version = v
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// withLock calls fn with the versions already applied while it holds the
+// migrations lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn, map[int]bool) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, lockMigrationsSQL)
+	if err != nil {
+		return fmt.Errorf("migrations lock cannot be taken: %w", err)
+	}
+	defer func() {
+		_, err := conn.ExecContext(context.Background(), unlockMigrationsSQL)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "migrations lock cannot be released", "method", "postgresql.Migrator.withLock", "error", err)
+		}
+	}()
+	if m.schema != "" {
+		_, err = conn.ExecContext(ctx, fmt.Sprintf(createSchemaSQL, pq.QuoteIdentifier(m.schema)))
+		if err != nil {
+			return fmt.Errorf("schema %s cannot be created: %w", m.schema, err)
+		}
+	}
+	_, err = conn.ExecContext(ctx, m.tables.Replace(createMigrationsTableSQL))
+	if err != nil {
+		return fmt.Errorf("schema_migrations cannot be created: %w", err)
+	}
+	versions, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, versions)
+}
+
+// appliedVersions reads the versions of the migrations already applied.
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, m.tables.Replace(selectMigrationsSQL))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	return versions, rows.Err()
+}
+
+// runMigration runs the given migration SQL and records it in
+// schema_migrations in one transaction. The objects of the migration are
+// created in the schema of the migrator.
+func (m *Migrator) runMigration(ctx context.Context, conn *sql.Conn, migrationSQL, recordSQL string, recordArgs ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if m.schema != "" {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(setSearchPathSQL, pq.QuoteIdentifier(m.schema)))
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, migrationSQL)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, m.tables.Replace(recordSQL), recordArgs...)
+	}
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			log.Println("level", "ERROR", "msg", "migration cannot be rolled back", "method", "postgresql.Migrator.runMigration", "error", rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,203 @@
+package postgresql_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fernandoocampo/users-micro/internal/adapter/postgresql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	migrations, err := postgresql.Migrations()
+
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 9) {
+		for i, v := range migrations {
+			assert.Equal(t, i+1, v.Version)
+			assert.NotEmpty(t, v.Up)
+			assert.NotEmpty(t, v.Down)
+		}
+		assert.Equal(t, "init", migrations[0].Name)
+		assert.Equal(t, "text_search", migrations[7].Name)
+		assert.Equal(t, "audit_redaction", migrations[8].Name)
+	}
+}
+
+func TestMigrateUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	appliedRows := sqlmock.NewRows([]string{"version"}).
+		AddRow(1).AddRow(2).AddRow(3).AddRow(4).AddRow(5).AddRow(6).AddRow(7).AddRow(8)
+
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock(hashtext('users-micro.schema_migrations'))")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "schema_migrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version FROM "schema_migrations" ORDER BY version`).
+		WillReturnRows(appliedRows)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE OR REPLACE FUNCTION jobseeker_audit_append_only").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "schema_migrations"(version, name) VALUES ($1, $2)`)).
+		WithArgs(9, "audit_redaction").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock(hashtext('users-micro.schema_migrations'))")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	migrator, err := postgresql.NewMigrator(db)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// WHEN
+	applied, migrateError := migrator.Up(context.TODO())
+
+	assert.NoError(t, migrateError)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateUpWithFailingMigration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("SELECT pg_advisory_lock").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "schema_migrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS jobseeker").
+		WillReturnError(errors.New("permission denied"))
+	mock.ExpectRollback()
+	mock.ExpectExec("SELECT pg_advisory_unlock").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	migrator, err := postgresql.NewMigrator(db)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// WHEN
+	applied, migrateError := migrator.Up(context.TODO())
+
+	assert.Error(t, migrateError)
+	assert.Equal(t, 0, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	appliedRows := sqlmock.NewRows([]string{"version"}).
+		AddRow(1).AddRow(2).AddRow(3).AddRow(4).AddRow(5).AddRow(6).AddRow(7).AddRow(8).AddRow(9)
+
+	mock.ExpectExec("SELECT pg_advisory_lock").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "schema_migrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version FROM "schema_migrations"`).
+		WillReturnRows(appliedRows)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE OR REPLACE FUNCTION jobseeker_audit_append_only(.+)RAISE EXCEPTION 'jobseeker_audit is append only';\\s+END;").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "schema_migrations" WHERE version = $1`)).
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	migrator, err := postgresql.NewMigrator(db)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// WHEN
+	reverted, migrateError := migrator.Down(context.TODO(), 1)
+
+	assert.NoError(t, migrateError)
+	assert.Equal(t, 1, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateUpInSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	appliedRows := sqlmock.NewRows([]string{"version"}).
+		AddRow(1).AddRow(2).AddRow(3).AddRow(4).AddRow(5).AddRow(6).AddRow(7)
+
+	mock.ExpectExec("SELECT pg_advisory_lock").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE SCHEMA IF NOT EXISTS "staging"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "staging"."schema_migrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version FROM "staging"."schema_migrations"`).
+		WillReturnRows(appliedRows)
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta(`SET LOCAL search_path TO "staging", public`) + "$").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE EXTENSION IF NOT EXISTS unaccent (.+) gin_trgm_ops").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "staging"."schema_migrations"`).
+		WithArgs(8, "text_search").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^" + regexp.QuoteMeta(`SET LOCAL search_path TO "staging", public`) + "$").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE OR REPLACE FUNCTION jobseeker_audit_append_only").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "staging"."schema_migrations"`).
+		WithArgs(9, "audit_redaction").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	migrator, err := postgresql.NewMigrator(db, postgresql.WithSchema("staging"))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// WHEN
+	applied, migrateError := migrator.Up(context.TODO())
+
+	assert.NoError(t, migrateError)
+	assert.Equal(t, 2, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorRejectsCustomTable(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	_, migratorError := postgresql.NewMigrator(db, postgresql.WithTable("candidate"))
+
+	assert.Error(t, migratorError)
+}
@@ -0,0 +1,11 @@
+-- Job seekers. Every migration is written so it can run on databases created
+-- before migrations existed, the objects are created only if they are missing.
+
+CREATE TABLE IF NOT EXISTS jobseeker
+(
+    id text PRIMARY KEY,
+    firstname text COLLATE pg_catalog."default",
+    lastname text COLLATE pg_catalog."default",
+    city text COLLATE pg_catalog."default",
+    skills jsonb
+);
@@ -0,0 +1,4 @@
+-- Soft delete for job seekers, a deleted row keeps the moment it was deleted.
+
+ALTER TABLE jobseeker
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
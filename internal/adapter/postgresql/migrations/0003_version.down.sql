@@ -0,0 +1,2 @@
+ALTER TABLE jobseeker
+    DROP COLUMN IF EXISTS version;
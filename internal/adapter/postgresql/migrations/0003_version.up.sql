@@ -0,0 +1,4 @@
+-- Row version for optimistic concurrency, every update increments it.
+
+ALTER TABLE jobseeker
+    ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS jobseeker_updated_at_idx;
+
+ALTER TABLE jobseeker
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS created_by,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS updated_by;
@@ -0,0 +1,10 @@
+-- When and by whom a job seeker was created and last changed.
+
+ALTER TABLE jobseeker
+    ADD COLUMN IF NOT EXISTS created_at timestamp with time zone NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS created_by text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_by text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS jobseeker_updated_at_idx
+    ON jobseeker (updated_at);
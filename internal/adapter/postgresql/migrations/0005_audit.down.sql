@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS jobseeker_audit;
+
+DROP FUNCTION IF EXISTS jobseeker_audit_append_only();
@@ -0,0 +1,27 @@
+-- Field level history of every change on a job seeker. Entries are only
+-- appended, they can't be updated or deleted.
+
+CREATE TABLE IF NOT EXISTS jobseeker_audit
+(
+    id bigserial PRIMARY KEY,
+    jobseeker_id text NOT NULL,
+    operation text NOT NULL,
+    actor text NOT NULL,
+    changed_at timestamp with time zone NOT NULL,
+    changes jsonb NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS jobseeker_audit_jobseeker_id_idx
+    ON jobseeker_audit (jobseeker_id, id);
+
+CREATE OR REPLACE FUNCTION jobseeker_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'jobseeker_audit is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS jobseeker_audit_append_only ON jobseeker_audit;
+
+CREATE TRIGGER jobseeker_audit_append_only
+    BEFORE UPDATE OR DELETE ON jobseeker_audit
+    FOR EACH ROW EXECUTE PROCEDURE jobseeker_audit_append_only();
@@ -0,0 +1,10 @@
+-- Catalog of canonical skills, aliases are other names of the same skill.
+
+CREATE TABLE IF NOT EXISTS skill_catalog
+(
+    name text PRIMARY KEY,
+    aliases jsonb NOT NULL DEFAULT '[]'
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS skill_catalog_lower_name_idx
+    ON skill_catalog (lower(name));
@@ -0,0 +1,13 @@
+-- Skills go back to plain strings with their names, their level and years of
+-- experience are lost.
+
+UPDATE jobseeker
+   SET skills = (
+       SELECT COALESCE(jsonb_agg(
+                  CASE WHEN jsonb_typeof(skill) = 'object'
+                       THEN to_jsonb(skill->>'name')
+                       ELSE skill
+                  END ORDER BY skill_order), '[]')
+         FROM jsonb_array_elements(skills) WITH ORDINALITY AS skills_of_user(skill, skill_order))
+ WHERE jsonb_typeof(skills) = 'array'
+   AND EXISTS (SELECT 1 FROM jsonb_array_elements(skills) AS skill WHERE jsonb_typeof(skill) = 'object');
@@ -0,0 +1,14 @@
+-- Skills are objects with name, level and years of experience. Skills stored
+-- as plain strings are still readable, this converts them so they can be
+-- searched too.
+
+UPDATE jobseeker
+   SET skills = (
+       SELECT COALESCE(jsonb_agg(
+                  CASE WHEN jsonb_typeof(skill) = 'string'
+                       THEN jsonb_build_object('name', skill #>> '{}')
+                       ELSE skill
+                  END), '[]')
+         FROM jsonb_array_elements(skills) AS skill)
+ WHERE jsonb_typeof(skills) = 'array'
+   AND EXISTS (SELECT 1 FROM jsonb_array_elements(skills) AS skill WHERE jsonb_typeof(skill) = 'string');
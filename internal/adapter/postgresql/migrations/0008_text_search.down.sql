@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS jobseeker_search_document ON jobseeker;
+
+DROP FUNCTION IF EXISTS jobseeker_search_document();
+
+DROP INDEX IF EXISTS jobseeker_search_vector_idx;
+
+DROP INDEX IF EXISTS jobseeker_search_text_idx;
+
+ALTER TABLE jobseeker
+    DROP COLUMN IF EXISTS search_text,
+    DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,41 @@
+-- Users are searched by name and skills with full text search for whole words
+-- and trigram similarity for typos. The search document is kept by a trigger
+-- in lower case and without accents.
+
+-- the extensions go to public, so their functions are found wherever the
+-- tables are.
+CREATE EXTENSION IF NOT EXISTS unaccent SCHEMA public;
+CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public;
+
+ALTER TABLE jobseeker
+    ADD COLUMN IF NOT EXISTS search_text text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS search_vector tsvector NOT NULL DEFAULT ''::tsvector;
+
+CREATE OR REPLACE FUNCTION jobseeker_search_document() RETURNS trigger AS $$
+BEGIN
+    NEW.search_text := lower(unaccent(concat_ws(' ', NEW.firstname, NEW.lastname,
+        (SELECT string_agg(skill->>'name', ' ')
+           FROM jsonb_array_elements(CASE jsonb_typeof(NEW.skills) WHEN 'array' THEN NEW.skills ELSE '[]' END) AS skill))));
+    NEW.search_vector := to_tsvector('simple', NEW.search_text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS jobseeker_search_document ON jobseeker;
+
+CREATE TRIGGER jobseeker_search_document
+    BEFORE INSERT OR UPDATE OF firstname, lastname, skills ON jobseeker
+    FOR EACH ROW EXECUTE PROCEDURE jobseeker_search_document();
+
+-- users saved without skills were stored with a json null instead of an
+-- empty array.
+UPDATE jobseeker SET skills = '[]' WHERE jsonb_typeof(skills) = 'null';
+
+-- fills the search document of the existing users.
+UPDATE jobseeker SET firstname = firstname;
+
+CREATE INDEX IF NOT EXISTS jobseeker_search_vector_idx
+    ON jobseeker USING gin (search_vector);
+
+CREATE INDEX IF NOT EXISTS jobseeker_search_text_idx
+    ON jobseeker USING gin (search_text gin_trgm_ops);
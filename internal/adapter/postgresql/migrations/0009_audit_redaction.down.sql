@@ -0,0 +1,5 @@
+CREATE OR REPLACE FUNCTION jobseeker_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'jobseeker_audit is append only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,17 @@
+-- Purging a job seeker redacts the field values of their history, so the
+-- only change allowed on an audit entry is emptying its changes.
+
+CREATE OR REPLACE FUNCTION jobseeker_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND NEW.changes = '[]'::jsonb
+       AND NEW.id = OLD.id
+       AND NEW.jobseeker_id = OLD.jobseeker_id
+       AND NEW.operation = OLD.operation
+       AND NEW.actor = OLD.actor
+       AND NEW.changed_at = OLD.changed_at THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'jobseeker_audit is append only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,48 @@
+package postgresql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Option sets an optional setting of a repository.
+type Option func(*settings)
+
+// settings optional settings of the repositories.
+type settings struct {
+	tables             tableNames
+	timeouts           Timeouts
+	statementCacheSize int
+	isolation          sql.IsolationLevel
+}
+
+// Timeouts longest time every kind of operation can take, zero means the
+// operation is only bound by the deadline of its context.
+type Timeouts struct {
+	// Read reads of a single user, skill or history.
+	Read time.Duration
+	// Write changes of the stored data.
+	Write time.Duration
+	// Search searches of users and their counts and facets.
+	Search time.Duration
+}
+
+// WithTimeouts sets the timeouts of the operations of the repository.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *settings) {
+		s.timeouts = timeouts
+	}
+}
+
+func newSettings(options []Option) settings {
+	result := settings{
+		tables: tableNames{
+			users: defaultUsersTable,
+		},
+		statementCacheSize: DefaultStatementCacheSize,
+	}
+	for _, option := range options {
+		option(&result)
+	}
+	return result
+}
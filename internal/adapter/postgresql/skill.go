@@ -0,0 +1,141 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/skills"
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+const (
+	createSkillSQL  = "INSERT INTO {skills}(name,aliases) VALUES ($1, $2)"
+	updateSkillSQL  = "UPDATE {skills} SET aliases = $1 WHERE name = $2"
+	deleteSkillSQL  = "DELETE FROM {skills} WHERE name = $1"
+	selectSkillSQL  = "SELECT name, aliases FROM {skills} WHERE name = $1"
+	selectSkillsSQL = "SELECT name, aliases FROM {skills} ORDER BY name"
+)
+
+// SkillRDB is the repository handler for the skill catalog in a relational db.
+type SkillRDB struct {
+	storage *sql.DB
+	// tables replaces the table placeholders of the statements.
+	tables   *strings.Replacer
+	timeouts Timeouts
+}
+
+// NewSkillRepository creates a new skill repository that will use a rdb, the
+// options set the schema of the catalog and the timeouts of its operations.
+func NewSkillRepository(conn *sql.DB, options ...Option) *SkillRDB {
+	repositorySettings := newSettings(options)
+	return &SkillRDB{
+		storage:  conn,
+		tables:   repositorySettings.tables.replacer(),
+		timeouts: repositorySettings.timeouts,
+	}
+}
+
+// statement returns the given statement with the tables of the repository.
+func (s *SkillRDB) statement(statement string) string {
+	return s.tables.Replace(statement)
+}
+
+// FindByName look for the skill with the given canonical name.
+func (s *SkillRDB) FindByName(ctx context.Context, name string) (*repository.Skill, error) {
+	log.Println("level", "DEBUG", "msg", "reading skill", "method", "repository.SkillRDB.FindByName", "name", name)
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	var skill repository.Skill
+	err := queries(ctx, s.storage).QueryRowContext(ctx, s.statement(selectSkillSQL), name).Scan(&skill.Name, &skill.Aliases)
+	if err == sql.ErrNoRows {
+		return nil, skills.ErrSkillNotFound
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading skill", "method", "repository.SkillRDB.FindByName", "error", err)
+		return nil, unavailableError(ctx, "skill cannot be read in the database", err)
+	}
+	return &skill, nil
+}
+
+// FindAll returns the whole skill catalog ordered by name.
+func (s *SkillRDB) FindAll(ctx context.Context) ([]repository.Skill, error) {
+	log.Println("level", "DEBUG", "msg", "reading skill catalog", "method", "repository.SkillRDB.FindAll")
+	ctx, cancel := withTimeout(ctx, s.timeouts.Read)
+	defer cancel()
+	rows, err := queries(ctx, s.storage).QueryContext(ctx, s.statement(selectSkillsSQL))
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading skill catalog", "method", "repository.SkillRDB.FindAll", "error", err)
+		return nil, unavailableError(ctx, "skill catalog cannot be read in the database", err)
+	}
+	defer rows.Close()
+
+	catalog := make([]repository.Skill, 0)
+	for rows.Next() {
+		var skill repository.Skill
+		err := rows.Scan(&skill.Name, &skill.Aliases)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to scan rows", "method", "repository.SkillRDB.FindAll", "error", err)
+			return nil, unavailableError(ctx, "skill catalog cannot be read in the database", err)
+		}
+		catalog = append(catalog, skill)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("level", "ERROR", "msg", "something went wrong trying because rows results has an error", "method", "repository.SkillRDB.FindAll", "error", err)
+		return nil, unavailableError(ctx, "skill catalog cannot be read in the database", err)
+	}
+	return catalog, nil
+}
+
+// Save stores the given skill in the catalog.
+func (s *SkillRDB) Save(ctx context.Context, skill repository.Skill) error {
+	log.Println("level", "DEBUG", "msg", "storing skill", "method", "repository.SkillRDB.Save", "data", skill)
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	_, err := queries(ctx, s.storage).ExecContext(ctx, s.statement(createSkillSQL), skill.Name, skill.Aliases)
+	if isUniqueViolation(err) {
+		log.Println("level", "ERROR", "msg", "skill already exists", "method", "repository.SkillRDB.Save", "data", skill, "error", err)
+		return users.NewConflictError(skills.ErrorCodeSkillConflict, "skill already exists", err)
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "skill cannot be stored", "method", "repository.SkillRDB.Save", "data", skill, "error", err)
+		return unavailableError(ctx, "skill cannot be stored", err)
+	}
+	return nil
+}
+
+// Update replaces the aliases of the given skill.
+func (s *SkillRDB) Update(ctx context.Context, skill repository.Skill) error {
+	log.Println("level", "DEBUG", "msg", "updating skill", "method", "repository.SkillRDB.Update", "data", skill)
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.changeSkill(ctx, "repository.SkillRDB.Update", "skill cannot be updated", updateSkillSQL, skill.Aliases, skill.Name)
+}
+
+// Delete removes the skill with the given name from the catalog.
+func (s *SkillRDB) Delete(ctx context.Context, name string) error {
+	log.Println("level", "DEBUG", "msg", "deleting skill", "method", "repository.SkillRDB.Delete", "name", name)
+	ctx, cancel := withTimeout(ctx, s.timeouts.Write)
+	defer cancel()
+	return s.changeSkill(ctx, "repository.SkillRDB.Delete", "skill cannot be deleted", deleteSkillSQL, name)
+}
+
+// changeSkill executes the given statement and checks that one skill was affected.
+func (s *SkillRDB) changeSkill(ctx context.Context, method, failureMessage, statement string, args ...interface{}) error {
+	res, err := queries(ctx, s.storage).ExecContext(ctx, s.statement(statement), args...)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", failureMessage, "method", method, "error", err)
+		return unavailableError(ctx, failureMessage, err)
+	}
+	rowCnt, err := res.RowsAffected()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "got an error while trying to get how many rows where affected", "method", method, "error", err)
+		return unavailableError(ctx, failureMessage, err)
+	}
+	if rowCnt == 0 {
+		return skills.ErrSkillNotFound
+	}
+	return nil
+}
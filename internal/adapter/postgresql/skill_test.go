@@ -0,0 +1,86 @@
+package postgresql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fernandoocampo/users-micro/internal/adapter/postgresql"
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/skills"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveSkill(t *testing.T) {
+	ctx := context.TODO()
+	givenSkill := repository.Skill{
+		Name:    "Go",
+		Aliases: repository.Aliases{"golang"},
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO \"skill_catalog\"").
+		WithArgs(givenSkill.Name, givenSkill.Aliases).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	skillRepository := postgresql.NewSkillRepository(db)
+
+	// WHEN
+	saveError := skillRepository.Save(ctx, givenSkill)
+
+	assert.NoError(t, saveError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindAllSkills(t *testing.T) {
+	ctx := context.TODO()
+	expectedCatalog := []repository.Skill{
+		{Name: "Go", Aliases: repository.Aliases{"golang"}},
+		{Name: "PostgreSQL", Aliases: repository.Aliases{"postgres", "psql"}},
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "aliases"}).
+		AddRow("Go", []byte(`["golang"]`)).
+		AddRow("PostgreSQL", []byte(`["postgres","psql"]`))
+	mock.ExpectQuery("SELECT name, aliases FROM \"skill_catalog\" ORDER BY name").
+		WillReturnRows(rows)
+
+	skillRepository := postgresql.NewSkillRepository(db)
+
+	// WHEN
+	catalog, err := skillRepository.FindAll(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedCatalog, catalog)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteSkillNotFound(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM \"skill_catalog\"").
+		WithArgs("Go").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	skillRepository := postgresql.NewSkillRepository(db)
+
+	// WHEN
+	err = skillRepository.Delete(ctx, "Go")
+
+	assert.Equal(t, skills.ErrSkillNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,181 @@
+package postgresql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+)
+
+// DefaultStatementCacheSize number of dynamic statements a repository keeps
+// prepared if no other size is given.
+const DefaultStatementCacheSize = 100
+
+// WithStatementCacheSize sets the number of dynamic statements, the ones built
+// from a filter or a patch, the repository keeps prepared. Zero or less means
+// they are closed as soon as they are used.
+func WithStatementCacheSize(size int) Option {
+	return func(s *settings) {
+		s.statementCacheSize = size
+	}
+}
+
+// statementCache prepared statements of a repository. The fixed statements
+// are prepared when the cache is created and live as long as it. The dynamic
+// ones are prepared the first time they are used, and the least recently used
+// is closed when there are more than the capacity.
+type statementCache struct {
+	storage  *sql.DB
+	capacity int
+	// fixed statements by their SQL, they are only read after the cache is
+	// created.
+	fixed map[string]*sql.Stmt
+	// mu guards the dynamic statements.
+	mu sync.Mutex
+	// dynamic element of the recency list of every dynamic statement by its SQL.
+	dynamic map[string]*list.Element
+	// recency dynamic statements from the most recently used to the least.
+	recency *list.List
+	closed  bool
+}
+
+// cachedStatement dynamic statement kept in the recency list.
+type cachedStatement struct {
+	query string
+	stmt  *sql.Stmt
+	// users number of callers using the statement, an evicted statement is
+	// closed when the last one releases it.
+	users   int
+	evicted bool
+}
+
+// newStatementCache prepares the given fixed statements. If any of them
+// cannot be prepared the ones already prepared are closed.
+func newStatementCache(ctx context.Context, conn *sql.DB, capacity int, fixedStatements []string) (*statementCache, error) {
+	cache := statementCache{
+		storage:  conn,
+		capacity: capacity,
+		fixed:    make(map[string]*sql.Stmt, len(fixedStatements)),
+		dynamic:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+	for _, query := range fixedStatements {
+		stmt, err := conn.PrepareContext(ctx, query)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "statement cannot be prepared", "method", "repository.newStatementCache", "query", query, "error", err)
+			cache.close()
+			return nil, err
+		}
+		cache.fixed[query] = stmt
+	}
+	return &cache, nil
+}
+
+// prepare returns the prepared statement of the given query, bound to the
+// given transaction if there is one. The returned function must be called
+// when the statement is not used anymore. Queries out of the cache are
+// prepared and cached, unless there is a transaction: they are prepared on its
+// connection and closed when they are released.
+func (c *statementCache) prepare(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, func(), error) {
+	stmt, release := c.lookup(query)
+	if stmt != nil && tx == nil {
+		return stmt, release, nil
+	}
+	if stmt != nil {
+		txStmt := tx.StmtContext(ctx, stmt)
+		return txStmt, func() {
+			txStmt.Close()
+			release()
+		}, nil
+	}
+	if tx != nil {
+		txStmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return txStmt, func() { txStmt.Close() }, nil
+	}
+	// preparing takes a round trip, the lock is not held meanwhile.
+	stmt, err := c.storage.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.dynamic[query]; ok || c.closed || c.capacity <= 0 {
+		// another caller cached the same query meanwhile, or there is no cache.
+		if ok {
+			c.recency.MoveToFront(element)
+		}
+		return stmt, func() { stmt.Close() }, nil
+	}
+	cached := &cachedStatement{query: query, stmt: stmt, users: 1}
+	c.dynamic[query] = c.recency.PushFront(cached)
+	for c.recency.Len() > c.capacity {
+		c.evict()
+	}
+	return stmt, c.releaser(cached), nil
+}
+
+// lookup returns the cached statement of the given query and the function
+// that releases it, nil if it is not cached.
+func (c *statementCache) lookup(query string) (*sql.Stmt, func()) {
+	if stmt, ok := c.fixed[query]; ok {
+		return stmt, func() {}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.dynamic[query]
+	if !ok {
+		return nil, nil
+	}
+	c.recency.MoveToFront(element)
+	cached := element.Value.(*cachedStatement)
+	cached.users++
+	return cached.stmt, c.releaser(cached)
+}
+
+// releaser returns the function that releases the given cached statement.
+func (c *statementCache) releaser(cached *cachedStatement) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		cached.users--
+		if cached.evicted && cached.users == 0 {
+			cached.stmt.Close()
+		}
+	}
+}
+
+// evict removes the least recently used dynamic statement, it is closed now
+// if nobody uses it. The caller must hold the lock.
+func (c *statementCache) evict() {
+	element := c.recency.Back()
+	if element == nil {
+		return
+	}
+	cached := c.recency.Remove(element).(*cachedStatement)
+	delete(c.dynamic, cached.query)
+	cached.evicted = true
+	if cached.users == 0 {
+		cached.stmt.Close()
+	}
+}
+
+// close closes every statement of the cache, dynamic statements in use are
+// closed when they are released.
+func (c *statementCache) close() {
+	for _, stmt := range c.fixed {
+		err := stmt.Close()
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "statement cannot be closed", "method", "repository.statementCache.close", "error", err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for c.recency.Len() > 0 {
+		c.evict()
+	}
+}
@@ -0,0 +1,89 @@
+package postgresql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fernandoocampo/users-micro/internal/adapter/postgresql"
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchUsersReusesPreparedStatements(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	queryStatement := mock.ExpectPrepare(`SELECT (.+), COUNT\(\*\) OVER\(\) FROM "jobseeker" WHERE deleted_at IS NULL ORDER BY id ASC LIMIT \$1 OFFSET \$2`)
+	queryStatement.ExpectQuery().
+		WithArgs(11, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	queryStatement.ExpectQuery().
+		WithArgs(11, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	_, firstError := userRepository.SearchWithFilters(ctx, givenFilter)
+	_, secondError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, firstError)
+	assert.NoError(t, secondError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvictLeastRecentlyUsedStatement(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock, postgresql.WithStatementCacheSize(1))
+
+	// the query of the first search is evicted when the one of the second
+	// search is cached.
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" WHERE deleted_at IS NULL ORDER BY id ASC LIMIT \$1 OFFSET \$2`).
+		WillBeClosed().
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" WHERE city = \$1 AND deleted_at IS NULL ORDER BY id ASC LIMIT \$2 OFFSET \$3`).
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// WHEN
+	_, firstError := userRepository.SearchWithFilters(ctx, repository.UserFilter{Page: 1, RowsPerPage: 10})
+	_, secondError := userRepository.SearchWithFilters(ctx, repository.UserFilter{City: "Cali", Page: 1, RowsPerPage: 10})
+
+	assert.NoError(t, firstError)
+	assert.NoError(t, secondError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloseUserRepository(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	for _, statement := range fixedUserStatements {
+		mock.ExpectPrepare(statement).WillBeClosed()
+	}
+	userRepository, err := postgresql.NewUserRepository(db)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the user repository", err)
+	}
+
+	// WHEN
+	userRepository.Close()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,69 @@
+package postgresql
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Placeholders of the tables in the SQL statements, they are replaced by the
+// quoted table names of the repository.
+const (
+	usersTablePlaceholder      = "{users}"
+	auditTablePlaceholder      = "{audit}"
+	skillsTablePlaceholder     = "{skills}"
+	migrationsTablePlaceholder = "{migrations}"
+)
+
+// Default table names.
+const (
+	defaultUsersTable  = "jobseeker"
+	defaultSkillsTable = "skill_catalog"
+	migrationsTable    = "schema_migrations"
+	// auditTableSuffix the audit table of the users is named after the users table.
+	auditTableSuffix = "_audit"
+)
+
+// tableNames names of the tables of the repositories.
+type tableNames struct {
+	schema string
+	users  string
+}
+
+// WithSchema sets the schema of the tables, the tables are looked up in the
+// search_path of the connection if it is empty.
+func WithSchema(schema string) Option {
+	return func(s *settings) {
+		s.tables.schema = schema
+	}
+}
+
+// WithTable sets the table of the users, jobseeker by default. Its history is
+// kept in the table with the same name plus _audit. The migrations only
+// create the default tables, so any other table must be created by hand.
+func WithTable(table string) Option {
+	return func(s *settings) {
+		if table != "" {
+			s.tables.users = table
+		}
+	}
+}
+
+// qualified returns the quoted name of the given table, qualified by the
+// schema if there is one.
+func (t tableNames) qualified(table string) string {
+	if t.schema == "" {
+		return pq.QuoteIdentifier(table)
+	}
+	return pq.QuoteIdentifier(t.schema) + "." + pq.QuoteIdentifier(table)
+}
+
+// replacer returns the replacer of the table placeholders.
+func (t tableNames) replacer() *strings.Replacer {
+	return strings.NewReplacer(
+		usersTablePlaceholder, t.qualified(t.users),
+		auditTablePlaceholder, t.qualified(t.users+auditTableSuffix),
+		skillsTablePlaceholder, t.qualified(defaultSkillsTable),
+		migrationsTablePlaceholder, t.qualified(migrationsTable),
+	)
+}
@@ -0,0 +1,28 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+// withTimeout returns a copy of the given context that is done after the
+// given timeout, or the context itself if there is no timeout.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// unavailableError wraps an error of the database. Errors caused by the
+// deadline of the given context are reported as timeouts, the database may
+// be fine but it didn't answer in time.
+func unavailableError(ctx context.Context, message string, cause error) error {
+	if errors.Is(cause, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return users.NewTimeoutError(users.ErrorCodeDeadlineExceeded, message, cause)
+	}
+	return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, message, cause)
+}
@@ -0,0 +1,110 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// isolationLevels isolation levels by the name postgresql gives them.
+var isolationLevels = map[string]sql.IsolationLevel{
+	"":                 sql.LevelDefault,
+	"default":          sql.LevelDefault,
+	"read uncommitted": sql.LevelReadUncommitted,
+	"read committed":   sql.LevelReadCommitted,
+	"repeatable read":  sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+// ParseIsolation returns the isolation level with the given name, e.g.
+// "read committed" or "serializable". An empty name is the default level of
+// the database.
+func ParseIsolation(name string) (sql.IsolationLevel, error) {
+	level, ok := isolationLevels[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return sql.LevelDefault, fmt.Errorf("unknown transaction isolation level %q", name)
+	}
+	return level, nil
+}
+
+// WithIsolation sets the isolation level of the transactions the repository
+// opens when the context doesn't carry one, the default of the database if
+// it is not set.
+func WithIsolation(isolation sql.IsolationLevel) Option {
+	return func(s *settings) {
+		s.isolation = isolation
+	}
+}
+
+// txKey context key for the transaction the repositories join.
+type txKey struct{}
+
+// querier statements the repositories run, on the database or on the
+// transaction of the context.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Transactor opens the transactions the repositories join through the context.
+type Transactor struct {
+	storage   *sql.DB
+	isolation sql.IsolationLevel
+}
+
+// NewTransactor creates a transactor that opens transactions with the given
+// isolation level.
+func NewTransactor(conn *sql.DB, isolation sql.IsolationLevel) *Transactor {
+	return &Transactor{
+		storage:   conn,
+		isolation: isolation,
+	}
+}
+
+// WithinTransaction calls fn with a context that carries a new transaction,
+// the transaction is committed only if fn doesn't fail. If the given context
+// already carries a transaction fn joins it, and the outermost call commits.
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if transactionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	tx, err := t.storage.BeginTx(ctx, &sql.TxOptions{Isolation: t.isolation})
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "transaction cannot be started", "method", "repository.Transactor.WithinTransaction", "error", err)
+		return unavailableError(ctx, "transaction cannot be started", err)
+	}
+	err = fn(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Println("level", "ERROR", "msg", "transaction cannot be rolled back", "method", "repository.Transactor.WithinTransaction", "error", rollbackErr)
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "transaction cannot be committed", "method", "repository.Transactor.WithinTransaction", "error", err)
+		return unavailableError(ctx, "transaction cannot be committed", err)
+	}
+	return nil
+}
+
+// transactionFromContext returns the transaction carried by the given
+// context, nil if there is none.
+func transactionFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// queries returns the transaction of the given context, or the given database
+// if there is none.
+func queries(ctx context.Context, db *sql.DB) querier {
+	if tx := transactionFromContext(ctx); tx != nil {
+		return tx
+	}
+	return db
+}
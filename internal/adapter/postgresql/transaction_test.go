@@ -0,0 +1,100 @@
+package postgresql_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fernandoocampo/users-micro/internal/adapter/postgresql"
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndDeleteUserInTransaction(t *testing.T) {
+	givenUser := repository.User{
+		ID:        "123",
+		FirstName: "Alonso",
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO \"jobseeker\"").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE \"jobseeker\" SET deleted_at").
+		WithArgs("123", stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("123", repository.AuditDelete, stampedBy, stampedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	transactor := postgresql.NewTransactor(db, sql.LevelSerializable)
+
+	// WHEN
+	txError := transactor.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		err := userRepository.Save(ctx, givenUser)
+		if err != nil {
+			return err
+		}
+		return userRepository.Delete(ctx, stateChange(givenUser.ID))
+	})
+
+	assert.NoError(t, txError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRollbackTransactionWhenUnitOfWorkFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"jobseeker\" SET deleted_at").
+		WithArgs("123", stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE \"jobseeker\" SET deleted_at").
+		WithArgs("456", stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	transactor := postgresql.NewTransactor(db, sql.LevelDefault)
+
+	// WHEN
+	txError := transactor.WithinTransaction(context.TODO(), func(ctx context.Context) error {
+		err := userRepository.Delete(ctx, stateChange("123"))
+		if err != nil {
+			return err
+		}
+		return userRepository.Delete(ctx, stateChange("456"))
+	})
+
+	assert.Equal(t, users.ErrUserNotFound, txError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseIsolation(t *testing.T) {
+	level, err := postgresql.ParseIsolation("Repeatable Read")
+	assert.NoError(t, err)
+	assert.Equal(t, sql.LevelRepeatableRead, level)
+
+	_, err = postgresql.ParseIsolation("snapshot")
+	assert.Error(t, err)
+}
@@ -3,180 +3,656 @@ package postgresql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/lib/pq"
 )
 
 const (
-	createUserSQL     = "INSERT INTO jobseeker(id,firstname,lastname,city,skills) VALUES ($1, $2, $3, $4, $5)"
-	updateUserSQL     = "UPDATE jobseeker SET firstname = $1,lastname = $2, city = $3, skills = $4 WHERE id = $5"
-	selectByIDSQL     = "SELECT id, firstname, lastname, city, skills FROM jobseeker WHERE id = $1"
-	selectByFilterSQL = "SELECT id, firstname, lastname, city, skills FROM jobseeker %s;"
-	countByFilterSQL  = "SELECT COUNT(id) FROM jobseeker %s;"
+	createUserSQL      = "INSERT INTO {users}(id,firstname,lastname,city,skills,created_at,created_by,updated_at,updated_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+	updateUserSQL      = "UPDATE {users} SET firstname = $1,lastname = $2, city = $3, skills = $4, updated_at = $5, updated_by = $6, version = version + 1 WHERE id = $7 AND version = $8 AND deleted_at IS NULL"
+	selectByIDSQL      = "SELECT id, firstname, lastname, city, skills, version, created_at, created_by, updated_at, updated_by FROM {users} WHERE id = $1 AND deleted_at IS NULL"
+	selectForUpdateSQL = "SELECT id, firstname, lastname, city, skills, version, created_at, created_by, updated_at, updated_by FROM {users} WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+	selectByFilterSQL  = "SELECT id, firstname, lastname, city, skills, version, created_at, created_by, updated_at, updated_by%s FROM {users} %s;"
+	declareExportSQL   = "DECLARE user_export NO SCROLL CURSOR FOR %s"
+	fetchExportSQL     = "FETCH %d FROM user_export"
+	closeExportSQL     = "CLOSE user_export"
+	countByFilterSQL   = "SELECT COUNT(id) FROM {users} %s;"
+	windowTotalColumn  = ", COUNT(*) OVER()"
+	seekTotalColumn    = ", (SELECT COUNT(id) FROM {users} %s)"
+	estimateTotalSQL   = "EXPLAIN (FORMAT JSON) SELECT id FROM {users} %s"
+	skillsFacetSQL     = "SELECT skill->>'name', COUNT(DISTINCT id) FROM {users} CROSS JOIN LATERAL jsonb_array_elements(CASE jsonb_typeof(skills) WHEN 'array' THEN skills ELSE '[]' END) AS skill %s GROUP BY 1 HAVING COALESCE(skill->>'name', '') <> '' ORDER BY 2 DESC, 1 LIMIT %d;"
+	cityFacetSQL       = "SELECT city, COUNT(id) FROM {users} %s GROUP BY 1 HAVING COALESCE(city, '') <> '' ORDER BY 2 DESC, 1 LIMIT %d;"
+	patchUserSQL       = "UPDATE {users} SET %s, version = version + 1 WHERE id = $%d AND version = $%d AND deleted_at IS NULL"
+	deleteUserSQL      = "UPDATE {users} SET deleted_at = $2, updated_at = $2, updated_by = $3 WHERE id = $1 AND deleted_at IS NULL"
+	restoreUserSQL     = "UPDATE {users} SET deleted_at = NULL, updated_at = $2, updated_by = $3 WHERE id = $1 AND deleted_at IS NOT NULL"
+	purgeUserSQL       = "DELETE FROM {users} WHERE id = $1"
+	savepointSQL       = "SAVEPOINT save_user"
+	rollbackToSQL      = "ROLLBACK TO SAVEPOINT save_user"
+	releaseSQL         = "RELEASE SAVEPOINT save_user"
+	insertAuditSQL     = "INSERT INTO {audit}(jobseeker_id,operation,actor,changed_at,changes) VALUES ($1, $2, $3, $4, $5)"
+	redactAuditSQL     = "UPDATE {audit} SET changes = '[]' WHERE jobseeker_id = $1 AND changes <> '[]'"
+	countHistorySQL    = "SELECT COUNT(id) FROM {audit} WHERE jobseeker_id = $1"
+	selectHistorySQL   = "SELECT jobseeker_id, operation, actor, changed_at, changes FROM {audit} WHERE jobseeker_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3"
 )
 
+// fixedUserStatements statements that don't depend on the request, they are
+// prepared when the repository is created.
+var fixedUserStatements = []string{
+	createUserSQL,
+	updateUserSQL,
+	selectByIDSQL,
+	selectForUpdateSQL,
+	deleteUserSQL,
+	restoreUserSQL,
+	purgeUserSQL,
+	insertAuditSQL,
+	redactAuditSQL,
+	countHistorySQL,
+	selectHistorySQL,
+}
+
 // Columns
 const (
-	cityColumn   = "city"
-	skillsColumn = "skills"
+	firstNameColumn = "firstname"
+	lastNameColumn  = "lastname"
+	cityColumn      = "city"
+	skillsColumn    = "skills"
+	updatedAtColumn = "updated_at"
+	updatedByColumn = "updated_by"
 )
 
+// Conditions
 const (
-	equalsOperator = "="
-	bsonInOperator = "@>"
-	whereOperator  = "WHERE"
-	andOperator    = "AND"
+	notDeletedCondition = "deleted_at IS NULL"
+	// skills are objects, so ?| cannot look for them by name, every skill is
+	// matched with @> against a single element array instead.
+	anySkillCondition  = "skills @> ANY($%d::jsonb[])"
+	noneSkillCondition = "NOT COALESCE(skills @> ANY($%d::jsonb[]), false)"
+	// textSearchCondition matches users whose search document has every word
+	// of the text, or words similar enough to them to tolerate typos. The
+	// search document is kept by a trigger without accents and in lower case.
+	textSearchCondition = "(search_vector @@ plainto_tsquery('simple', lower(unaccent($%[1]d))) OR lower(unaccent($%[1]d)) <%% search_text)"
+	// skillRequirementCondition matches users who have the skill with one of
+	// the accepted levels, any level if none is given, and at least the given
+	// years of experience.
+	skillRequirementCondition = "EXISTS (SELECT 1 FROM jsonb_array_elements(CASE jsonb_typeof(skills) WHEN 'array' THEN skills ELSE '[]' END) AS skill WHERE skill->>'name' = $%d AND (cardinality($%[2]d::text[]) = 0 OR skill->>'level' = ANY($%[2]d::text[])) AND COALESCE((skill->>'years')::int, 0) >= $%[3]d)"
+)
+
+// facetStatements statements to count the users found by every facet, any
+// other facet is ignored.
+var facetStatements = map[string]string{
+	repository.FacetSkills: skillsFacetSQL,
+	repository.FacetCity:   cityFacetSQL,
+}
+
+// relevanceExpression ranks the users found by textSearchCondition, exact
+// words weigh more than similar ones.
+const relevanceExpression = "ts_rank(search_vector, plainto_tsquery('simple', lower(unaccent($%[1]d)))) + word_similarity(lower(unaccent($%[1]d)), search_text)"
+
+// sortColumns columns of the sortable user fields, any other field is ignored.
+var sortColumns = map[string]string{
+	repository.SortByID:        "id",
+	repository.SortByFirstName: firstNameColumn,
+	repository.SortByLastName:  lastNameColumn,
+	repository.SortByCity:      cityColumn,
+	repository.SortByCreatedAt: "created_at",
+	repository.SortByUpdatedAt: updatedAtColumn,
+}
+
+// exportFetchSize number of users read at once from the export cursor.
+const exportFetchSize = 500
+
+// uniqueViolationCode postgresql error code for unique constraint violations.
+const uniqueViolationCode = "23505"
+
+const (
+	equalsOperator         = "="
+	greaterOrEqualOperator = ">="
+	bsonInOperator         = "@>"
+	whereOperator          = "WHERE"
+	andOperator            = "AND"
 )
 
 type filterBuilder struct {
 	queryStatement string
 	countStatement string
-	filters        []string
-	queryArgs      []interface{}
-	countArgs      []interface{}
+	// estimateStatement explains the count of the users found, the planner
+	// estimates how many they are.
+	estimateStatement string
+	// withTotal true if every row of the query carries the total of the users
+	// found in its last column.
+	withTotal bool
+	// whereClause conditions of the users found, without pagination.
+	whereClause string
+	filters     []string
+	queryArgs   []interface{}
+	countArgs   []interface{}
+	// relevance expression to sort by relevance, empty if there is no text
+	// to search.
+	relevance string
 }
 
 // UserRDB is the repository handler for users in a relational db.
 type UserRDB struct {
 	storage *sql.DB
+	// tables replaces the table placeholders of the statements.
+	tables   *strings.Replacer
+	timeouts Timeouts
+	// isolation isolation level of the transactions the repository opens.
+	isolation sql.IsolationLevel
+	// statements prepared statements of the repository.
+	statements *statementCache
 }
 
-// NewUserRepository creates a new user repository that will use a rdb.
-func NewUserRepository(conn *sql.DB) *UserRDB {
+// NewUserRepository creates a new user repository that will use a rdb, the
+// options set the schema and table of the users, the timeouts of the
+// operations, the isolation level of its transactions and how many dynamic
+// statements are kept prepared. The fixed statements are prepared right away,
+// call Close to release them.
+func NewUserRepository(conn *sql.DB, options ...Option) (*UserRDB, error) {
+	repositorySettings := newSettings(options)
 	newUser := UserRDB{
-		storage: conn,
+		storage:   conn,
+		tables:    repositorySettings.tables.replacer(),
+		timeouts:  repositorySettings.timeouts,
+		isolation: repositorySettings.isolation,
+	}
+	fixedStatements := make([]string, 0, len(fixedUserStatements))
+	for _, v := range fixedUserStatements {
+		fixedStatements = append(fixedStatements, newUser.statement(v))
 	}
-	return &newUser
+	ctx, cancel := withTimeout(context.Background(), repositorySettings.timeouts.Write)
+	defer cancel()
+	statements, err := newStatementCache(ctx, conn, repositorySettings.statementCacheSize, fixedStatements)
+	if err != nil {
+		return nil, unavailableError(ctx, "user statements cannot be prepared", err)
+	}
+	newUser.statements = statements
+	return &newUser, nil
+}
+
+// Close closes the prepared statements of the repository.
+func (u *UserRDB) Close() {
+	u.statements.close()
+}
+
+// prepared returns the prepared statement of the given query, bound to the
+// given transaction if there is one. The returned function must be called
+// when the statement is not used anymore.
+func (u *UserRDB) prepared(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, func(), error) {
+	return u.statements.prepare(ctx, tx, query)
+}
+
+// statement returns the given statement with the tables of the repository.
+func (u *UserRDB) statement(statement string) string {
+	return u.tables.Replace(statement)
 }
 
-// Save save the given user in the postgresql database.
+// Save save the given user in the postgresql database, the creation is
+// audited in the same transaction.
 func (u *UserRDB) Save(ctx context.Context, user repository.User) error {
 	log.Println("level", "DEBUG", "msg", "storing user", "method", "repository.UserRDB.Save", "data", user)
-	stmt, err := u.storage.Prepare(createUserSQL)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	return u.withTransaction(ctx, "repository.UserRDB.Save", "user cannot be stored", func(tx *sql.Tx) error {
+		stmt, release, err := u.prepared(ctx, tx, u.statement(createUserSQL))
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "user cannot be stored", "method", "repository.UserRDB.Save", "data", user, "error", err)
+			return unavailableError(ctx, "user cannot be stored", err)
+		}
+		defer release()
+		res, err := stmt.ExecContext(ctx, user.ID, user.FirstName, user.LastName, user.City, user.Skills, user.CreatedAt, user.CreatedBy, user.UpdatedAt, user.UpdatedBy)
+		if isUniqueViolation(err) {
+			log.Println("level", "ERROR", "msg", "user already exists", "method", "repository.UserRDB.Save", "data", user, "error", err)
+			return users.NewConflictError(users.ErrorCodeUserAlreadyExists, "user already exists", err)
+		}
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "got an error while executing insert to store user",
+				"method", "repository.UserRDB.Save",
+				"data", user,
+				"error", err,
+			)
+			return unavailableError(ctx, "user cannot be stored", err)
+		}
+		rowCnt, err := res.RowsAffected()
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "got an error while trying to get how many rows where affected",
+				"method", "repository.UserRDB.Save",
+				"data", user,
+				"error", err,
+			)
+			return unavailableError(ctx, "cannot get how many records were affected, please check if user was inserted", err)
+		}
+		log.Println("level", "INFO", "msg", "rows affected when storing user", "method", "repository.UserRDB.Save", "count", rowCnt)
+		return u.insertAudit(ctx, tx, repository.NewCreateAuditEntry(user))
+	})
+}
+
+// SaveAll saves the given users in a single transaction, with their creation
+// audit. Every user is saved in its own savepoint, so a user that cannot be
+// saved is reported in its position and the others are still saved.
+func (u *UserRDB) SaveAll(ctx context.Context, newUsers []repository.User) ([]error, error) {
+	log.Println("level", "DEBUG", "msg", "storing users", "method", "repository.UserRDB.SaveAll", "count", len(newUsers))
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	userErrs := make([]error, len(newUsers))
+	err := u.withTransaction(ctx, "repository.UserRDB.SaveAll", "users cannot be stored", func(tx *sql.Tx) error {
+		stmt, release, err := u.prepared(ctx, tx, u.statement(createUserSQL))
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "users cannot be stored", "method", "repository.UserRDB.SaveAll", "error", err)
+			return unavailableError(ctx, "users cannot be stored", err)
+		}
+		defer release()
+		saved := 0
+		for i, user := range newUsers {
+			_, err = tx.ExecContext(ctx, savepointSQL)
+			if err != nil {
+				log.Println("level", "ERROR", "msg", "savepoint cannot be created", "method", "repository.UserRDB.SaveAll", "error", err)
+				return unavailableError(ctx, "users cannot be stored", err)
+			}
+			userErrs[i] = u.saveInBatch(ctx, tx, stmt, user)
+			savepointStatement := releaseSQL
+			if userErrs[i] != nil {
+				savepointStatement = rollbackToSQL
+			}
+			_, err = tx.ExecContext(ctx, savepointStatement)
+			if err != nil {
+				log.Println("level", "ERROR", "msg", "savepoint cannot be closed", "method", "repository.UserRDB.SaveAll", "error", err)
+				return unavailableError(ctx, "users cannot be stored", err)
+			}
+			if userErrs[i] == nil {
+				saved++
+			}
+		}
+		log.Println("level", "INFO", "msg", "users were stored", "method", "repository.UserRDB.SaveAll", "count", saved, "failed", len(newUsers)-saved)
+		return nil
+	})
 	if err != nil {
-		log.Println("level", "ERROR", "msg", "user cannot be stored", "method", "repository.UserRDB.Save", "data", user, "error", err)
-		return errors.New("user cannot be stored")
+		return nil, err
 	}
-	res, err := stmt.Exec(user.ID, user.FirstName, user.LastName, user.City, user.Skills)
-	if err != nil {
-		log.Println(
-			"level", "ERROR",
-			"msg", "got an error while executing insert to store user",
-			"method", "repository.UserRDB.Save",
-			"data", user,
-			"error", err,
-		)
-		return errors.New("user cannot be stored")
+	return userErrs, nil
+}
+
+// saveInBatch inserts the given user with the given statement and audits its
+// creation.
+func (u *UserRDB) saveInBatch(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, user repository.User) error {
+	_, err := stmt.ExecContext(ctx, user.ID, user.FirstName, user.LastName, user.City, user.Skills, user.CreatedAt, user.CreatedBy, user.UpdatedAt, user.UpdatedBy)
+	if isUniqueViolation(err) {
+		log.Println("level", "ERROR", "msg", "user already exists", "method", "repository.UserRDB.SaveAll", "data", user, "error", err)
+		return users.NewConflictError(users.ErrorCodeUserAlreadyExists, "user already exists", err)
 	}
-	rowCnt, err := res.RowsAffected()
 	if err != nil {
 		log.Println(
 			"level", "ERROR",
-			"msg", "got an error while trying to get how many rows where affected",
-			"method", "repository.UserRDB.Save",
+			"msg", "got an error while executing insert to store users",
+			"method", "repository.UserRDB.SaveAll",
 			"data", user,
 			"error", err,
 		)
-		return errors.New("cannot get how many records were affected, please check if user was inserted")
+		return unavailableError(ctx, "user cannot be stored", err)
 	}
-	log.Println("level", "INFO", "msg", "rows affected when storing user", "method", "repository.UserRDB.Save", "count", rowCnt)
-	return nil
+	return u.insertAudit(ctx, tx, repository.NewCreateAuditEntry(user))
 }
 
 // FindByID look for an user with the given id
 func (u *UserRDB) FindByID(ctx context.Context, userID string) (*repository.User, error) {
 	log.Println("level", "DEBUG", "msg", "reading user", "method", "repository.UserRDB.FindByID", "user id", userID)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Read)
+	defer cancel()
 	var user repository.User
-	err := u.storage.QueryRow(selectByIDSQL, userID).
-		Scan(&user.ID, &user.FirstName, &user.LastName, &user.City, &user.Skills)
-	if err != nil && err != sql.ErrNoRows {
+	stmt, release, err := u.prepared(ctx, transactionFromContext(ctx), u.statement(selectByIDSQL))
+	if err != nil {
 		log.Println("level", "ERROR", "msg", "reading user", "method", "repository.UserRDB.FindByID", "error", err)
-		return nil, errors.New("user cannot be read in the database")
+		return nil, unavailableError(ctx, "user cannot be read in the database", err)
 	}
-	if user.ID == "" {
-		return nil, nil
+	defer release()
+	err = scanUser(stmt.QueryRowContext(ctx, userID), &user)
+	if err == sql.ErrNoRows {
+		return nil, users.ErrUserNotFound
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading user", "method", "repository.UserRDB.FindByID", "error", err)
+		return nil, unavailableError(ctx, "user cannot be read in the database", err)
 	}
 	return &user, nil
 }
 
-// Update update the given user in the postgresql database.
+// Update update the given user in the postgresql database, the change is
+// audited in the same transaction.
 func (u *UserRDB) Update(ctx context.Context, user repository.User) error {
 	log.Println("level", "DEBUG", "msg", "updating user", "method", "repository.UserRDB.Update", "data", user)
-	stmt, err := u.storage.Prepare(updateUserSQL)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	return u.withTransaction(ctx, "repository.UserRDB.Update", "user cannot be updated", func(tx *sql.Tx) error {
+		current, err := u.findForUpdate(ctx, tx, user.ID, user.Version)
+		if err != nil {
+			return err
+		}
+		stmt, release, err := u.prepared(ctx, tx, u.statement(updateUserSQL))
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "user cannot be updated", "method", "repository.UserRDB.Update", "data", user, "error", err)
+			return unavailableError(ctx, "user cannot be updated", err)
+		}
+		defer release()
+		res, err := stmt.ExecContext(ctx, user.FirstName, user.LastName, user.City, user.Skills, user.UpdatedAt, user.UpdatedBy, user.ID, user.Version)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "got an error while executing update to update user",
+				"method", "repository.UserRDB.Update",
+				"data", user,
+				"error", err,
+			)
+			return unavailableError(ctx, "user cannot be updated", err)
+		}
+		rowCnt, err := res.RowsAffected()
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "got an error while trying to get how many rows where affected",
+				"method", "repository.UserRDB.Updated",
+				"data", user,
+				"error", err,
+			)
+			return unavailableError(ctx, "cannot get how many records were affected, please check if user was updated", err)
+		}
+		if rowCnt == 0 {
+			return users.ErrVersionConflict
+		}
+		log.Println("level", "INFO", "msg", "rows affected when updating a user", "method", "repository.UserRDB.Update", "count", rowCnt)
+		return u.insertAudit(ctx, tx, repository.NewUpdateAuditEntry(repository.AuditUpdate, *current, user))
+	})
+}
+
+// Patch writes only the changed columns of the given user, the change is
+// audited in the same transaction.
+func (u *UserRDB) Patch(ctx context.Context, changes repository.UserPatch) error {
+	log.Println("level", "DEBUG", "msg", "patching user", "method", "repository.UserRDB.Patch", "user id", changes.ID)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	if changes.IsEmpty() {
+		return nil
+	}
+	return u.withTransaction(ctx, "repository.UserRDB.Patch", "user cannot be patched", func(tx *sql.Tx) error {
+		current, err := u.findForUpdate(ctx, tx, changes.ID, changes.Version)
+		if err != nil {
+			return err
+		}
+		statement, args := buildPatchStatement(changes)
+		statement = u.statement(statement)
+		stmt, release, err := u.prepared(ctx, tx, statement)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "user cannot be patched", "method", "repository.UserRDB.Patch", "query", statement, "error", err)
+			return unavailableError(ctx, "user cannot be patched", err)
+		}
+		defer release()
+		res, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "got an error while executing update to patch user",
+				"method", "repository.UserRDB.Patch",
+				"query", statement,
+				"error", err,
+			)
+			return unavailableError(ctx, "user cannot be patched", err)
+		}
+		rowCnt, err := res.RowsAffected()
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "got an error while trying to get how many rows where affected",
+				"method", "repository.UserRDB.Patch",
+				"error", err,
+			)
+			return unavailableError(ctx, "cannot get how many records were affected, please check if user was patched", err)
+		}
+		if rowCnt == 0 {
+			return users.ErrVersionConflict
+		}
+		log.Println("level", "INFO", "msg", "rows affected when patching a user", "method", "repository.UserRDB.Patch", "count", rowCnt)
+		return u.insertAudit(ctx, tx, repository.NewUpdateAuditEntry(repository.AuditPatch, *current, changes.Apply(*current)))
+	})
+}
+
+// findForUpdate reads and locks the user with the given id until the end of
+// the transaction, the user must have the expected version.
+func (u *UserRDB) findForUpdate(ctx context.Context, tx *sql.Tx, userID string, expectedVersion int) (*repository.User, error) {
+	var current repository.User
+	stmt, release, err := u.prepared(ctx, tx, u.statement(selectForUpdateSQL))
 	if err != nil {
-		log.Println("level", "ERROR", "msg", "user cannot be updated", "method", "repository.UserRDB.Update", "data", user, "error", err)
-		return errors.New("user cannot be updated")
+		log.Println("level", "ERROR", "msg", "reading user to change", "method", "repository.UserRDB.findForUpdate", "user id", userID, "error", err)
+		return nil, unavailableError(ctx, "user cannot be read in the database", err)
+	}
+	defer release()
+	err = scanUser(stmt.QueryRowContext(ctx, userID), &current)
+	if err == sql.ErrNoRows {
+		return nil, users.ErrUserNotFound
 	}
-	res, err := stmt.Exec(user.FirstName, user.LastName, user.City, user.Skills, user.ID)
 	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading user to change", "method", "repository.UserRDB.findForUpdate", "user id", userID, "error", err)
+		return nil, unavailableError(ctx, "user cannot be read in the database", err)
+	}
+	if current.Version != expectedVersion {
 		log.Println(
 			"level", "ERROR",
-			"msg", "got an error while executing update to update user",
-			"method", "repository.UserRDB.Update",
-			"data", user,
+			"msg", "user version doesn't match",
+			"method", "repository.UserRDB.findForUpdate",
+			"user id", userID,
+			"expected", expectedVersion,
+			"current", current.Version,
+		)
+		return nil, users.ErrVersionConflict
+	}
+	return &current, nil
+}
+
+// insertAudit appends the given entry to the audit history.
+func (u *UserRDB) insertAudit(ctx context.Context, tx *sql.Tx, entry repository.AuditEntry) error {
+	stmt, release, err := u.prepared(ctx, tx, u.statement(insertAuditSQL))
+	if err == nil {
+		defer release()
+		_, err = stmt.ExecContext(ctx, entry.UserID, entry.Operation, entry.Actor, entry.ChangedAt, entry.Changes)
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "audit entry cannot be stored", "method", "repository.UserRDB.insertAudit", "user id", entry.UserID, "error", err)
+		return unavailableError(ctx, "user change cannot be audited", err)
+	}
+	return nil
+}
+
+// withTransaction runs the given function in a transaction, the transaction
+// is committed only if the function doesn't fail. If the context carries a
+// transaction the function joins it, and its owner commits or rolls it back.
+func (u *UserRDB) withTransaction(ctx context.Context, method, failureMessage string, fn func(tx *sql.Tx) error) error {
+	if tx := transactionFromContext(ctx); tx != nil {
+		return fn(tx)
+	}
+	tx, err := u.storage.BeginTx(ctx, &sql.TxOptions{Isolation: u.isolation})
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "transaction cannot be started", "method", method, "error", err)
+		return unavailableError(ctx, failureMessage, err)
+	}
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Println("level", "ERROR", "msg", "transaction cannot be rolled back", "method", method, "error", rollbackErr)
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "transaction cannot be committed", "method", method, "error", err)
+		return unavailableError(ctx, failureMessage, err)
+	}
+	return nil
+}
+
+// buildPatchStatement builds an update statement that only sets the changed columns.
+func buildPatchStatement(changes repository.UserPatch) (string, []interface{}) {
+	assignments := make([]string, 0, 6)
+	args := make([]interface{}, 0, 8)
+	addAssignment := func(column string, value interface{}) {
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if changes.FirstName != nil {
+		addAssignment(firstNameColumn, *changes.FirstName)
+	}
+	if changes.LastName != nil {
+		addAssignment(lastNameColumn, *changes.LastName)
+	}
+	if changes.City != nil {
+		addAssignment(cityColumn, *changes.City)
+	}
+	if changes.Skills != nil {
+		addAssignment(skillsColumn, *changes.Skills)
+	}
+	addAssignment(updatedAtColumn, changes.UpdatedAt)
+	addAssignment(updatedByColumn, changes.UpdatedBy)
+	args = append(args, changes.ID, changes.Version)
+	statement := fmt.Sprintf(patchUserSQL, strings.Join(assignments, ", "), len(args)-1, len(args))
+	return statement, args
+}
+
+// Delete marks the user with the given id as deleted, so it is hidden from
+// reads and searches. The deletion is audited in the same transaction.
+func (u *UserRDB) Delete(ctx context.Context, change repository.UserStateChange) error {
+	log.Println("level", "DEBUG", "msg", "deleting user", "method", "repository.UserRDB.Delete", "user id", change.ID)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	return u.withTransaction(ctx, "repository.UserRDB.Delete", "user cannot be deleted", func(tx *sql.Tx) error {
+		err := u.changeUser(ctx, tx, deleteUserSQL, "repository.UserRDB.Delete", "user cannot be deleted", change.ID, change.UpdatedAt, change.UpdatedBy)
+		if err != nil {
+			return err
+		}
+		return u.insertAudit(ctx, tx, repository.NewStateAuditEntry(repository.AuditDelete, change))
+	})
+}
+
+// Restore unmarks the user with the given id as deleted, the restoration is
+// audited in the same transaction.
+func (u *UserRDB) Restore(ctx context.Context, change repository.UserStateChange) error {
+	log.Println("level", "DEBUG", "msg", "restoring user", "method", "repository.UserRDB.Restore", "user id", change.ID)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	return u.withTransaction(ctx, "repository.UserRDB.Restore", "user cannot be restored", func(tx *sql.Tx) error {
+		err := u.changeUser(ctx, tx, restoreUserSQL, "repository.UserRDB.Restore", "user cannot be restored", change.ID, change.UpdatedAt, change.UpdatedBy)
+		if err != nil {
+			return err
+		}
+		return u.insertAudit(ctx, tx, repository.NewStateAuditEntry(repository.AuditRestore, change))
+	})
+}
+
+// Purge removes permanently the user with the given id from the database.
+// The field values of its history are redacted, so no personal data is left,
+// and the purge is audited in the same transaction.
+func (u *UserRDB) Purge(ctx context.Context, change repository.UserStateChange) error {
+	log.Println("level", "DEBUG", "msg", "purging user", "method", "repository.UserRDB.Purge", "user id", change.ID)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Write)
+	defer cancel()
+	return u.withTransaction(ctx, "repository.UserRDB.Purge", "user cannot be purged", func(tx *sql.Tx) error {
+		err := u.changeUser(ctx, tx, purgeUserSQL, "repository.UserRDB.Purge", "user cannot be purged", change.ID)
+		if err != nil {
+			return err
+		}
+		err = u.redactHistory(ctx, tx, change.ID)
+		if err != nil {
+			return err
+		}
+		return u.insertAudit(ctx, tx, repository.NewStateAuditEntry(repository.AuditPurge, change))
+	})
+}
+
+// changeUser executes the given statement with the given arguments, the
+// first one is the user id, and checks that one user was affected.
+func (u *UserRDB) changeUser(ctx context.Context, tx *sql.Tx, statement, method, failureMessage string, args ...interface{}) error {
+	userID := args[0]
+	stmt, release, err := u.prepared(ctx, tx, u.statement(statement))
+	if err != nil {
+		log.Println("level", "ERROR", "msg", failureMessage, "method", method, "user id", userID, "error", err)
+		return unavailableError(ctx, failureMessage, err)
+	}
+	defer release()
+	res, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		log.Println(
+			"level", "ERROR",
+			"msg", "got an error while executing statement",
+			"method", method,
+			"user id", userID,
 			"error", err,
 		)
-		return errors.New("user cannot be updated")
+		return unavailableError(ctx, failureMessage, err)
 	}
 	rowCnt, err := res.RowsAffected()
 	if err != nil {
 		log.Println(
 			"level", "ERROR",
 			"msg", "got an error while trying to get how many rows where affected",
-			"method", "repository.UserRDB.Updated",
-			"data", user,
+			"method", method,
+			"user id", userID,
 			"error", err,
 		)
-		return errors.New("cannot get how many records were affected, please check if user was updated")
+		return unavailableError(ctx, failureMessage, err)
+	}
+	if rowCnt == 0 {
+		log.Println("level", "ERROR", "msg", "no user was affected", "method", method, "user id", userID)
+		return users.ErrUserNotFound
 	}
-	log.Println("level", "INFO", "msg", "rows affected when updating a user", "method", "repository.UserRDB.Update", "count", rowCnt)
+	log.Println("level", "INFO", "msg", "rows affected when changing a user", "method", method, "count", rowCnt)
 	return nil
 }
 
-// SearchWithFilters search users with the given filters.
+// redactHistory empties the changes of every audit entry of the given user.
+func (u *UserRDB) redactHistory(ctx context.Context, tx *sql.Tx, userID string) error {
+	stmt, release, err := u.prepared(ctx, tx, u.statement(redactAuditSQL))
+	if err == nil {
+		defer release()
+		_, err = stmt.ExecContext(ctx, userID)
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "user history cannot be redacted", "method", "repository.UserRDB.redactHistory", "user id", userID, "error", err)
+		return unavailableError(ctx, "user history cannot be redacted", err)
+	}
+	return nil
+}
+
+// SearchWithFilters search users with the given filters. The page and the
+// total of the users found are read in the same query, unless the filter asks
+// to estimate the total or not to count it at all.
 func (u *UserRDB) SearchWithFilters(ctx context.Context, filter repository.UserFilter) (repository.FindUsersResult, error) {
 	log.Println("level", "DEBUG", "msg", "search users with filters", "method", "repository.UserRDB.SearchWithFilters")
+	ctx, cancel := withTimeout(ctx, u.timeouts.Search)
+	defer cancel()
 
 	result := repository.FindUsersResult{
 		Total:       0,
 		Page:        filter.Page,
 		RowsPerPage: filter.RowsPerPage,
+		TotalMode:   repository.TotalExact,
 	}
 
-	searchFilters := buildSQLFilters(filter)
+	searchFilters := buildSQLFilters(filter, u.tables)
 
-	var count int
-
-	countStmt, err := u.storage.Prepare(searchFilters.countStatement)
+	var err error
+	result.Facets, err = u.countFacets(ctx, filter, searchFilters)
 	if err != nil {
-		log.Println(
-			"level", "ERROR",
-			"msg", "error building search users prepared statement",
-			"method", "repository.UserRDB.SearchWithFilters",
-			"query", searchFilters.countStatement,
-			"filters", filter,
-			"error", err,
-		)
-		return result, errors.New("something went wrong trying to find some users")
+		return result, unavailableError(ctx, "something went wrong trying to find some users", err)
 	}
-	row := countStmt.QueryRow(searchFilters.countArgs...)
-	row.Scan(&count)
-	if err != nil {
-		log.Println(
-			"level", "ERROR",
-			"msg", "something went wrong trying to count the users found",
-			"method", "repository.UserRDB.SearchWithFilters",
-			"query", searchFilters.countStatement,
-			"filters", filter,
-			"error", err,
-		)
-		return result, errors.New("something went wrong trying to find some users")
-	}
-
-	result.Total = count
 
 	log.Println(
 		"level", "DEBUG",
@@ -186,7 +662,20 @@ func (u *UserRDB) SearchWithFilters(ctx context.Context, filter repository.UserF
 		"filters", filter,
 	)
 
-	rows, err := u.storage.Query(searchFilters.queryStatement, searchFilters.queryArgs...)
+	queryStmt, release, err := u.prepared(ctx, transactionFromContext(ctx), searchFilters.queryStatement)
+	if err != nil {
+		log.Println(
+			"level", "ERROR",
+			"msg", "error building search users prepared statement",
+			"method", "repository.UserRDB.SearchWithFilters",
+			"query", searchFilters.queryStatement,
+			"filters", filter,
+			"error", err,
+		)
+		return result, unavailableError(ctx, "something went wrong trying to find some users", err)
+	}
+	defer release()
+	rows, err := queryStmt.QueryContext(ctx, searchFilters.queryArgs...)
 	if err != nil {
 		log.Println(
 			"level", "ERROR",
@@ -196,13 +685,20 @@ func (u *UserRDB) SearchWithFilters(ctx context.Context, filter repository.UserF
 			"filters", filter,
 			"error", err,
 		)
-		return result, errors.New("something went wrong trying to find some users")
+		return result, unavailableError(ctx, "something went wrong trying to find some users", err)
 	}
+	defer rows.Close()
 
+	var total int
 	usersFound := make([]repository.User, 0)
 	for rows.Next() {
 		user := new(repository.User)
-		rowErr := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.City, &user.Skills)
+		var rowErr error
+		if searchFilters.withTotal {
+			rowErr = scanFoundUser(rows, user, &total)
+		} else {
+			rowErr = scanUser(rows, user)
+		}
 		if rowErr != nil {
 			log.Println(
 				"level", "ERROR",
@@ -212,7 +708,7 @@ func (u *UserRDB) SearchWithFilters(ctx context.Context, filter repository.UserF
 				"filters", filter,
 				"error", rowErr,
 			)
-			return result, errors.New("something went wrong trying to find some users")
+			return result, unavailableError(ctx, "something went wrong trying to find some users", rowErr)
 		}
 		usersFound = append(usersFound, *user)
 	}
@@ -226,7 +722,38 @@ func (u *UserRDB) SearchWithFilters(ctx context.Context, filter repository.UserF
 			"filters", filter,
 			"error", err,
 		)
-		return result, errors.New("something went wrong trying to find some users")
+		return result, unavailableError(ctx, "something went wrong trying to find some users", err)
+	}
+
+	switch {
+	case filter.TotalMode == repository.TotalNone:
+		result.TotalMode = repository.TotalNone
+	case filter.TotalMode == repository.TotalEstimate:
+		result.TotalMode = repository.TotalEstimate
+		total, err = u.estimateTotal(ctx, searchFilters)
+	case len(usersFound) == 0 && (filter.Page > 1 || filter.Cursor != nil):
+		// there is no row to carry the total after the last page.
+		total, err = u.countTotal(ctx, searchFilters)
+	}
+	if err != nil {
+		log.Println(
+			"level", "ERROR",
+			"msg", "something went wrong trying to count the users found",
+			"method", "repository.UserRDB.SearchWithFilters",
+			"query", searchFilters.countStatement,
+			"filters", filter,
+			"error", err,
+		)
+		return result, unavailableError(ctx, "something went wrong trying to find some users", err)
+	}
+	result.Total = total
+
+	if len(usersFound) > filter.RowsPerPage {
+		result.HasMore = true
+		usersFound = usersFound[:filter.RowsPerPage]
+	}
+	if filter.Cursor != nil && filter.Cursor.Backward {
+		reverseUsers(usersFound)
 	}
 
 	result.Users = usersFound
@@ -234,7 +761,265 @@ func (u *UserRDB) SearchWithFilters(ctx context.Context, filter repository.UserF
 	return result, nil
 }
 
-func buildSQLFilters(filters repository.UserFilter) *filterBuilder {
+// countTotal counts the users found with a query of its own.
+func (u *UserRDB) countTotal(ctx context.Context, searchFilters *filterBuilder) (int, error) {
+	stmt, release, err := u.prepared(ctx, transactionFromContext(ctx), searchFilters.countStatement)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	var count int
+	err = stmt.QueryRowContext(ctx, searchFilters.countArgs...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// queryPlan root node of a plan explained in json format.
+type queryPlan struct {
+	Plan struct {
+		Rows float64 `json:"Plan Rows"`
+	} `json:"Plan"`
+}
+
+// estimateTotal returns the number of users found the planner estimates, it
+// doesn't read them so it doesn't depend on how many they are.
+func (u *UserRDB) estimateTotal(ctx context.Context, searchFilters *filterBuilder) (int, error) {
+	stmt, release, err := u.prepared(ctx, transactionFromContext(ctx), searchFilters.estimateStatement)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	var explained []byte
+	err = stmt.QueryRowContext(ctx, searchFilters.countArgs...).Scan(&explained)
+	if err != nil {
+		return 0, err
+	}
+	var plans []queryPlan
+	err = json.Unmarshal(explained, &plans)
+	if err != nil {
+		return 0, err
+	}
+	if len(plans) == 0 {
+		return 0, errors.New("query plan without nodes")
+	}
+	return int(plans[0].Plan.Rows), nil
+}
+
+// countFacets counts the users found by every facet of the filter.
+func (u *UserRDB) countFacets(ctx context.Context, filter repository.UserFilter, searchFilters *filterBuilder) (map[string][]repository.FacetValue, error) {
+	if len(filter.Facets) == 0 {
+		return nil, nil
+	}
+	facets := make(map[string][]repository.FacetValue, len(filter.Facets))
+	for _, facet := range filter.Facets {
+		statement, ok := facetStatements[facet]
+		if !ok {
+			continue
+		}
+		query := u.statement(fmt.Sprintf(statement, searchFilters.whereClause, repository.FacetLimit))
+		values, err := u.queryFacet(ctx, query, searchFilters.countArgs)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to count the users found by facet",
+				"method", "repository.UserRDB.SearchWithFilters",
+				"query", query,
+				"facet", facet,
+				"error", err,
+			)
+			return nil, err
+		}
+		facets[facet] = values
+	}
+	return facets, nil
+}
+
+// queryFacet reads the values and counts of a facet statement.
+func (u *UserRDB) queryFacet(ctx context.Context, query string, args []interface{}) ([]repository.FacetValue, error) {
+	stmt, release, err := u.prepared(ctx, transactionFromContext(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make([]repository.FacetValue, 0)
+	for rows.Next() {
+		var value repository.FacetValue
+		err := rows.Scan(&value.Value, &value.Count)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// ExportWithFilters calls fn with every user found with the given filter, the
+// users are read in chunks from a server side cursor so memory usage doesn't
+// depend on the number of users. Pagination of the filter is ignored. The
+// export has no timeout of its own, it lasts as long as its context.
+func (u *UserRDB) ExportWithFilters(ctx context.Context, filter repository.UserFilter, fn func(repository.User) error) error {
+	log.Println("level", "DEBUG", "msg", "export users with filters", "method", "repository.UserRDB.ExportWithFilters", "filters", filter)
+	filter.Cursor = nil
+	filter.Page = 0
+	filter.RowsPerPage = 0
+	searchFilters := buildSQLFilters(filter, u.tables)
+	query := strings.TrimSuffix(searchFilters.queryStatement, ";")
+
+	// the cursor lives in a transaction, the one of the context if there is
+	// one. That transaction outlives the export, so the cursor is closed.
+	tx := transactionFromContext(ctx)
+	joined := tx != nil
+	if !joined {
+		var err error
+		tx, err = u.storage.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "transaction cannot be started", "method", "repository.UserRDB.ExportWithFilters", "error", err)
+			return unavailableError(ctx, "something went wrong trying to export users", err)
+		}
+		// the export only reads, rolling back closes the cursor too.
+		defer tx.Rollback()
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(declareExportSQL, query), searchFilters.queryArgs...)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "export cursor cannot be declared", "method", "repository.UserRDB.ExportWithFilters", "query", query, "error", err)
+		return unavailableError(ctx, "something went wrong trying to export users", err)
+	}
+	if joined {
+		defer closeExport(ctx, tx)
+	}
+	fetch := fmt.Sprintf(fetchExportSQL, exportFetchSize)
+	for {
+		usersFound, err := fetchExport(ctx, tx, fetch)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "users cannot be fetched from the export cursor", "method", "repository.UserRDB.ExportWithFilters", "error", err)
+			return unavailableError(ctx, "something went wrong trying to export users", err)
+		}
+		for _, v := range usersFound {
+			err := fn(v)
+			if err != nil {
+				return err
+			}
+		}
+		if len(usersFound) < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+// closeExport closes the export cursor, so the transaction it was declared in
+// can declare it again.
+func closeExport(ctx context.Context, tx *sql.Tx) {
+	_, err := tx.ExecContext(ctx, closeExportSQL)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "export cursor cannot be closed", "method", "repository.UserRDB.ExportWithFilters", "error", err)
+	}
+}
+
+// fetchExport reads the next chunk of users of the export cursor.
+func fetchExport(ctx context.Context, tx *sql.Tx, fetch string) ([]repository.User, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	usersFound := make([]repository.User, 0, exportFetchSize)
+	for rows.Next() {
+		var user repository.User
+		err := scanUser(rows, &user)
+		if err != nil {
+			return nil, err
+		}
+		usersFound = append(usersFound, user)
+	}
+	return usersFound, rows.Err()
+}
+
+// reverseUsers reverses the order of the given users.
+func reverseUsers(usersFound []repository.User) {
+	for i, j := 0, len(usersFound)-1; i < j; i, j = i+1, j-1 {
+		usersFound[i], usersFound[j] = usersFound[j], usersFound[i]
+	}
+}
+
+// FindHistory returns the audit entries of the given user, newest first.
+func (u *UserRDB) FindHistory(ctx context.Context, filter repository.HistoryFilter) (repository.FindHistoryResult, error) {
+	log.Println("level", "DEBUG", "msg", "reading user history", "method", "repository.UserRDB.FindHistory", "user id", filter.UserID)
+	ctx, cancel := withTimeout(ctx, u.timeouts.Read)
+	defer cancel()
+
+	result := repository.FindHistoryResult{
+		Entries:     make([]repository.AuditEntry, 0),
+		Page:        filter.Page,
+		RowsPerPage: filter.RowsPerPage,
+	}
+
+	countStmt, releaseCount, err := u.prepared(ctx, transactionFromContext(ctx), u.statement(countHistorySQL))
+	if err == nil {
+		err = countStmt.QueryRowContext(ctx, filter.UserID).Scan(&result.Total)
+		releaseCount()
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "counting user history", "method", "repository.UserRDB.FindHistory", "user id", filter.UserID, "error", err)
+		return result, unavailableError(ctx, "user history cannot be read in the database", err)
+	}
+
+	offset := filter.RowsPerPage * (filter.Page - 1)
+	historyStmt, release, err := u.prepared(ctx, transactionFromContext(ctx), u.statement(selectHistorySQL))
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading user history", "method", "repository.UserRDB.FindHistory", "user id", filter.UserID, "error", err)
+		return result, unavailableError(ctx, "user history cannot be read in the database", err)
+	}
+	defer release()
+	rows, err := historyStmt.QueryContext(ctx, filter.UserID, filter.RowsPerPage, offset)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "reading user history", "method", "repository.UserRDB.FindHistory", "user id", filter.UserID, "error", err)
+		return result, unavailableError(ctx, "user history cannot be read in the database", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry repository.AuditEntry
+		err := rows.Scan(&entry.UserID, &entry.Operation, &entry.Actor, &entry.ChangedAt, &entry.Changes)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to scan rows", "method", "repository.UserRDB.FindHistory", "user id", filter.UserID, "error", err)
+			return result, unavailableError(ctx, "user history cannot be read in the database", err)
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println("level", "ERROR", "msg", "something went wrong trying because rows results has an error", "method", "repository.UserRDB.FindHistory", "user id", filter.UserID, "error", err)
+		return result, unavailableError(ctx, "user history cannot be read in the database", err)
+	}
+
+	return result, nil
+}
+
+// rowScanner is a row or a set of rows whose current row can be scanned.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads the columns of a user row into the given user.
+func scanUser(row rowScanner, user *repository.User) error {
+	return row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.City, &user.Skills, &user.Version, &user.CreatedAt, &user.CreatedBy, &user.UpdatedAt, &user.UpdatedBy)
+}
+
+// scanFoundUser reads the columns of a user row of a search into the given
+// user, and the total of the users found it carries in the last column.
+func scanFoundUser(row rowScanner, user *repository.User, total *int) error {
+	return row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.City, &user.Skills, &user.Version, &user.CreatedAt, &user.CreatedBy, &user.UpdatedAt, &user.UpdatedBy, total)
+}
+
+func buildSQLFilters(filters repository.UserFilter, tables *strings.Replacer) *filterBuilder {
 	newFilterBuilder := &filterBuilder{
 		filters:   make([]string, 0),
 		countArgs: make([]interface{}, 0),
@@ -249,25 +1034,89 @@ func buildSQLFilters(filters repository.UserFilter) *filterBuilder {
 		newFilterBuilder.addCondition(skillsColumn, bsonInOperator, filters.Skills)
 	}
 
+	if len(filters.SkillsAny) > 0 {
+		newFilterBuilder.addExpression(anySkillCondition, skillDocuments(filters.SkillsAny))
+	}
+
+	if len(filters.SkillsNone) > 0 {
+		newFilterBuilder.addExpression(noneSkillCondition, skillDocuments(filters.SkillsNone))
+	}
+
+	for _, requirement := range filters.SkillRequirements {
+		levels := pq.StringArray(requirement.Levels)
+		if levels == nil {
+			levels = pq.StringArray{}
+		}
+		newFilterBuilder.addExpression(skillRequirementCondition, requirement.Name, levels, requirement.MinYears)
+	}
+
+	if filters.Query != "" {
+		newFilterBuilder.addExpression(textSearchCondition, filters.Query)
+		newFilterBuilder.relevance = fmt.Sprintf(relevanceExpression, len(newFilterBuilder.queryArgs))
+	}
+
+	if !filters.UpdatedSince.IsZero() {
+		newFilterBuilder.addCondition(updatedAtColumn, greaterOrEqualOperator, filters.UpdatedSince)
+	}
+
+	newFilterBuilder.addClause(notDeletedCondition)
+
 	var countWhereClause string
 	for _, v := range newFilterBuilder.filters {
 		countWhereClause += v
 	}
 
 	countStatement := fmt.Sprintf(countByFilterSQL, countWhereClause)
-	newFilterBuilder.countStatement = countStatement
+	newFilterBuilder.countStatement = tables.Replace(countStatement)
+	newFilterBuilder.estimateStatement = tables.Replace(fmt.Sprintf(estimateTotalSQL, countWhereClause))
+	newFilterBuilder.whereClause = countWhereClause
+
+	var totalColumn string
+	switch {
+	case filters.RowsPerPage == 0, filters.TotalMode == repository.TotalNone, filters.TotalMode == repository.TotalEstimate:
+		// exports and searches that don't count exactly read no total.
+	case filters.Cursor != nil:
+		// the seek narrows the rows of the query, the users found are
+		// counted in a subquery with the same arguments but the seek ones.
+		totalColumn = fmt.Sprintf(seekTotalColumn, countWhereClause)
+	default:
+		totalColumn = windowTotalColumn
+	}
+	newFilterBuilder.withTotal = totalColumn != ""
+
+	sortFields := repository.StableSort(filters.Sort)
+	backward := false
+	if filters.Cursor != nil {
+		backward = filters.Cursor.Backward
+		newFilterBuilder.addSeek(sortFields, *filters.Cursor)
+	}
+
+	newFilterBuilder.addOrder(sortFields, backward)
 
-	newFilterBuilder.addFilter(" LIMIT", filters.RowsPerPage, true)
-	page := filters.RowsPerPage * (filters.Page - 1)
-	newFilterBuilder.addFilter(" OFFSET", page, true)
+	switch {
+	case filters.RowsPerPage == 0:
+		// every user found is read, exports do it through a cursor.
+	case filters.Cursor != nil:
+		// one more row tells if there are more users after the page.
+		newFilterBuilder.addFilter(" LIMIT", filters.RowsPerPage+1, true)
+	default:
+		// one more row tells if there are more users after the page, the
+		// total may be estimated or not counted.
+		newFilterBuilder.addFilter(" LIMIT", filters.RowsPerPage+1, true)
+		offset := filters.RowsPerPage * (filters.Page - 1)
+		if offset < 0 {
+			offset = 0
+		}
+		newFilterBuilder.addFilter(" OFFSET", offset, true)
+	}
 
 	var whereClause string
 	for _, v := range newFilterBuilder.filters {
 		whereClause += v
 	}
 
-	queryStatement := fmt.Sprintf(selectByFilterSQL, whereClause)
-	newFilterBuilder.queryStatement = queryStatement
+	queryStatement := fmt.Sprintf(selectByFilterSQL, totalColumn, whereClause)
+	newFilterBuilder.queryStatement = tables.Replace(queryStatement)
 
 	return newFilterBuilder
 }
@@ -283,8 +1132,106 @@ func (f *filterBuilder) addCondition(field, operator string, value interface{})
 	return f
 }
 
+// addClause adds a condition that doesn't need any argument.
+func (f *filterBuilder) addClause(clause string) *filterBuilder {
+	condition := whereOperator
+	if len(f.filters) > 0 {
+		condition = " " + andOperator
+	}
+	f.filters = append(f.filters, fmt.Sprintf("%s %s", condition, clause))
+	return f
+}
+
+// skillDocuments builds a one element skills document for each of the given
+// skills, they can be matched with @> one by one.
+func skillDocuments(skills repository.Skills) pq.StringArray {
+	documents := make(pq.StringArray, 0, len(skills))
+	for _, v := range skills {
+		document, _ := json.Marshal(repository.Skills{{Name: v.Name}})
+		documents = append(documents, string(document))
+	}
+	return documents
+}
+
+// addOrder adds the ORDER BY clause with the given fields, backward reverses
+// every direction to read the rows before a cursor.
+func (f *filterBuilder) addOrder(fields []repository.SortField, backward bool) *filterBuilder {
+	orders := make([]string, 0, len(fields))
+	for _, v := range fields {
+		column, ok := f.sortColumn(v.Field)
+		if !ok {
+			continue
+		}
+		direction := "ASC"
+		if v.Descending != backward {
+			direction = "DESC"
+		}
+		orders = append(orders, column+" "+direction)
+	}
+	f.filters = append(f.filters, " ORDER BY "+strings.Join(orders, ", "))
+	return f
+}
+
+// addSeek adds the condition of the rows after the cursor in the order of the
+// given fields, or before it if the cursor goes backward. Directions can differ
+// between fields, so instead of a row comparison it is written as
+// (a > $1) OR (a = $1 AND b < $2) OR ...
+func (f *filterBuilder) addSeek(fields []repository.SortField, cursor repository.Cursor) *filterBuilder {
+	if len(cursor.Values) != len(fields) {
+		return f
+	}
+	alternatives := make([]string, 0, len(fields))
+	equalities := make([]string, 0, len(fields))
+	values := make([]interface{}, 0, len(fields))
+	for i, v := range fields {
+		column, ok := f.sortColumn(v.Field)
+		if !ok {
+			continue
+		}
+		operator := ">"
+		if v.Descending != cursor.Backward {
+			operator = "<"
+		}
+		values = append(values, cursor.Values[i])
+		placeholder := fmt.Sprintf("$%%[%d]d", len(values))
+		conditions := append(equalities[:len(equalities):len(equalities)], column+" "+operator+" "+placeholder)
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+		equalities = append(equalities, column+" = "+placeholder)
+	}
+	f.addExpression("("+strings.Join(alternatives, " OR ")+")", values...)
+	// the seek only limits the page, the count keeps every user found.
+	f.countArgs = f.countArgs[:len(f.countArgs)-len(values)]
+	return f
+}
+
+// sortColumn returns the column or expression to sort by the given field.
+func (f *filterBuilder) sortColumn(field string) (string, bool) {
+	if field == repository.SortByRelevance {
+		return f.relevance, f.relevance != ""
+	}
+	column, ok := sortColumns[field]
+	return column, ok
+}
+
+// addExpression adds a condition with several arguments, the given format
+// receives the placeholder index of every argument.
+func (f *filterBuilder) addExpression(format string, values ...interface{}) *filterBuilder {
+	condition := whereOperator
+	if len(f.filters) > 0 {
+		condition = " " + andOperator
+	}
+	indexes := make([]interface{}, 0, len(values))
+	for i := range values {
+		indexes = append(indexes, len(f.queryArgs)+1+i)
+	}
+	f.filters = append(f.filters, fmt.Sprintf("%s %s", condition, fmt.Sprintf(format, indexes...)))
+	f.countArgs = append(f.countArgs, values...)
+	f.queryArgs = append(f.queryArgs, values...)
+	return f
+}
+
 func (f *filterBuilder) addFilter(statement string, value interface{}, isHint bool) *filterBuilder {
-	index := len(f.filters) + 1
+	index := len(f.queryArgs) + 1
 	statement = fmt.Sprintf("%s $%d", statement, index)
 	f.filters = append(f.filters, statement)
 	if !isHint {
@@ -293,3 +1240,12 @@ func (f *filterBuilder) addFilter(statement string, value interface{}, isHint bo
 	f.queryArgs = append(f.queryArgs, value)
 	return f
 }
+
+// isUniqueViolation checks if the given error was caused by a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == uniqueViolationCode
+	}
+	return false
+}
@@ -33,9 +33,13 @@ func TestCreateUser(t *testing.T) {
 		FirstName: "users-micro",
 		LastName:  "Wayne",
 		City:      "Medellin",
-		Skills:    []string{"work", "happy"},
+		Skills:    repository.Skills{{Name: "work"}, {Name: "happy"}},
 	}
-	userRepository := postgresql.NewUserRepository(client)
+	userRepository, err := postgresql.NewUserRepository(client)
+	if err != nil {
+		t.Fatalf("unexpected error creating the user repository: %s", err)
+	}
+	defer userRepository.Close()
 
 	saveErr := userRepository.Save(ctx, newUser)
 	assert.NoError(t, saveErr)
@@ -67,17 +71,21 @@ func TestUpdateExistingUser(t *testing.T) {
 		FirstName: "users-micro",
 		LastName:  "Wayne",
 		City:      "Medellin",
-		Skills:    []string{"work", "happy"},
+		Skills:    repository.Skills{{Name: "work"}, {Name: "happy"}},
 	}
 	givenUser := repository.User{
 		ID:        newUserID,
 		City:      "Cali",
 		FirstName: "Alonso",
 		LastName:  "Ojeda",
-		Skills:    []string{"painter"},
+		Skills:    repository.Skills{{Name: "painter"}},
 	}
 
-	userRepository := postgresql.NewUserRepository(client)
+	userRepository, err := postgresql.NewUserRepository(client)
+	if err != nil {
+		t.Fatalf("unexpected error creating the user repository: %s", err)
+	}
+	defer userRepository.Close()
 
 	// WHEN
 	saveErr := userRepository.Save(ctx, newUser)
@@ -120,9 +128,13 @@ func TestReadUser(t *testing.T) {
 		FirstName: "users-micro",
 		LastName:  "Wayne",
 		City:      "Medellin",
-		Skills:    []string{"work"},
+		Skills:    repository.Skills{{Name: "work"}},
+	}
+	userRepository, err := postgresql.NewUserRepository(client)
+	if err != nil {
+		t.Fatalf("unexpected error creating the user repository: %s", err)
 	}
-	userRepository := postgresql.NewUserRepository(client)
+	defer userRepository.Close()
 
 	saveErr := userRepository.Save(ctx, newUser)
 	assert.NoError(t, saveErr)
@@ -164,21 +176,21 @@ func TestFindUsersByCityIntegration(t *testing.T) {
 			City:      "Cali",
 			FirstName: "Wayne",
 			LastName:  "Ojeda",
-			Skills:    []string{"painter"},
+			Skills:    repository.Skills{{Name: "painter"}},
 		},
 		{
 			ID:        "124",
 			City:      "Cali",
 			FirstName: "Alicia",
 			LastName:  "Cifuentes",
-			Skills:    []string{"sculptor"},
+			Skills:    repository.Skills{{Name: "sculptor"}},
 		},
 		{
 			ID:        "125",
 			City:      "Bogota",
 			FirstName: "Alicia",
 			LastName:  "Cifuentes",
-			Skills:    []string{"sculptor"},
+			Skills:    repository.Skills{{Name: "sculptor"}},
 		},
 	}
 	givenFilter := repository.UserFilter{
@@ -193,21 +205,26 @@ func TestFindUsersByCityIntegration(t *testing.T) {
 				City:      "Cali",
 				FirstName: "Wayne",
 				LastName:  "Ojeda",
-				Skills:    []string{"painter"},
+				Skills:    repository.Skills{{Name: "painter"}},
 			},
 			{
 				ID:        "124",
 				City:      "Cali",
 				FirstName: "Alicia",
 				LastName:  "Cifuentes",
-				Skills:    []string{"sculptor"},
+				Skills:    repository.Skills{{Name: "sculptor"}},
 			},
 		},
 		Total:       2,
 		Page:        1,
 		RowsPerPage: 10,
+		TotalMode:   repository.TotalExact,
 	}
-	userRepository := postgresql.NewUserRepository(client)
+	userRepository, err := postgresql.NewUserRepository(client)
+	if err != nil {
+		t.Fatalf("unexpected error creating the user repository: %s", err)
+	}
+	defer userRepository.Close()
 
 	for _, v := range newUsers {
 		newUser := v
@@ -252,25 +269,25 @@ func TestFindUsersBySkillsIntegration(t *testing.T) {
 			City:      "Cali",
 			FirstName: "Wayne",
 			LastName:  "Ojeda",
-			Skills:    []string{"painter", "decorator"},
+			Skills:    repository.Skills{{Name: "painter"}, {Name: "decorator"}},
 		},
 		{
 			ID:        "127",
 			City:      "Cali",
 			FirstName: "Alicia",
 			LastName:  "Cifuentes",
-			Skills:    []string{"sculptor", "cabinetmaker", "painter"},
+			Skills:    repository.Skills{{Name: "sculptor"}, {Name: "cabinetmaker"}, {Name: "painter"}},
 		},
 		{
 			ID:        "128",
 			City:      "Bogota",
 			FirstName: "Liliana",
 			LastName:  "Marino",
-			Skills:    []string{"painter", "cabinetmaker"},
+			Skills:    repository.Skills{{Name: "painter"}, {Name: "cabinetmaker"}},
 		},
 	}
 	givenFilter := repository.UserFilter{
-		Skills:      []string{"cabinetmaker", "painter"},
+		Skills:      repository.Skills{{Name: "cabinetmaker"}, {Name: "painter"}},
 		Page:        1,
 		RowsPerPage: 10,
 	}
@@ -281,21 +298,26 @@ func TestFindUsersBySkillsIntegration(t *testing.T) {
 				City:      "Cali",
 				FirstName: "Alicia",
 				LastName:  "Cifuentes",
-				Skills:    []string{"sculptor", "cabinetmaker", "painter"},
+				Skills:    repository.Skills{{Name: "sculptor"}, {Name: "cabinetmaker"}, {Name: "painter"}},
 			},
 			{
 				ID:        "128",
 				City:      "Bogota",
 				FirstName: "Liliana",
 				LastName:  "Marino",
-				Skills:    []string{"painter", "cabinetmaker"},
+				Skills:    repository.Skills{{Name: "painter"}, {Name: "cabinetmaker"}},
 			},
 		},
 		Total:       2,
 		Page:        1,
 		RowsPerPage: 10,
+		TotalMode:   repository.TotalExact,
+	}
+	userRepository, err := postgresql.NewUserRepository(client)
+	if err != nil {
+		t.Fatalf("unexpected error creating the user repository: %s", err)
 	}
-	userRepository := postgresql.NewUserRepository(client)
+	defer userRepository.Close()
 
 	for _, v := range newUsers {
 		newUser := v
@@ -316,3 +338,56 @@ func TestFindUsersBySkillsIntegration(t *testing.T) {
 
 	assert.Equal(t, expectedResult, searchResult)
 }
+
+func TestPurgeUserRedactsHistoryIntegration(t *testing.T) {
+	if !*integration {
+		t.Skip("this is an integration test, to execute this test send integration flag to true")
+	}
+	ctx := context.TODO()
+
+	givenParameters := postgresql.Parameters{
+		DBName:   "postgres",
+		Host:     "localhost",
+		User:     "postgres",
+		Password: "postgres",
+		Port:     5432,
+	}
+	client := createClient(t, givenParameters)
+	defer client.Close()
+
+	newUserID := uuid.New().String()
+	newUser := repository.User{
+		ID:        newUserID,
+		FirstName: "users-micro",
+		LastName:  "Wayne",
+		City:      "Medellin",
+		Skills:    repository.Skills{{Name: "work"}},
+		CreatedBy: "recruiter-1",
+		UpdatedBy: "recruiter-1",
+	}
+	userRepository, err := postgresql.NewUserRepository(client)
+	if err != nil {
+		t.Fatalf("unexpected error creating the user repository: %s", err)
+	}
+	defer userRepository.Close()
+	if err := userRepository.Save(ctx, newUser); err != nil {
+		t.Fatalf("unexpected error trying to create a user: %s", err)
+	}
+	newUser.City = "Cali"
+	newUser.Version = 1
+	if err := userRepository.Update(ctx, newUser); err != nil {
+		t.Fatalf("unexpected error trying to update a user: %s", err)
+	}
+
+	purgeErr := userRepository.Purge(ctx, repository.UserStateChange{ID: newUserID, UpdatedBy: "recruiter-2"})
+
+	assert.NoError(t, purgeErr)
+	history, err := userRepository.FindHistory(ctx, repository.HistoryFilter{UserID: newUserID, Page: 1, RowsPerPage: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, history.Entries, 3) {
+		assert.Equal(t, repository.AuditPurge, history.Entries[0].Operation)
+		for _, v := range history.Entries {
+			assert.Empty(t, v.Changes)
+		}
+	}
+}
@@ -2,12 +2,19 @@ package postgresql_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/fernandoocampo/users-micro/internal/adapter/postgresql"
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,30 +25,205 @@ func TestSaveUser(t *testing.T) {
 		City:      "Cali",
 		FirstName: "Alonso",
 		LastName:  "Ojeda",
-		Skills:    []string{"painter"},
+		Skills:    repository.Skills{{Name: "painter"}},
+		Version:   1,
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
 	}
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	mock.ExpectPrepare("INSERT INTO jobseeker").ExpectExec().
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO \"jobseeker\"").
 		WithArgs(
 			givenUser.ID,
 			givenUser.FirstName,
 			givenUser.LastName,
 			givenUser.City,
 			givenUser.Skills,
+			givenUser.CreatedAt,
+			givenUser.CreatedBy,
+			givenUser.UpdatedAt,
+			givenUser.UpdatedBy,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs(givenUser.ID, repository.AuditCreate, stampedBy, stampedAt, repository.FieldChanges{
+			{Field: "first_name", After: "Alonso"},
+			{Field: "last_name", After: "Ojeda"},
+			{Field: "city", After: "Cali"},
+			{Field: "skills", After: repository.Skills{{Name: "painter"}}},
+		}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	saveError := userRepository.Save(ctx, givenUser)
+
+	assert.NoError(t, saveError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveUserWithoutSkills(t *testing.T) {
+	ctx := context.TODO()
+	givenUser := repository.User{
+		ID:        "123",
+		FirstName: "Alonso",
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	userRepository := postgresql.NewUserRepository(db)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO \"jobseeker\"").
+		WithArgs("123", "Alonso", "", "", []byte("[]"), stampedAt, stampedBy, stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// WHEN
 	saveError := userRepository.Save(ctx, givenUser)
 
 	assert.NoError(t, saveError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAllUsers(t *testing.T) {
+	ctx := context.TODO()
+	givenUsers := []repository.User{
+		{ID: "123", FirstName: "Alonso", LastName: "Ojeda", City: "Cali", CreatedAt: stampedAt, CreatedBy: stampedBy, UpdatedAt: stampedAt, UpdatedBy: stampedBy},
+		{ID: "456", FirstName: "Alicia", LastName: "Mendez", City: "Cali", CreatedAt: stampedAt, CreatedBy: stampedBy, UpdatedAt: stampedAt, UpdatedBy: stampedBy},
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "jobseeker"\(`).
+		WithArgs("123", "Alonso", "Ojeda", "Cali", repository.Skills(nil), stampedAt, stampedBy, stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("123", repository.AuditCreate, stampedBy, stampedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "jobseeker"\(`).
+		WithArgs("456", "Alicia", "Mendez", "Cali", repository.Skills(nil), stampedAt, stampedBy, stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("456", repository.AuditCreate, stampedBy, stampedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	userErrors, saveError := userRepository.SaveAll(ctx, givenUsers)
+
+	assert.NoError(t, saveError)
+	assert.Equal(t, []error{nil, nil}, userErrors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAllUsersWithExistingUser(t *testing.T) {
+	ctx := context.TODO()
+	givenUsers := []repository.User{
+		{ID: "123", FirstName: "Alonso", LastName: "Ojeda", CreatedAt: stampedAt, CreatedBy: stampedBy, UpdatedAt: stampedAt, UpdatedBy: stampedBy},
+		{ID: "456", FirstName: "Alicia", LastName: "Mendez", CreatedAt: stampedAt, CreatedBy: stampedBy, UpdatedAt: stampedAt, UpdatedBy: stampedBy},
+		{ID: "789", FirstName: "Oliver", LastName: "Vasquez", CreatedAt: stampedAt, CreatedBy: stampedBy, UpdatedAt: stampedAt, UpdatedBy: stampedBy},
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "jobseeker"\(`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "jobseeker"\(`).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "jobseeker"\(`).
+		WithArgs("789", "Oliver", "Vasquez", "", repository.Skills(nil), stampedAt, stampedBy, stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// WHEN
+	userErrors, saveError := userRepository.SaveAll(ctx, givenUsers)
+
+	assert.NoError(t, saveError)
+	assert.Len(t, userErrors, 3)
+	assert.NoError(t, userErrors[0])
+	assert.Equal(t, users.ErrorKindConflict, users.KindOf(userErrors[1]))
+	assert.NoError(t, userErrors[2])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAllUsersFailsWhenSavepointCannotBeRolledBack(t *testing.T) {
+	ctx := context.TODO()
+	givenUsers := []repository.User{
+		{ID: "123", FirstName: "Alonso", LastName: "Ojeda", CreatedAt: stampedAt, CreatedBy: stampedBy, UpdatedAt: stampedAt, UpdatedBy: stampedBy},
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT save_user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "jobseeker"\(`).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT save_user").
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	// WHEN
+	userErrors, saveError := userRepository.SaveAll(ctx, givenUsers)
+
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(saveError))
+	assert.Nil(t, userErrors)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestSaveUserButUnexpectedError(t *testing.T) {
@@ -51,32 +233,41 @@ func TestSaveUserButUnexpectedError(t *testing.T) {
 		City:      "Cali",
 		FirstName: "Alonso",
 		LastName:  "Ojeda",
-		Skills:    []string{"painter"},
+		Skills:    repository.Skills{{Name: "painter"}},
+		Version:   1,
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
 	}
-	expectedError := errors.New("user cannot be stored")
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	mock.ExpectPrepare("INSERT INTO jobseeker").ExpectExec().
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO \"jobseeker\"").
 		WithArgs(
 			givenUser.ID,
 			givenUser.FirstName,
 			givenUser.LastName,
 			givenUser.City,
 			givenUser.Skills,
+			givenUser.CreatedAt,
+			givenUser.CreatedBy,
+			givenUser.UpdatedAt,
+			givenUser.UpdatedBy,
 		).
 		WillReturnError(errors.New("unexpected error"))
-
-	userRepository := postgresql.NewUserRepository(db)
+	mock.ExpectRollback()
 
 	// WHEN
 	saveError := userRepository.Save(ctx, givenUser)
 
-	assert.Error(t, saveError)
-	assert.Equal(t, expectedError, saveError)
+	assert.EqualError(t, saveError, "user cannot be stored")
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(saveError))
 }
 
 func TestUpdateUser(t *testing.T) {
@@ -86,30 +277,257 @@ func TestUpdateUser(t *testing.T) {
 		City:      "Cali",
 		FirstName: "Alonso",
 		LastName:  "Ojeda",
-		Skills:    []string{"painter"},
+		Skills:    repository.Skills{{Name: "painter"}},
+		Version:   1,
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
 	}
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	mock.ExpectPrepare("UPDATE jobseeker").ExpectExec().
+	currentRow := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Medellin", []byte(`["painter"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "jobseeker" WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(givenUser.ID).
+		WillReturnRows(currentRow)
+	mock.ExpectExec("UPDATE \"jobseeker\"").
 		WithArgs(
 			givenUser.FirstName,
 			givenUser.LastName,
 			givenUser.City,
 			givenUser.Skills,
+			givenUser.UpdatedAt,
+			givenUser.UpdatedBy,
 			givenUser.ID,
+			givenUser.Version,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	userRepository := postgresql.NewUserRepository(db)
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs(givenUser.ID, repository.AuditUpdate, stampedBy, stampedAt, repository.FieldChanges{
+			{Field: "city", Before: "Medellin", After: "Cali"},
+		}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// WHEN
 	saveError := userRepository.Update(ctx, givenUser)
 
 	assert.NoError(t, saveError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUserWithOldVersion(t *testing.T) {
+	ctx := context.TODO()
+	givenUser := repository.User{
+		ID:        "123",
+		City:      "Cali",
+		FirstName: "Alonso",
+		LastName:  "Ojeda",
+		Skills:    repository.Skills{{Name: "painter"}},
+		Version:   1,
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	currentRow := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Medellin", []byte(`["painter"]`), 2, stampedAt, stampedBy, stampedAt, stampedBy)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "jobseeker" WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(givenUser.ID).
+		WillReturnRows(currentRow)
+	mock.ExpectRollback()
+
+	// WHEN
+	saveError := userRepository.Update(ctx, givenUser)
+
+	assert.Equal(t, users.ErrVersionConflict, saveError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatchUser(t *testing.T) {
+	ctx := context.TODO()
+	newCity := "Bogota"
+	newSkills := repository.Skills{{Name: "painter"}, {Name: "sculptor"}}
+	givenChanges := repository.UserPatch{
+		ID:        "123",
+		Version:   4,
+		City:      &newCity,
+		Skills:    &newSkills,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	currentRow := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`["painter"]`), 4, stampedAt, stampedBy, stampedAt, stampedBy)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "jobseeker" WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs("123").
+		WillReturnRows(currentRow)
+	mock.ExpectPrepare(`UPDATE "jobseeker" SET city = \$1, skills = \$2, updated_at = \$3, updated_by = \$4, version = version \+ 1 WHERE id = \$5 AND version = \$6`).ExpectExec().
+		WithArgs(newCity, newSkills, stampedAt, stampedBy, "123", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("123", repository.AuditPatch, stampedBy, stampedAt, repository.FieldChanges{
+			{Field: "city", Before: "Cali", After: "Bogota"},
+			{Field: "skills", Before: repository.Skills{{Name: "painter"}}, After: newSkills},
+		}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	patchError := userRepository.Patch(ctx, givenChanges)
+
+	assert.NoError(t, patchError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"jobseeker\" SET deleted_at = \\$2, updated_at = \\$2, updated_by = \\$3").
+		WithArgs("123", stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("123", repository.AuditDelete, stampedBy, stampedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	deleteError := userRepository.Delete(ctx, stateChange("123"))
+
+	assert.NoError(t, deleteError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"jobseeker\" SET deleted_at = \\$2").
+		WithArgs("123", stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// WHEN
+	deleteError := userRepository.Delete(ctx, stateChange("123"))
+
+	assert.Equal(t, users.ErrUserNotFound, deleteError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUser(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"jobseeker\" SET deleted_at = NULL, updated_at = \\$2, updated_by = \\$3").
+		WithArgs("123", stampedAt, stampedBy).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("123", repository.AuditRestore, stampedBy, stampedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	restoreError := userRepository.Restore(ctx, stateChange("123"))
+
+	assert.NoError(t, restoreError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeUser(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM \"jobseeker\"").
+		WithArgs("123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "jobseeker_audit" SET changes = '[]' WHERE jobseeker_id = $1`)).
+		WithArgs("123").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO \"jobseeker_audit\"").
+		WithArgs("123", repository.AuditPurge, stampedBy, stampedAt, []byte("[]")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// WHEN
+	purgeError := userRepository.Purge(ctx, stateChange("123"))
+
+	assert.NoError(t, purgeError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeUserFailsWhenHistoryCannotBeRedacted(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM \"jobseeker\"").
+		WithArgs("123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "jobseeker_audit" SET changes`).
+		WithArgs("123").
+		WillReturnError(errors.New("jobseeker_audit is append only"))
+	mock.ExpectRollback()
+
+	// WHEN
+	purgeError := userRepository.Purge(ctx, stateChange("123"))
+
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(purgeError))
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestFindUserByID(t *testing.T) {
@@ -120,22 +538,26 @@ func TestFindUserByID(t *testing.T) {
 		City:      "Cali",
 		FirstName: "Alonso",
 		LastName:  "Ojeda",
-		Skills:    []string{"painter"},
+		Skills:    repository.Skills{{Name: "painter"}},
+		Version:   1,
+		CreatedAt: stampedAt,
+		CreatedBy: stampedBy,
+		UpdatedAt: stampedAt,
+		UpdatedBy: stampedBy,
 	}
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills"}).
-		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`["painter"]`))
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`["painter"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy)
 
-	mock.ExpectQuery("SELECT (.+) FROM jobseeker").
+	mock.ExpectQuery("SELECT (.+) FROM \"jobseeker\"").
 		WillReturnRows(rows)
 
-	userRepository := postgresql.NewUserRepository(db)
-
 	// WHEN
 	got, saveError := userRepository.FindByID(ctx, givenUserID)
 
@@ -143,27 +565,69 @@ func TestFindUserByID(t *testing.T) {
 	assert.Equal(t, &expectedUser, got)
 }
 
+func TestFindUserByIDTimesOut(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock, postgresql.WithTimeouts(postgresql.Timeouts{Read: 10 * time.Millisecond}))
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`[]`), 1, stampedAt, stampedBy, stampedAt, stampedBy)
+	mock.ExpectQuery("SELECT (.+) FROM \"jobseeker\"").
+		WillDelayFor(time.Second).
+		WillReturnRows(rows)
+
+	// WHEN
+	got, findError := userRepository.FindByID(ctx, "123")
+
+	assert.Nil(t, got)
+	assert.Equal(t, users.ErrorKindTimeout, users.KindOf(findError))
+	assert.Equal(t, users.ErrorCodeDeadlineExceeded, users.CodeOf(findError))
+}
+
 func TestFindUserByIDButError(t *testing.T) {
 	ctx := context.TODO()
 	givenUserID := "123"
-	expectedError := errors.New("user cannot be read in the database")
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	mock.ExpectQuery("SELECT (.+) FROM jobseeker").
+	mock.ExpectQuery("SELECT (.+) FROM \"jobseeker\"").
 		WillReturnError(errors.New("error"))
 
-	userRepository := postgresql.NewUserRepository(db)
-
 	// WHEN
 	got, saveError := userRepository.FindByID(ctx, givenUserID)
 
-	assert.Error(t, saveError)
+	assert.EqualError(t, saveError, "user cannot be read in the database")
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(saveError))
+	assert.Nil(t, got)
+}
+
+func TestFindUserByIDNotFound(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"})
+
+	mock.ExpectQuery("SELECT (.+) FROM \"jobseeker\"").
+		WillReturnRows(rows)
+
+	// WHEN
+	got, findError := userRepository.FindByID(ctx, "123")
+
+	assert.Equal(t, users.ErrUserNotFound, findError)
 	assert.Nil(t, got)
-	assert.Equal(t, expectedError, saveError)
 }
 
 func TestFindUsersByCity(t *testing.T) {
@@ -180,19 +644,30 @@ func TestFindUsersByCity(t *testing.T) {
 				City:      "Cali",
 				FirstName: "Alonso",
 				LastName:  "Ojeda",
-				Skills:    []string{"painter"},
+				Skills:    repository.Skills{{Name: "painter"}},
+				Version:   1,
+				CreatedAt: stampedAt,
+				CreatedBy: stampedBy,
+				UpdatedAt: stampedAt,
+				UpdatedBy: stampedBy,
 			},
 			{
 				ID:        "124",
 				City:      "Cali",
 				FirstName: "Alicia",
 				LastName:  "Cifuentes",
-				Skills:    []string{"sculptor"},
+				Skills:    repository.Skills{{Name: "sculptor"}},
+				Version:   1,
+				CreatedAt: stampedAt,
+				CreatedBy: stampedBy,
+				UpdatedAt: stampedAt,
+				UpdatedBy: stampedBy,
 			},
 		},
 		Total:       2,
 		Page:        1,
 		RowsPerPage: 10,
+		TotalMode:   repository.TotalExact,
 	}
 
 	db, mock, err := sqlmock.New()
@@ -200,24 +675,16 @@ func TestFindUsersByCity(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	countRow := sqlmock.NewRows([]string{"COUNT(*)"}).
-		AddRow("2")
-
-	mock.ExpectPrepare("SELECT (.+) FROM jobseeker WHERE city").
-		ExpectQuery().WithArgs("Cali").
-		WillReturnRows(countRow)
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`["painter"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy, 2).
+		AddRow("124", "Alicia", "Cifuentes", "Cali", []byte(`["sculptor"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy, 2)
 
-	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills"}).
-		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`["painter"]`)).
-		AddRow("124", "Alicia", "Cifuentes", "Cali", []byte(`["sculptor"]`))
-
-	mock.ExpectQuery("SELECT (.+) FROM jobseeker WHERE city").
-		WithArgs("Cali", 10, 0).
+	mock.ExpectPrepare("SELECT (.+) FROM \"jobseeker\" WHERE city").ExpectQuery().
+		WithArgs("Cali", 11, 0).
 		WillReturnRows(rows)
 
-	userRepository := postgresql.NewUserRepository(db)
-
 	// WHEN
 	got, findError := userRepository.SearchWithFilters(ctx, givenFilter)
 
@@ -228,7 +695,7 @@ func TestFindUsersByCity(t *testing.T) {
 func TestFindUsersBySkill(t *testing.T) {
 	ctx := context.TODO()
 	givenFilter := repository.UserFilter{
-		Skills:      []string{"cabinetmaker"},
+		Skills:      repository.Skills{{Name: "cabinetmaker"}},
 		Page:        1,
 		RowsPerPage: 10,
 	}
@@ -239,19 +706,30 @@ func TestFindUsersBySkill(t *testing.T) {
 				City:      "Bogota",
 				FirstName: "Cecilia",
 				LastName:  "Quiroga",
-				Skills:    []string{"cabinetmaker"},
+				Skills:    repository.Skills{{Name: "cabinetmaker"}},
+				Version:   1,
+				CreatedAt: stampedAt,
+				CreatedBy: stampedBy,
+				UpdatedAt: stampedAt,
+				UpdatedBy: stampedBy,
 			},
 			{
 				ID:        "126",
 				City:      "Medellin",
 				FirstName: "Armando",
 				LastName:  "Lopez",
-				Skills:    []string{"sculptor", "cabinetmaker"},
+				Skills:    repository.Skills{{Name: "sculptor"}, {Name: "cabinetmaker"}},
+				Version:   1,
+				CreatedAt: stampedAt,
+				CreatedBy: stampedBy,
+				UpdatedAt: stampedAt,
+				UpdatedBy: stampedBy,
 			},
 		},
 		Total:       2,
 		Page:        1,
 		RowsPerPage: 10,
+		TotalMode:   repository.TotalExact,
 	}
 
 	db, mock, err := sqlmock.New()
@@ -259,24 +737,16 @@ func TestFindUsersBySkill(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	countRow := sqlmock.NewRows([]string{"COUNT(*)"}).
-		AddRow("2")
-
-	mock.ExpectPrepare("SELECT (.+) FROM jobseeker WHERE skills").
-		ExpectQuery().WithArgs([]byte(`["cabinetmaker"]`)).
-		WillReturnRows(countRow)
-
-	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills"}).
-		AddRow("125", "Cecilia", "Quiroga", "Bogota", []byte(`["cabinetmaker"]`)).
-		AddRow("126", "Armando", "Lopez", "Medellin", []byte(`["sculptor", "cabinetmaker"]`))
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"}).
+		AddRow("125", "Cecilia", "Quiroga", "Bogota", []byte(`["cabinetmaker"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy, 2).
+		AddRow("126", "Armando", "Lopez", "Medellin", []byte(`["sculptor", "cabinetmaker"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy, 2)
 
-	mock.ExpectQuery("SELECT (.+) FROM jobseeker WHERE skills").
-		WithArgs([]byte(`["cabinetmaker"]`), 10, 0).
+	mock.ExpectPrepare("SELECT (.+) FROM \"jobseeker\" WHERE skills").ExpectQuery().
+		WithArgs([]byte(`[{"name":"cabinetmaker"}]`), 11, 0).
 		WillReturnRows(rows)
 
-	userRepository := postgresql.NewUserRepository(db)
-
 	// WHEN
 	got, findError := userRepository.SearchWithFilters(ctx, givenFilter)
 
@@ -288,7 +758,7 @@ func TestFindUsersBySkillAndCity(t *testing.T) {
 	ctx := context.TODO()
 	givenFilter := repository.UserFilter{
 		City:        "Medellin",
-		Skills:      []string{"cabinetmaker"},
+		Skills:      repository.Skills{{Name: "cabinetmaker"}},
 		Page:        1,
 		RowsPerPage: 10,
 	}
@@ -299,12 +769,47 @@ func TestFindUsersBySkillAndCity(t *testing.T) {
 				City:      "Medellin",
 				FirstName: "Armando",
 				LastName:  "Lopez",
-				Skills:    []string{"sculptor", "cabinetmaker"},
+				Skills:    repository.Skills{{Name: "sculptor"}, {Name: "cabinetmaker"}},
+				Version:   1,
+				CreatedAt: stampedAt,
+				CreatedBy: stampedBy,
+				UpdatedAt: stampedAt,
+				UpdatedBy: stampedBy,
 			},
 		},
 		Total:       1,
 		Page:        1,
 		RowsPerPage: 10,
+		TotalMode:   repository.TotalExact,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"}).
+		AddRow("126", "Armando", "Lopez", "Medellin", []byte(`["sculptor", "cabinetmaker"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy, 1)
+
+	mock.ExpectPrepare("SELECT (.+) FROM \"jobseeker\" WHERE city").ExpectQuery().
+		WithArgs("Medellin", []byte(`[{"name":"cabinetmaker"}]`), 11, 0).
+		WillReturnRows(rows)
+
+	// WHEN
+	got, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.Equal(t, expectedResult, got)
+}
+
+func TestFindUsersUpdatedSince(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		UpdatedSince: stampedAt,
+		Page:         1,
+		RowsPerPage:  10,
 	}
 
 	db, mock, err := sqlmock.New()
@@ -312,26 +817,588 @@ func TestFindUsersBySkillAndCity(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"})
+
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" WHERE updated_at >= \$1 AND deleted_at IS NULL ORDER BY id ASC LIMIT \$2 OFFSET \$3`).ExpectQuery().
+		WithArgs(stampedAt, 11, 0).
+		WillReturnRows(rows)
 
-	countRow := sqlmock.NewRows([]string{"COUNT(*)"}).
-		AddRow("1")
+	// WHEN
+	_, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUsersSorted(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		Sort: []repository.SortField{
+			{Field: repository.SortByLastName},
+			{Field: repository.SortByUpdatedAt, Descending: true},
+			{Field: "password"},
+		},
+		Page:        2,
+		RowsPerPage: 10,
+	}
 
-	mock.ExpectPrepare("SELECT (.+) FROM jobseeker WHERE city").
-		ExpectQuery().WithArgs("Medellin", []byte(`["cabinetmaker"]`)).
-		WillReturnRows(countRow)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
 
-	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills"}).
-		AddRow("126", "Armando", "Lopez", "Medellin", []byte(`["sculptor", "cabinetmaker"]`))
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"})
 
-	mock.ExpectQuery("SELECT (.+) FROM jobseeker WHERE city").
-		WithArgs("Medellin", []byte(`["cabinetmaker"]`), 10, 0).
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" WHERE deleted_at IS NULL ORDER BY lastname ASC, updated_at DESC, id ASC LIMIT \$1 OFFSET \$2`).ExpectQuery().
+		WithArgs(11, 10).
 		WillReturnRows(rows)
 
-	userRepository := postgresql.NewUserRepository(db)
+	// the page is after the last one, it has no row to carry the total.
+	mock.ExpectPrepare(`SELECT COUNT\(id\) FROM "jobseeker" WHERE deleted_at IS NULL;`).
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 
 	// WHEN
-	got, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+	_, findError := userRepository.SearchWithFilters(ctx, givenFilter)
 
 	assert.NoError(t, findError)
-	assert.Equal(t, expectedResult, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUsersAfterCursor(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		Sort:        []repository.SortField{{Field: repository.SortByLastName}},
+		Cursor:      &repository.Cursor{Values: []string{"Mendez", "1"}},
+		RowsPerPage: 1,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"}).
+		AddRow("3", "Cecilia", "Mendez", "Cali", []byte(`[]`), 1, time.Time{}, "", time.Time{}, "", 3).
+		AddRow("2", "Oliver", "Vasquez", "Cali", []byte(`[]`), 1, time.Time{}, "", time.Time{}, "", 3)
+
+	mock.ExpectPrepare(`SELECT (.+), \(SELECT COUNT\(id\) FROM "jobseeker" WHERE deleted_at IS NULL\) FROM "jobseeker" WHERE deleted_at IS NULL AND \(\(lastname > \$1\) OR \(lastname = \$1 AND id > \$2\)\) ORDER BY lastname ASC, id ASC LIMIT \$3`).ExpectQuery().
+		WithArgs("Mendez", "1", 2).
+		WillReturnRows(rows)
+
+	// WHEN
+	result, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, result.Users, 1)
+	assert.Equal(t, "3", result.Users[0].ID)
+	assert.True(t, result.HasMore)
+	assert.Equal(t, 3, result.Total)
+}
+
+func TestFindUsersWithEstimatedTotal(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		City:        "Cali",
+		TotalMode:   repository.TotalEstimate,
+		Page:        1,
+		RowsPerPage: 10,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`[]`), 1, stampedAt, stampedBy, stampedAt, stampedBy)
+
+	mock.ExpectPrepare(`SELECT id, (.+), updated_by FROM "jobseeker" WHERE city = \$1 AND deleted_at IS NULL ORDER BY id ASC LIMIT \$2 OFFSET \$3`).ExpectQuery().
+		WithArgs("Cali", 11, 0).
+		WillReturnRows(rows)
+
+	planRow := sqlmock.NewRows([]string{"QUERY PLAN"}).
+		AddRow([]byte(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 48210}}]`))
+
+	mock.ExpectPrepare(`EXPLAIN \(FORMAT JSON\) SELECT id FROM "jobseeker" WHERE city = \$1 AND deleted_at IS NULL`).ExpectQuery().
+		WithArgs("Cali").
+		WillReturnRows(planRow)
+
+	// WHEN
+	result, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, result.Users, 1)
+	assert.Equal(t, 48210, result.Total)
+	assert.Equal(t, repository.TotalEstimate, result.TotalMode)
+}
+
+func TestFindUsersWithoutTotal(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		TotalMode:   repository.TotalNone,
+		Page:        3,
+		RowsPerPage: 10,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"})
+
+	mock.ExpectPrepare(`SELECT id, (.+), updated_by FROM "jobseeker" WHERE deleted_at IS NULL ORDER BY id ASC LIMIT \$1 OFFSET \$2`).ExpectQuery().
+		WithArgs(11, 20).
+		WillReturnRows(rows)
+
+	// WHEN
+	result, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 0, result.Total)
+	assert.Equal(t, repository.TotalNone, result.TotalMode)
+}
+
+func TestFindUsersWithoutTotalHasMore(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		TotalMode:   repository.TotalNone,
+		Page:        1,
+		RowsPerPage: 2,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("1", "Fernando", "Ocampo", "Cali", []byte("[]"), 1, time.Time{}, "", time.Time{}, "").
+		AddRow("2", "Mario", "Mendez", "Bogota", []byte("[]"), 1, time.Time{}, "", time.Time{}, "").
+		AddRow("3", "Luis", "Rojas", "Pasto", []byte("[]"), 1, time.Time{}, "", time.Time{}, "")
+
+	mock.ExpectPrepare(`SELECT id, (.+), updated_by FROM "jobseeker" WHERE deleted_at IS NULL ORDER BY id ASC LIMIT \$1 OFFSET \$2`).ExpectQuery().
+		WithArgs(3, 0).
+		WillReturnRows(rows)
+
+	// WHEN
+	result, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.True(t, result.HasMore)
+	assert.Len(t, result.Users, 2)
+	assert.Equal(t, "2", result.Users[1].ID)
+}
+
+func TestFindUsersFailsWhenTotalCannotBeCounted(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		Page:        2,
+		RowsPerPage: 10,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"})
+
+	mock.ExpectPrepare(`SELECT (.+), COUNT\(\*\) OVER\(\) FROM "jobseeker" WHERE deleted_at IS NULL ORDER BY id ASC LIMIT \$1 OFFSET \$2`).ExpectQuery().
+		WithArgs(11, 10).
+		WillReturnRows(rows)
+
+	mock.ExpectPrepare(`SELECT COUNT\(id\) FROM "jobseeker" WHERE deleted_at IS NULL;`).ExpectQuery().
+		WillReturnError(errors.New("connection reset by peer"))
+
+	// WHEN
+	_, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindUnavailable, users.KindOf(findError))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUsersBeforeCursor(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		Sort:        []repository.SortField{{Field: repository.SortByLastName, Descending: true}},
+		Cursor:      &repository.Cursor{Values: []string{"Mendez", "1"}, Backward: true},
+		RowsPerPage: 2,
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"}).
+		AddRow("2", "Oliver", "Vasquez", "Cali", []byte(`[]`), 1, time.Time{}, "", time.Time{}, "", 3).
+		AddRow("4", "Armando", "Zapata", "Cali", []byte(`[]`), 1, time.Time{}, "", time.Time{}, "", 3)
+
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" WHERE deleted_at IS NULL AND \(\(lastname > \$1\) OR \(lastname = \$1 AND id < \$2\)\) ORDER BY lastname ASC, id DESC LIMIT \$3`).ExpectQuery().
+		WithArgs("Mendez", "1", 3).
+		WillReturnRows(rows)
+
+	// WHEN
+	result, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, result.Users, 2)
+	assert.Equal(t, "4", result.Users[0].ID)
+	assert.Equal(t, "2", result.Users[1].ID)
+	assert.False(t, result.HasMore)
+}
+
+func TestFindUsersByText(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		Query: "Fernado Ocampo",
+		Sort: []repository.SortField{
+			{Field: repository.SortByRelevance, Descending: true},
+			{Field: repository.SortByID},
+		},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	whereClause := `WHERE \(search_vector @@ plainto_tsquery\('simple', lower\(unaccent\(\$1\)\)\) OR lower\(unaccent\(\$1\)\) <% search_text\) AND deleted_at IS NULL`
+	relevance := `ts_rank\(search_vector, plainto_tsquery\('simple', lower\(unaccent\(\$1\)\)\)\) \+ word_similarity\(lower\(unaccent\(\$1\)\), search_text\)`
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"})
+
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" `+whereClause+` ORDER BY `+relevance+` DESC, id ASC LIMIT \$2 OFFSET \$3`).ExpectQuery().
+		WithArgs("Fernado Ocampo", 11, 0).
+		WillReturnRows(rows)
+
+	// WHEN
+	_, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUsersWithFacets(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		City:        "Cali",
+		Facets:      []string{repository.FacetSkills, repository.FacetCity},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	expectedFacets := map[string][]repository.FacetValue{
+		repository.FacetSkills: {{Value: "Go", Count: 2}, {Value: "Rust", Count: 1}},
+		repository.FacetCity:   {{Value: "Cali", Count: 2}},
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	skillRows := sqlmock.NewRows([]string{"name", "count"}).
+		AddRow("Go", 2).
+		AddRow("Rust", 1)
+
+	mock.ExpectPrepare(`SELECT skill->>'name', COUNT\(DISTINCT id\) FROM "jobseeker" CROSS JOIN LATERAL jsonb_array_elements\(CASE jsonb_typeof\(skills\) WHEN 'array' THEN skills ELSE '\[\]' END\) AS skill WHERE city = \$1 AND deleted_at IS NULL GROUP BY 1 (.+) LIMIT 20;`).ExpectQuery().
+		WithArgs("Cali").
+		WillReturnRows(skillRows)
+
+	cityRows := sqlmock.NewRows([]string{"city", "count"}).
+		AddRow("Cali", 2)
+
+	mock.ExpectPrepare(`SELECT city, COUNT\(id\) FROM "jobseeker" WHERE city = \$1 AND deleted_at IS NULL GROUP BY 1 (.+) LIMIT 20;`).ExpectQuery().
+		WithArgs("Cali").
+		WillReturnRows(cityRows)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"})
+
+	mock.ExpectPrepare(`SELECT (.+) FROM "jobseeker" WHERE city = \$1 AND deleted_at IS NULL ORDER BY id ASC LIMIT \$2 OFFSET \$3`).ExpectQuery().
+		WithArgs("Cali", 11, 0).
+		WillReturnRows(rows)
+
+	// WHEN
+	result, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, expectedFacets, result.Facets)
+}
+
+func TestFindUsersWithAnyAndNoneSkills(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		SkillsAny:   repository.Skills{{Name: "Go"}, {Name: "Rust"}},
+		SkillsNone:  repository.Skills{{Name: "Kotlin"}},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	whereClause := `WHERE skills @> ANY\(\$1::jsonb\[\]\) AND NOT COALESCE\(skills @> ANY\(\$2::jsonb\[\]\), false\) AND deleted_at IS NULL`
+	anySkills := pq.StringArray{`[{"name":"Go"}]`, `[{"name":"Rust"}]`}
+	noneSkills := pq.StringArray{`[{"name":"Kotlin"}]`}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"})
+
+	mock.ExpectPrepare("SELECT (.+) FROM \"jobseeker\" "+whereClause+` ORDER BY id ASC LIMIT \$3 OFFSET \$4`).ExpectQuery().
+		WithArgs(anySkills, noneSkills, 11, 0).
+		WillReturnRows(rows)
+
+	// WHEN
+	_, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUsersBySkillRequirement(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		City: "Cali",
+		SkillRequirements: []repository.SkillRequirement{
+			{Name: "Go", Levels: []string{"advanced", "expert"}},
+			{Name: "PostgreSQL", MinYears: 3},
+		},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	expectedResult := repository.FindUsersResult{
+		Users: []repository.User{
+			{
+				ID:        "125",
+				City:      "Cali",
+				FirstName: "Cecilia",
+				LastName:  "Quiroga",
+				Skills: repository.Skills{
+					{Name: "Go", Level: "expert", Years: 6},
+					{Name: "PostgreSQL", Level: "intermediate", Years: 4},
+				},
+				Version:   1,
+				CreatedAt: stampedAt,
+				CreatedBy: stampedBy,
+				UpdatedAt: stampedAt,
+				UpdatedBy: stampedBy,
+			},
+		},
+		Total:       1,
+		Page:        1,
+		RowsPerPage: 10,
+		TotalMode:   repository.TotalExact,
+	}
+	requirementCondition := `EXISTS \(SELECT 1 FROM jsonb_array_elements\(CASE jsonb_typeof\(skills\) WHEN 'array' THEN skills ELSE '\[\]' END\) AS skill WHERE skill->>'name' = \$%d AND \(cardinality\(\$%d::text\[\]\) = 0 OR skill->>'level' = ANY\(\$%d::text\[\]\)\) AND COALESCE\(\(skill->>'years'\)::int, 0\) >= \$%d\)`
+	whereClause := "WHERE city = \\$1 AND " + fmt.Sprintf(requirementCondition, 2, 3, 3, 4) + " AND " + fmt.Sprintf(requirementCondition, 5, 6, 6, 7) + " AND deleted_at IS NULL"
+	args := []driver.Value{"Cali", "Go", pq.StringArray{"advanced", "expert"}, 0, "PostgreSQL", pq.StringArray{}, 3}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by", "total"}).
+		AddRow("125", "Cecilia", "Quiroga", "Cali", []byte(`[{"name":"Go","level":"expert","years":6},{"name":"PostgreSQL","level":"intermediate","years":4}]`), 1, stampedAt, stampedBy, stampedAt, stampedBy, 1)
+
+	mock.ExpectPrepare("SELECT (.+) FROM \"jobseeker\" " + whereClause + " ORDER BY id ASC LIMIT \\$8 OFFSET \\$9").ExpectQuery().
+		WithArgs(append(args, 11, 0)...).
+		WillReturnRows(rows)
+
+	// WHEN
+	got, findError := userRepository.SearchWithFilters(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.Equal(t, expectedResult, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUserHistory(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.HistoryFilter{
+		UserID:      "123",
+		Page:        2,
+		RowsPerPage: 1,
+	}
+	expectedResult := repository.FindHistoryResult{
+		Entries: []repository.AuditEntry{
+			{
+				UserID:    "123",
+				Operation: repository.AuditCreate,
+				Actor:     stampedBy,
+				ChangedAt: stampedAt,
+				Changes: repository.FieldChanges{
+					{Field: "city", Before: nil, After: "Cali"},
+				},
+			},
+		},
+		Total:       2,
+		Page:        2,
+		RowsPerPage: 1,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	mock.ExpectQuery("SELECT COUNT(.+) FROM \"jobseeker_audit\"").
+		WithArgs("123").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	rows := sqlmock.NewRows([]string{"jobseeker_id", "operation", "actor", "changed_at", "changes"}).
+		AddRow("123", "create", stampedBy, stampedAt, []byte(`[{"field":"city","before":null,"after":"Cali"}]`))
+	mock.ExpectQuery("SELECT (.+) FROM \"jobseeker_audit\" WHERE jobseeker_id = (.+) ORDER BY id DESC").
+		WithArgs("123", 1, 1).
+		WillReturnRows(rows)
+
+	// WHEN
+	got, findError := userRepository.FindHistory(ctx, givenFilter)
+
+	assert.NoError(t, findError)
+	assert.Equal(t, expectedResult, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// stampedAt and stampedBy creation and change stamps of the users in the tests.
+var stampedAt = time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+const stampedBy = "recruiter-1"
+
+// stateChange deletion, restoration or purge of the given user stamped with
+// stampedAt and stampedBy.
+func stateChange(userID string) repository.UserStateChange {
+	return repository.UserStateChange{ID: userID, UpdatedAt: stampedAt, UpdatedBy: stampedBy}
+}
+
+func TestExportUsers(t *testing.T) {
+	ctx := context.TODO()
+	givenFilter := repository.UserFilter{
+		City:        "Cali",
+		Page:        2,
+		RowsPerPage: 10,
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock)
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte(`["painter"]`), 1, stampedAt, stampedBy, stampedAt, stampedBy).
+		AddRow("456", "Alicia", "Mendez", "Cali", []byte("[]"), 1, stampedAt, stampedBy, stampedAt, stampedBy)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE user_export NO SCROLL CURSOR FOR SELECT (.+) FROM "jobseeker" WHERE (.+) ORDER BY id ASC$`).
+		WithArgs("Cali").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FETCH 500 FROM user_export`).
+		WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	// WHEN
+	var exported []string
+	exportError := userRepository.ExportWithFilters(ctx, givenFilter, func(user repository.User) error {
+		exported = append(exported, user.ID)
+		return nil
+	})
+
+	assert.NoError(t, exportError)
+	assert.Equal(t, []string{"123", "456"}, exported)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindUserInSchemaAndTable(t *testing.T) {
+	ctx := context.TODO()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	userRepository := newUserRepository(t, db, mock, postgresql.WithSchema("staging"), postgresql.WithTable(`candidate "users"`))
+
+	rows := sqlmock.NewRows([]string{"id", "firstname", "lastname", "city", "skills", "version", "created_at", "created_by", "updated_at", "updated_by"}).
+		AddRow("123", "Alonso", "Ojeda", "Cali", []byte("[]"), 1, stampedAt, stampedBy, stampedAt, stampedBy)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "staging"."candidate ""users""" WHERE id = $1`)).
+		WithArgs("123").
+		WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(id) FROM "staging"."candidate ""users""_audit" WHERE jobseeker_id = $1`)).
+		WithArgs("123").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "staging"."candidate ""users""_audit" WHERE jobseeker_id = $1`)).
+		WithArgs("123", 10, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"jobseeker_id", "operation", "actor", "changed_at", "changes"}))
+
+	// WHEN
+	user, findError := userRepository.FindByID(ctx, "123")
+	_, historyError := userRepository.FindHistory(ctx, repository.HistoryFilter{UserID: "123", Page: 1, RowsPerPage: 10})
+
+	assert.NoError(t, findError)
+	assert.Equal(t, "Alonso", user.FirstName)
+	assert.NoError(t, historyError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newUserRepository creates a user repository on the given mock, expecting
+// the statements it prepares when it is created.
+func newUserRepository(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock, options ...postgresql.Option) *postgresql.UserRDB {
+	t.Helper()
+	for _, statement := range fixedUserStatements {
+		mock.ExpectPrepare(statement)
+	}
+	userRepository, err := postgresql.NewUserRepository(db, options...)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when creating the user repository", err)
+	}
+	return userRepository
+}
+
+// fixedUserStatements statements the user repository prepares when it is
+// created, in order.
+var fixedUserStatements = []string{
+	`INSERT INTO (.+)\(id,firstname`,
+	`UPDATE (.+) SET firstname`,
+	`SELECT (.+) WHERE id = \$1 AND deleted_at IS NULL$`,
+	`SELECT (.+) FOR UPDATE`,
+	`UPDATE (.+) SET deleted_at = \$2`,
+	`UPDATE (.+) SET deleted_at = NULL`,
+	`DELETE FROM`,
+	`INSERT INTO (.+)\(jobseeker_id`,
+	`UPDATE (.+)_audit" SET changes = '\[\]'`,
+	`SELECT COUNT\(id\) FROM (.+) WHERE jobseeker_id`,
+	`SELECT jobseeker_id`,
 }
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Audited operations.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditPatch   = "patch"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// Audited fields.
+const (
+	firstNameField = "first_name"
+	lastNameField  = "last_name"
+	cityField      = "city"
+	skillsField    = "skills"
+)
+
+// FieldChange contains the value of a user field before and after a change.
+// Before is nil when the user was created.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// FieldChanges field level changes of a user.
+type FieldChanges []FieldChange
+
+// AuditEntry describes a change on a user, who made it, when and what changed.
+type AuditEntry struct {
+	UserID    string
+	Operation string
+	Actor     string
+	ChangedAt time.Time
+	Changes   FieldChanges
+}
+
+// HistoryFilter contains filters to read the audit history of a user.
+type HistoryFilter struct {
+	UserID string
+	// Page page to query
+	Page int
+	// rows per page
+	RowsPerPage int
+}
+
+// FindHistoryResult contains the audit entries found plus some metadata.
+type FindHistoryResult struct {
+	Entries     []AuditEntry
+	Total       int
+	Page        int
+	RowsPerPage int
+}
+
+// NewCreateAuditEntry builds the audit entry of the creation of the given user.
+func NewCreateAuditEntry(user User) AuditEntry {
+	return AuditEntry{
+		UserID:    user.ID,
+		Operation: AuditCreate,
+		Actor:     user.CreatedBy,
+		ChangedAt: user.CreatedAt,
+		Changes:   Diff(nil, user),
+	}
+}
+
+// NewUpdateAuditEntry builds the audit entry of a change from before to after
+// with the given operation.
+func NewUpdateAuditEntry(operation string, before, after User) AuditEntry {
+	return AuditEntry{
+		UserID:    after.ID,
+		Operation: operation,
+		Actor:     after.UpdatedBy,
+		ChangedAt: after.UpdatedAt,
+		Changes:   Diff(&before, after),
+	}
+}
+
+// NewStateAuditEntry builds the audit entry of the deletion, restoration or
+// purge of a user, none of its fields change.
+func NewStateAuditEntry(operation string, change UserStateChange) AuditEntry {
+	return AuditEntry{
+		UserID:    change.ID,
+		Operation: operation,
+		Actor:     change.UpdatedBy,
+		ChangedAt: change.UpdatedAt,
+		Changes:   make(FieldChanges, 0),
+	}
+}
+
+// Diff returns the fields whose value changed from before to after,
+// a nil before means that every non empty field of after is new.
+func Diff(before *User, after User) FieldChanges {
+	changes := make(FieldChanges, 0, 4)
+	addChange := func(field string, beforeValue, afterValue interface{}) {
+		if before == nil {
+			beforeValue = nil
+		}
+		changes = append(changes, FieldChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+	var previous User
+	if before != nil {
+		previous = *before
+	}
+	if previous.FirstName != after.FirstName {
+		addChange(firstNameField, previous.FirstName, after.FirstName)
+	}
+	if previous.LastName != after.LastName {
+		addChange(lastNameField, previous.LastName, after.LastName)
+	}
+	if previous.City != after.City {
+		addChange(cityField, previous.City, after.City)
+	}
+	if !previous.Skills.equal(after.Skills) {
+		addChange(skillsField, previous.Skills, after.Skills)
+	}
+	return changes
+}
+
+// equal checks if both skill lists contain the same skills in the same order.
+func (s Skills) equal(other Skills) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Value make the FieldChanges type implement the driver.Valuer interface.
+func (f FieldChanges) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// Scan make the FieldChanges type implement the sql.Scanner interface.
+func (f *FieldChanges) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, f)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Aliases other names of a catalog skill.
+type Aliases []string
+
+// Skill contains a canonical skill of the catalog and the aliases
+// that mean the same skill.
+type Skill struct {
+	// Name canonical name of the skill.
+	Name string `json:"name"`
+	// Aliases other names of the skill.
+	Aliases Aliases `json:"aliases"`
+}
+
+// Value make the Aliases type implement the driver.Valuer interface.
+func (a Aliases) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan make the Aliases type implement the sql.Scanner interface.
+func (a *Aliases) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, a)
+}
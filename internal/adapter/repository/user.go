@@ -4,10 +4,31 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 )
 
+// UserSkill contains a skill of a user and how good the user is at it.
+type UserSkill struct {
+	// Name canonical name of the skill.
+	Name string `json:"name"`
+	// Level proficiency level, empty if unknown.
+	Level string `json:"level,omitempty"`
+	// Years years of experience with the skill.
+	Years int `json:"years,omitempty"`
+}
+
 // Skills user skills type
-type Skills []string
+type Skills []UserSkill
+
+// SkillRequirement minimum proficiency a user must have on a skill.
+type SkillRequirement struct {
+	// Name canonical name of the skill.
+	Name string
+	// Levels accepted proficiency levels, any level if it is empty.
+	Levels []string
+	// MinYears minimum years of experience with the skill.
+	MinYears int
+}
 
 // User contains user data.
 type User struct {
@@ -20,6 +41,66 @@ type User struct {
 	LastName string `json:"last_name"`
 	// Skill skill of the user.
 	Skills Skills `json:"skills"`
+	// Version row version, it increases with every change. On updates it
+	// must contain the version the change was based on.
+	Version int `json:"version"`
+	// CreatedAt moment the user was created.
+	CreatedAt time.Time `json:"created_at"`
+	// CreatedBy identity of who created the user.
+	CreatedBy string `json:"created_by"`
+	// UpdatedAt moment of the last change of the user.
+	UpdatedAt time.Time `json:"updated_at"`
+	// UpdatedBy identity of who made the last change of the user.
+	UpdatedBy string `json:"updated_by"`
+}
+
+// UserPatch contains the fields of a user that changed, a nil field
+// means that it must not be written.
+type UserPatch struct {
+	ID string
+	// Version version of the user the patch was based on.
+	Version   int
+	FirstName *string
+	LastName  *string
+	City      *string
+	Skills    *Skills
+	// UpdatedAt and UpdatedBy stamp the change, they are not considered changes.
+	UpdatedAt time.Time
+	UpdatedBy string
+}
+
+// UserStateChange deletion, restoration or purge of a user, who made it and
+// when.
+type UserStateChange struct {
+	ID        string
+	UpdatedAt time.Time
+	UpdatedBy string
+}
+
+// IsEmpty returns true if the patch doesn't contain any change.
+func (u UserPatch) IsEmpty() bool {
+	return u.FirstName == nil && u.LastName == nil && u.City == nil && u.Skills == nil
+}
+
+// Apply applies the patch changes on the given user.
+func (u UserPatch) Apply(user User) User {
+	if u.FirstName != nil {
+		user.FirstName = *u.FirstName
+	}
+	if u.LastName != nil {
+		user.LastName = *u.LastName
+	}
+	if u.City != nil {
+		user.City = *u.City
+	}
+	if u.Skills != nil {
+		user.Skills = *u.Skills
+	}
+	if !u.UpdatedAt.IsZero() {
+		user.UpdatedAt = u.UpdatedAt
+		user.UpdatedBy = u.UpdatedBy
+	}
+	return user
 }
 
 // FindUsersResult contains the list of users found plus some metadata.
@@ -28,14 +109,128 @@ type FindUsersResult struct {
 	Total       int
 	Page        int
 	RowsPerPage int
+	// HasMore true if there are more users after the page, in the direction
+	// of the cursor if the search has one.
+	HasMore bool
+	// Facets counts of users found by value of every facet asked in the
+	// filter, keyed by facet.
+	Facets map[string][]FacetValue
+	// TotalMode how Total was counted, TotalNone if it was not.
+	TotalMode string
+}
+
+// Ways the total of the users found can be counted.
+const (
+	// TotalExact counts every user found.
+	TotalExact = "exact"
+	// TotalEstimate estimates the users found, it is cheaper than counting
+	// them when there are a lot.
+	TotalEstimate = "estimate"
+	// TotalNone doesn't count the users found, the total is zero.
+	TotalNone = "none"
+)
+
+// Facets users found can be counted by.
+const (
+	FacetSkills = "skills"
+	FacetCity   = "city"
+)
+
+// FacetLimit max number of values returned per facet, the most common ones.
+const FacetLimit = 20
+
+// FacetValue number of users found with a value of a facet.
+type FacetValue struct {
+	Value string
+	Count int
+}
+
+// Sortable user fields.
+const (
+	SortByID        = "id"
+	SortByFirstName = "first_name"
+	SortByLastName  = "last_name"
+	SortByCity      = "city"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	// SortByRelevance sorts by how well users match the search text, it only
+	// makes sense with a Query, descending puts the best matches first.
+	SortByRelevance = "relevance"
+)
+
+// SortField field to sort users by and its direction.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Cursor position of a user in a sorted search, the page starts right after
+// the user or, going backward, it ends right before the user.
+type Cursor struct {
+	// Values values of the sort fields of the user, in the order given by StableSort.
+	Values []string
+	// Backward true if the page goes before the user.
+	Backward bool
+}
+
+// StableSort returns the given sort fields ending with id, fields after id are
+// removed because id is unique.
+func StableSort(fields []SortField) []SortField {
+	stable := make([]SortField, 0, len(fields)+1)
+	for _, v := range fields {
+		stable = append(stable, v)
+		if v.Field == SortByID {
+			return stable
+		}
+	}
+	return append(stable, SortField{Field: SortByID})
+}
+
+// SortValue returns the value of the given sort field of the user as it is kept
+// in cursors, moments are written in RFC 3339 format with nanoseconds.
+func SortValue(user User, field string) string {
+	switch field {
+	case SortByFirstName:
+		return user.FirstName
+	case SortByLastName:
+		return user.LastName
+	case SortByCity:
+		return user.City
+	case SortByCreatedAt:
+		return user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortByUpdatedAt:
+		return user.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return user.ID
 }
 
 // UserFilter contains filters to search users
 type UserFilter struct {
 	// City user's city.
 	City string
-	// Skill skill of the user.
+	// Skills the users must have all of them.
 	Skills Skills
+	// SkillsAny the users must have at least one of them.
+	SkillsAny Skills
+	// SkillsNone the users must not have any of them.
+	SkillsNone Skills
+	// SkillRequirements proficiency the users must have on some skills.
+	SkillRequirements []SkillRequirement
+	// UpdatedSince only users changed at or after this moment.
+	UpdatedSince time.Time
+	// Query text to look for in the name and skills of the users, accents
+	// and small typos are ignored.
+	Query string
+	// Sort fields to sort the users by, id is always the last one so the
+	// order is stable between pages.
+	Sort []SortField
+	// Cursor when it is set the page is read from the cursor position
+	// instead of using Page.
+	Cursor *Cursor
+	// Facets facets to count the users found by, none if it is empty.
+	Facets []string
+	// TotalMode how the users found are counted, TotalExact if it is empty.
+	TotalMode string
 	// Page page to query
 	Page int
 	// rows per page
@@ -45,6 +240,11 @@ type UserFilter struct {
 // Value make the Skills struct implement the driver.Valuer interface. This method
 // simply returns the JSON-encoded representation of the struct.
 func (s Skills) Value() (driver.Value, error) {
+	if s == nil {
+		// users without skills have an empty array, a json null cannot be
+		// searched as an array.
+		return []byte("[]"), nil
+	}
 	return json.Marshal(s)
 }
 
@@ -58,3 +258,21 @@ func (s *Skills) Scan(value interface{}) error {
 
 	return json.Unmarshal(b, s)
 }
+
+// UnmarshalJSON decodes a skill, skills stored before levels existed were
+// plain strings with the skill name.
+func (u *UserSkill) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*u = UserSkill{Name: name}
+		return nil
+	}
+	type userSkill UserSkill
+	var skill userSkill
+	err := json.Unmarshal(data, &skill)
+	if err != nil {
+		return err
+	}
+	*u = UserSkill(skill)
+	return nil
+}
@@ -1,22 +1,67 @@
 package web
 
 import (
+	"bufio"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/fernandoocampo/users-micro/internal/users"
 	"github.com/gorilla/mux"
 )
 
+// Media types supported to patch a user, plain json is read as a merge patch.
+const (
+	jsonPatchMediaType  = "application/json-patch+json"
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonMediaType       = "application/json"
+)
+
+// patchMediaTypesMessage describes the media types supported to patch a user.
+const patchMediaTypesMessage = "Content-Type must be " + mergePatchMediaType + ", " + jsonPatchMediaType + " or " + jsonMediaType
+
+// Media types supported to import users.
+const (
+	csvMediaType    = "text/csv"
+	ndjsonMediaType = "application/x-ndjson"
+)
+
+// Import limits.
+const (
+	// maxImportBodySize max size of an import request body.
+	maxImportBodySize = 32 << 20
+	// maxImportLineSize max size of a line of a NDJSON import.
+	maxImportLineSize = 1 << 20
+)
+
+// actorHeader header that carries the identity of the caller.
+const actorHeader = "X-Actor-ID"
+
+// actorToContext puts the identity of the caller, if any, in the request context.
+func actorToContext(ctx context.Context, r *http.Request) context.Context {
+	actorID := strings.TrimSpace(r.Header.Get(actorHeader))
+	if actorID == "" {
+		return ctx
+	}
+	return users.WithActor(ctx, actorID)
+}
+
 func decodeGetUserWithIDRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	v := mux.Vars(r)
 	userIDParam, ok := v["id"]
 	if !ok {
-		return nil, errors.New("user ID was not provided")
+		return nil, newInvalidRequestError("user ID was not provided", nil)
 	}
 	return userIDParam, nil
 }
@@ -40,37 +85,155 @@ func decodeSearchUsersRequest(ctx context.Context, r *http.Request) (interface{}
 		thereAreSkills = false
 	}
 	if thereAreSkills {
-		skills := r.Form["skills"]
-		skillsToSearch := make([]string, 0)
-		for _, v := range skills {
-			skill := v
-			skillsToSearch = append(skillsToSearch, skill)
+		// skills is the former name of skills_all.
+		skills := append(r.Form["skills"], r.Form["skills_all"]...)
+		if len(skills) > 0 {
+			filterRequest.Skills = skills
 		}
-		filterRequest.Skills = skills
+		filterRequest.SkillsAny = r.Form["skills_any"]
+		filterRequest.SkillsNone = r.Form["skills_none"]
 	}
 
-	if v, ok := filters["page"]; ok {
-		page, err := strconv.Atoi(v[0])
+	for _, v := range filters["skill_level"] {
+		name, level, err := splitSkillRequirement(v)
 		if err != nil {
-			log.Println("level", "ERROR", "invalid page parameter, it must be an integer", "error", err)
-			page = 1
+			log.Println("level", "ERROR", "msg", "invalid skill_level parameter, it must be skill:level", "value", v)
+			return nil, newInvalidRequestError("skill_level must be skill:level, e.g. Go:advanced", err)
 		}
-		filterRequest.Page = page
+		filterRequest.SkillRequirements = append(filterRequest.SkillRequirements, users.SkillRequirement{
+			Name:     name,
+			MinLevel: level,
+		})
 	}
-	if v, ok := filters["pagesize"]; ok {
-		pageSize, err := strconv.Atoi(v[0])
+
+	for _, v := range filters["skill_years"] {
+		name, value, err := splitSkillRequirement(v)
+		var years int
+		if err == nil {
+			years, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "invalid skill_years parameter, it must be skill:years", "value", v)
+			return nil, newInvalidRequestError("skill_years must be skill:years, e.g. PostgreSQL:3", err)
+		}
+		filterRequest.SkillRequirements = append(filterRequest.SkillRequirements, users.SkillRequirement{
+			Name:     name,
+			MinYears: years,
+		})
+	}
+
+	if v, ok := filters["updated_since"]; ok {
+		updatedSince, err := time.Parse(time.RFC3339, v[0])
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "invalid updated_since parameter, it must be a RFC 3339 date", "error", err)
+			return nil, newInvalidRequestError("updated_since must be a RFC 3339 date", err)
+		}
+		filterRequest.UpdatedSince = updatedSince
+	}
+
+	if v, ok := filters["q"]; ok {
+		filterRequest.Query = v[0]
+	}
+
+	if v, ok := filters["sort"]; ok {
+		filterRequest.Sort, err = decodeSort(v[0])
 		if err != nil {
-			log.Println("level", "ERROR", "invalid page size parameter, it must be an integer", "error", err)
-			pageSize = 10
+			log.Println("level", "ERROR", "msg", "invalid sort parameter", "value", v[0], "error", err)
+			return nil, newInvalidRequestError("sort must be a comma separated list of fields, e.g. last_name,-updated_at", err)
 		}
-		filterRequest.PageSize = pageSize
 	}
 
+	if v, ok := filters["facets"]; ok {
+		for _, facet := range strings.Split(v[0], ",") {
+			if facet = strings.TrimSpace(facet); facet != "" {
+				filterRequest.Facets = append(filterRequest.Facets, facet)
+			}
+		}
+	}
+
+	if v, ok := filters["total"]; ok {
+		filterRequest.TotalMode = strings.TrimSpace(v[0])
+	}
+
+	if v, ok := filters["cursor"]; ok {
+		filterRequest.Cursor = v[0]
+	}
+
+	filterRequest.Page, filterRequest.PageSize = decodePagination(filters)
+
 	filter := filterRequest.toSearchUserFilter()
 
 	return filter, nil
 }
 
+// decodeSort decodes a comma separated list of fields, a field with a leading
+// minus sign is sorted in descending order.
+func decodeSort(param string) ([]users.SortField, error) {
+	fields := strings.Split(param, ",")
+	sortFields := make([]users.SortField, 0, len(fields))
+	for _, v := range fields {
+		field := users.SortField{
+			Field: strings.TrimSpace(v),
+		}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Field = field.Field[1:]
+			field.Descending = true
+		}
+		if field.Field == "" {
+			return nil, fmt.Errorf("%q contains an empty field", param)
+		}
+		sortFields = append(sortFields, field)
+	}
+	return sortFields, nil
+}
+
+// splitSkillRequirement splits a skill:value search parameter, the value
+// goes after the last colon so skill names can contain colons.
+func splitSkillRequirement(param string) (string, string, error) {
+	separator := strings.LastIndex(param, ":")
+	if separator <= 0 || separator == len(param)-1 {
+		return "", "", fmt.Errorf("%q has no skill:value format", param)
+	}
+	return param[:separator], param[separator+1:], nil
+}
+
+// decodeUserHistoryRequest decodes the user id in the path and the page to read.
+func decodeUserHistoryRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	v := mux.Vars(r)
+	userID, ok := v["id"]
+	if !ok {
+		return nil, newInvalidRequestError("user ID was not provided", nil)
+	}
+	filter := users.UserHistoryFilter{
+		UserID: userID,
+	}
+	filter.Page, filter.RowsPerPage = decodePagination(r.URL.Query())
+	return filter, nil
+}
+
+// decodePagination reads the page and page size query parameters, invalid
+// or missing values fall back to the first page of ten rows.
+func decodePagination(filters url.Values) (int, int) {
+	page, pageSize := 1, 10
+	if v, ok := filters["page"]; ok {
+		value, err := strconv.Atoi(v[0])
+		if err != nil || value < 1 {
+			log.Println("level", "ERROR", "invalid page parameter, it must be a positive integer", "value", v[0])
+		} else {
+			page = value
+		}
+	}
+	if v, ok := filters["pagesize"]; ok {
+		value, err := strconv.Atoi(v[0])
+		if err != nil || value < 1 {
+			log.Println("level", "ERROR", "invalid page size parameter, it must be a positive integer", "value", v[0])
+		} else {
+			pageSize = value
+		}
+	}
+	return page, pageSize
+}
+
 func decodeCreateUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	log.Println("level", "DEBUG", "msg", "decoding new user request")
 	var req NewUser
@@ -78,13 +241,13 @@ func decodeCreateUserRequest(ctx context.Context, r *http.Request) (interface{},
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return nil, err
+		return nil, newInvalidRequestError("request body could not be read", err)
 	}
 
 	err = json.Unmarshal(body, &req)
 	if err != nil {
 		log.Println("level", "ERROR", "new user request could not be decoded. Request: %q because of: %s", string(body), err.Error())
-		return nil, err
+		return nil, newInvalidRequestError("request body is not valid json", err)
 	}
 
 	log.Println("level", "DEBUG", "msg", "user request was decoded", "request", req)
@@ -94,6 +257,63 @@ func decodeCreateUserRequest(ctx context.Context, r *http.Request) (interface{},
 	return domainUser, nil
 }
 
+// decodeVersion reads the user versions the request is based on from the
+// If-Match header, a single ETag is returned as version and a list of them as
+// versions. It returns zero if the header was not provided. The * wildcard is
+// rejected because changes must be based on a version the client has read.
+func decodeVersion(r *http.Request) (int, []int, error) {
+	versions := make([]int, 0)
+	for _, value := range r.Header.Values("If-Match") {
+		for _, etag := range strings.Split(value, ",") {
+			etag = strings.TrimSpace(etag)
+			if etag == "" {
+				continue
+			}
+			if etag == "*" {
+				return 0, nil, errAnyVersion
+			}
+			etag = strings.TrimPrefix(etag, "W/")
+			version, err := strconv.Atoi(strings.Trim(etag, `"`))
+			if err != nil || version < 1 {
+				return 0, nil, newInvalidRequestError("If-Match header must contain valid user ETags", err)
+			}
+			if !containsVersion(versions, version) {
+				versions = append(versions, version)
+			}
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return 0, nil, nil
+	case 1:
+		return versions[0], nil, nil
+	}
+	return 0, versions, nil
+}
+
+// errAnyVersion is returned when If-Match is *, a change must say which
+// version of the user it is based on.
+var errAnyVersion = &users.Error{
+	Kind:    users.ErrorKindPreconditionRequired,
+	Code:    users.ErrorCodeVersionRequired,
+	Message: "If-Match: * is not supported, send the ETag of the version of the user the change is based on",
+}
+
+// containsVersion tells whether the given version is one of the versions.
+func containsVersion(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeVersion transforms a user version into an ETag.
+func encodeVersion(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
 func decodeUpdateUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	log.Println("level", "DEBUG", "msg", "decoding update user request")
 	var req UpdateUser
@@ -101,18 +321,323 @@ func decodeUpdateUserRequest(ctx context.Context, r *http.Request) (interface{},
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return nil, err
+		return nil, newInvalidRequestError("request body could not be read", err)
 	}
 
 	err = json.Unmarshal(body, &req)
 	if err != nil {
 		log.Println("level", "ERROR", "update user request could not be decoded. Request: %q because of: %s", string(body), err.Error())
-		return nil, err
+		return nil, newInvalidRequestError("request body is not valid json", err)
 	}
 
 	log.Println("level", "DEBUG", "msg", "user request was decoded", "request", req)
 
 	domainUser := req.toUser()
 
+	domainUser.Version, domainUser.Versions, err = decodeVersion(r)
+	if err != nil {
+		return nil, err
+	}
+
 	return domainUser, nil
 }
+
+// decodePatchUserRequest decodes a RFC 7386 merge patch document or, when the
+// content type is application/json-patch+json, a RFC 6902 json patch document.
+func decodePatchUserRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	log.Println("level", "DEBUG", "msg", "decoding patch user request")
+	v := mux.Vars(r)
+	userID, ok := v["id"]
+	if !ok {
+		return nil, newInvalidRequestError("user ID was not provided", nil)
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newInvalidRequestError("request body could not be read", err)
+	}
+
+	version, versions, err := decodeVersion(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, newUnsupportedMediaTypeError(patchMediaTypesMessage, err)
+	}
+	switch mediaType {
+	case mergePatchMediaType, jsonMediaType, jsonPatchMediaType:
+	default:
+		return nil, newUnsupportedMediaTypeError(patchMediaTypesMessage, nil)
+	}
+
+	if mediaType == jsonPatchMediaType {
+		var operations []users.PatchOperation
+		err = json.Unmarshal(body, &operations)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "json patch request could not be decoded", "request", string(body), "error", err)
+			return nil, newInvalidRequestError("request body is not valid json", err)
+		}
+		return &users.PatchUser{ID: userID, Version: version, Versions: versions, Operations: operations}, nil
+	}
+
+	var document map[string]json.RawMessage
+	err = json.Unmarshal(body, &document)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "merge patch request could not be decoded", "request", string(body), "error", err)
+		return nil, newInvalidRequestError("request body is not valid json", err)
+	}
+	patch, err := users.NewMergePatchUser(userID, document)
+	if err != nil {
+		return nil, err
+	}
+	patch.Version = version
+	patch.Versions = versions
+	return patch, nil
+}
+
+func decodeListSkillsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// decodeSkillNameRequest decodes requests that only need the skill name in the path.
+func decodeSkillNameRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	v := mux.Vars(r)
+	name, ok := v["name"]
+	if !ok {
+		return nil, newInvalidRequestError("skill name was not provided", nil)
+	}
+	return name, nil
+}
+
+func decodeCreateSkillRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	log.Println("level", "DEBUG", "msg", "decoding new skill request")
+	var req Skill
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newInvalidRequestError("request body could not be read", err)
+	}
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "new skill request could not be decoded", "request", string(body), "error", err)
+		return nil, newInvalidRequestError("request body is not valid json", err)
+	}
+
+	return req.toSkill(), nil
+}
+
+// decodeUpdateSkillRequest decodes the new aliases of the skill named in the path.
+func decodeUpdateSkillRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	log.Println("level", "DEBUG", "msg", "decoding update skill request")
+	v := mux.Vars(r)
+	name, ok := v["name"]
+	if !ok {
+		return nil, newInvalidRequestError("skill name was not provided", nil)
+	}
+	var req Skill
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newInvalidRequestError("request body could not be read", err)
+	}
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "update skill request could not be decoded", "request", string(body), "error", err)
+		return nil, newInvalidRequestError("request body is not valid json", err)
+	}
+	req.Name = name
+
+	return req.toSkill(), nil
+}
+
+// decodeImportUsersRequest reads the users to import from a CSV or NDJSON
+// body. Rows that cannot be read are kept with their error so they are
+// reported with the rest.
+func decodeImportUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	log.Println("level", "DEBUG", "msg", "decoding import users request")
+	defer r.Body.Close()
+	body := &limitedBody{reader: r.Body, remaining: maxImportBodySize}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, newInvalidRequestError("Content-Type must be "+csvMediaType+" or "+ndjsonMediaType, err)
+	}
+	var rows []users.ImportRow
+	switch mediaType {
+	case csvMediaType:
+		rows, err = readCSVImport(body)
+	case ndjsonMediaType:
+		rows, err = readNDJSONImport(body)
+	default:
+		return nil, newInvalidRequestError("Content-Type must be "+csvMediaType+" or "+ndjsonMediaType, nil)
+	}
+	if errors.Is(err, errBodyTooLarge) {
+		return nil, newRequestTooLargeError(fmt.Sprintf("request body must not be larger than %d bytes", maxImportBodySize), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// errBodyTooLarge is returned by a limitedBody after its limit.
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedBody reads a request body up to a limit and fails with
+// errBodyTooLarge when the body is larger, unlike io.LimitReader that would
+// silently truncate it.
+type limitedBody struct {
+	reader    io.Reader
+	remaining int64
+}
+
+// Read implements io.Reader interface.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), errBodyTooLarge
+	}
+	return n, err
+}
+
+// readCSVImport reads users from a CSV with a header row, the columns are
+// first_name, last_name, city and skills, in any order. Skills are separated
+// by semicolons and written as name[:level[:years]], e.g. Go:advanced:5;SQL
+func readCSVImport(body io.Reader) ([]users.ImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, newInvalidRequestError("csv must start with a header row", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	for _, required := range []string{"first_name", "last_name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, newInvalidRequestError(fmt.Sprintf("csv header must have the %s column", required), nil)
+		}
+	}
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+	rows := make([]users.ImportRow, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row := users.ImportRow{Row: len(rows) + 1}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			row.Err = newInvalidRequestError(fmt.Sprintf("row %d is not valid csv", row.Row), err)
+			rows = append(rows, row)
+			continue
+		}
+		if err != nil {
+			return nil, newInvalidRequestError("request body could not be read", err)
+		}
+		skills, err := decodeCSVSkills(column(record, "skills"))
+		if err != nil {
+			row.Err = newInvalidRequestError(fmt.Sprintf("row %d has invalid skills: %s", row.Row, err), err)
+			rows = append(rows, row)
+			continue
+		}
+		newUser := NewUser{
+			FirstName: column(record, "first_name"),
+			LastName:  column(record, "last_name"),
+			City:      column(record, "city"),
+			Skills:    skills,
+		}
+		row.User = *newUser.toUser()
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeCSVSkills reads the skills of a CSV import row.
+func decodeCSVSkills(value string) ([]UserSkill, error) {
+	var skills []UserSkill
+	for _, v := range strings.Split(value, ";") {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		parts := strings.Split(v, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("%q must be name[:level[:years]]", v)
+		}
+		skill := UserSkill{Name: strings.TrimSpace(parts[0])}
+		if len(parts) > 1 {
+			skill.Level = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			years, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+			if err != nil {
+				return nil, fmt.Errorf("years of %q must be a number", v)
+			}
+			skill.Years = years
+		}
+		skills = append(skills, skill)
+	}
+	return skills, nil
+}
+
+// readNDJSONImport reads users from newline delimited JSON, a user per line
+// with the same fields of POST /users. Blank lines are skipped.
+func readNDJSONImport(body io.Reader) ([]users.ImportRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	rows := make([]users.ImportRow, 0)
+	// lineNumber counts the blank lines too, so rows are numbered as the
+	// lines of the file.
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		row := users.ImportRow{Row: lineNumber}
+		var newUser NewUser
+		err := json.Unmarshal(line, &newUser)
+		if err != nil {
+			row.Err = newInvalidRequestError(fmt.Sprintf("row %d is not valid json", row.Row), err)
+			rows = append(rows, row)
+			continue
+		}
+		row.User = *newUser.toUser()
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, newInvalidRequestError("request body could not be read", err)
+	}
+	return rows, nil
+}
+
+// decodeExportUsersRequest decodes the same filters of a search, the export
+// options are read again by the response encoder from the context.
+func decodeExportUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	log.Println("level", "DEBUG", "msg", "decoding export users request")
+	_, err := exportOptionsFromRequest(r)
+	if err != nil {
+		return nil, newInvalidRequestError(err.Error(), err)
+	}
+	return decodeSearchUsersRequest(ctx, r)
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 
+	"github.com/fernandoocampo/users-micro/internal/skills"
 	"github.com/fernandoocampo/users-micro/internal/users"
 )
 
@@ -17,19 +19,61 @@ func encodeCreateUserResponse(ctx context.Context, w http.ResponseWriter, respon
 		log.Println("level", "ERROR", "msg", "cannot transform to users.CreateUserResult", "received", fmt.Sprintf("%+v", response))
 		return errors.New("cannot build create user response")
 	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/users/"+result.ID)
+	w.WriteHeader(http.StatusCreated)
 	message := toCreateUserResponse(result)
 	return json.NewEncoder(w).Encode(message)
 }
 
+func encodeImportUsersResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.ImportUsersResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.ImportUsersResult", "received", fmt.Sprintf("%+v", response))
+		return errors.New("cannot build import users response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	message := toImportUsersResponse(result)
+	return json.NewEncoder(w).Encode(message)
+}
+
 func encodeUpdateUserResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	result, ok := response.(users.UpdateUserResult)
 	if !ok {
 		log.Println("level", "ERROR", "msg", "cannot transform to users.UpdateUserResult", "received", fmt.Sprintf("%+v", response))
 		return errors.New("cannot build update user response")
 	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", encodeVersion(result.Version))
+	message := toSuccessResponse()
+	return json.NewEncoder(w).Encode(message)
+}
+
+func encodePatchUserResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.PatchUserResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.PatchUserResult", "received", fmt.Sprintf("%+v", response))
+		return errors.New("cannot build patch user response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
 	w.Header().Set("Content-Type", "application/json")
-	message := toUpdateUserResponse(result)
+	w.Header().Set("ETag", encodeVersion(result.Version))
+	message := toSuccessResponse()
 	return json.NewEncoder(w).Encode(message)
 }
 
@@ -39,7 +83,14 @@ func encodeGetUserWithIDResponse(ctx context.Context, w http.ResponseWriter, res
 		log.Println("level", "ERROR", "msg", "cannot transform to users.GetUserWithIDResult", "received", fmt.Sprintf("%+v", response))
 		return errors.New("cannot build get user response")
 	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
 	w.Header().Set("Content-Type", "application/json")
+	if result.User != nil {
+		w.Header().Set("ETag", encodeVersion(result.User.Version))
+	}
 	message := toGetUserWithIDResponse(result)
 	return json.NewEncoder(w).Encode(message)
 }
@@ -50,7 +101,143 @@ func encodeSearchUsersResponse(ctx context.Context, w http.ResponseWriter, respo
 		log.Println("level", "ERROR", "msg", "cannot transform to users.SearchUsersDataResult", "received", fmt.Sprintf("%T", response))
 		return errors.New("cannot build search users response")
 	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
 	w.Header().Set("Content-Type", "application/json")
 	message := toSearchUsersResponse(result)
 	return json.NewEncoder(w).Encode(message)
 }
+
+func encodeUserHistoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.GetUserHistoryResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.GetUserHistoryResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build user history response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	message := toUserHistoryResponse(result)
+	return json.NewEncoder(w).Encode(message)
+}
+
+func encodeDeleteUserResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.DeleteUserResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.DeleteUserResult", "received", fmt.Sprintf("%+v", response))
+		return errors.New("cannot build delete user response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	message := toSuccessResponse()
+	return json.NewEncoder(w).Encode(message)
+}
+
+func encodeRestoreUserResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.RestoreUserResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.RestoreUserResult", "received", fmt.Sprintf("%+v", response))
+		return errors.New("cannot build restore user response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	message := toSuccessResponse()
+	return json.NewEncoder(w).Encode(message)
+}
+
+func encodePurgeUserResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.PurgeUserResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.PurgeUserResult", "received", fmt.Sprintf("%+v", response))
+		return errors.New("cannot build purge user response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	message := toSuccessResponse()
+	return json.NewEncoder(w).Encode(message)
+}
+
+func encodeListSkillsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(skills.ListSkillsResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to skills.ListSkillsResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build list skills response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(toListSkillsResponse(result))
+}
+
+func encodeGetSkillResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(skills.GetSkillResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to skills.GetSkillResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build get skill response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(toGetSkillResponse(result))
+}
+
+func encodeCreateSkillResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(skills.CreateSkillResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to skills.CreateSkillResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build create skill response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/skills/"+url.PathEscape(result.Name))
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(toCreateSkillResponse(result))
+}
+
+func encodeUpdateSkillResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(skills.UpdateSkillResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to skills.UpdateSkillResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build update skill response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(toSuccessResponse())
+}
+
+func encodeDeleteSkillResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(skills.DeleteSkillResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to skills.DeleteSkillResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build delete skill response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(toSuccessResponse())
+}
@@ -0,0 +1,328 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+// Export formats.
+const (
+	csvFormat    = "csv"
+	ndjsonFormat = "ndjson"
+	jsonFormat   = "json"
+)
+
+// exportMediaTypes media type of every export format.
+var exportMediaTypes = map[string]string{
+	csvFormat:    csvMediaType,
+	ndjsonFormat: ndjsonMediaType,
+	jsonFormat:   "application/json",
+}
+
+// exportColumns columns an export can have, in their default order.
+var exportColumns = []string{
+	"id", "first_name", "last_name", "city", "skills", "version",
+	"created_at", "created_by", "updated_at", "updated_by",
+}
+
+// exportFlushRows number of users written between flushes of the response.
+const exportFlushRows = 100
+
+// exportOptions how the users of an export are written.
+type exportOptions struct {
+	format  string
+	columns []string
+}
+
+type exportOptionsKey struct{}
+
+// exportOptionsToContext puts the export options of the request in the context
+// so the response encoder can read them. Invalid options are rejected by
+// decodeExportUsersRequest.
+func exportOptionsToContext(ctx context.Context, r *http.Request) context.Context {
+	options, _ := exportOptionsFromRequest(r)
+	return context.WithValue(ctx, exportOptionsKey{}, options)
+}
+
+// exportOptionsFromContext returns the export options of the request.
+func exportOptionsFromContext(ctx context.Context) exportOptions {
+	options, ok := ctx.Value(exportOptionsKey{}).(exportOptions)
+	if !ok {
+		return exportOptions{format: ndjsonFormat, columns: exportColumns}
+	}
+	return options
+}
+
+// exportOptionsFromRequest reads the format, from the format parameter or the
+// Accept header, NDJSON if none is given, and the columns parameter.
+func exportOptionsFromRequest(r *http.Request) (exportOptions, error) {
+	options := exportOptions{
+		format:  r.URL.Query().Get("format"),
+		columns: exportColumns,
+	}
+	if options.format == "" {
+		options.format = formatFromAccept(r.Header.Get("Accept"))
+	}
+	if _, ok := exportMediaTypes[options.format]; !ok {
+		return options, fmt.Errorf("format must be %s, %s or %s", csvFormat, ndjsonFormat, jsonFormat)
+	}
+	if param := r.URL.Query().Get("columns"); param != "" {
+		options.columns = make([]string, 0, len(exportColumns))
+		for _, v := range strings.Split(param, ",") {
+			column := strings.TrimSpace(v)
+			if !isExportColumn(column) {
+				return options, fmt.Errorf("%q is not a column, columns must be %s", column, strings.Join(exportColumns, ", "))
+			}
+			options.columns = append(options.columns, column)
+		}
+	}
+	return options, nil
+}
+
+// formatFromAccept returns the first export format accepted.
+func formatFromAccept(accept string) string {
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		for format, formatMediaType := range exportMediaTypes {
+			if mediaType == formatMediaType {
+				return format
+			}
+		}
+	}
+	return ndjsonFormat
+}
+
+func isExportColumn(column string) bool {
+	for _, v := range exportColumns {
+		if v == column {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeExportUsersResponse writes the users of the export as they are read.
+// The status is written with the first user, or when the export ends if there
+// is none, so an export that cannot be read at all fails with a problem. Once
+// the status is written it can't change anymore, so errors after it abort the
+// connection and the client sees an incomplete response, not a clean end.
+func encodeExportUsersResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	result, ok := response.(users.ExportUsersResult)
+	if !ok {
+		log.Println("level", "ERROR", "msg", "cannot transform to users.ExportUsersResult", "received", fmt.Sprintf("%T", response))
+		return errors.New("cannot build export users response")
+	}
+	if result.Err != nil {
+		encodeError(ctx, result.Err, w)
+		return nil
+	}
+	options := exportOptionsFromContext(ctx)
+	writer := newExportWriter(w, options)
+	flusher, _ := w.(http.Flusher)
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", exportMediaTypes[options.format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"users.%s\"", options.format))
+		w.WriteHeader(http.StatusOK)
+		err := writer.begin()
+		if err == nil && flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	}
+	err := result.Export.Each(ctx, func(user users.User) error {
+		if !started {
+			startErr := start()
+			if startErr != nil {
+				return startErr
+			}
+		}
+		writeErr := writer.write(toUser(&user))
+		if writeErr != nil || writer.rows%exportFlushRows != 0 {
+			return writeErr
+		}
+		writeErr = writer.flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return writeErr
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = writer.end()
+	}
+	if err != nil && !started {
+		log.Println("level", "ERROR", "msg", "users export could not be started", "error", err)
+		encodeError(ctx, err, w)
+		return nil
+	}
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "users export was interrupted", "written", writer.rows, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+// exportWriter writes the users of an export in a format.
+type exportWriter struct {
+	w       io.Writer
+	csv     *csv.Writer
+	format  string
+	columns []string
+	rows    int
+}
+
+func newExportWriter(w io.Writer, options exportOptions) *exportWriter {
+	writer := exportWriter{
+		w:       w,
+		format:  options.format,
+		columns: options.columns,
+	}
+	if options.format == csvFormat {
+		writer.csv = csv.NewWriter(w)
+	}
+	return &writer
+}
+
+// begin writes what goes before the first user.
+func (e *exportWriter) begin() error {
+	switch e.format {
+	case csvFormat:
+		return e.csv.Write(e.columns)
+	case jsonFormat:
+		_, err := io.WriteString(e.w, "[")
+		return err
+	}
+	return nil
+}
+
+// write writes a user.
+func (e *exportWriter) write(user *User) error {
+	e.rows++
+	if e.format == csvFormat {
+		record := make([]string, 0, len(e.columns))
+		for _, column := range e.columns {
+			record = append(record, csvValue(user, column))
+		}
+		return e.csv.Write(record)
+	}
+	var document strings.Builder
+	if e.format == jsonFormat && e.rows > 1 {
+		document.WriteString(",")
+	}
+	document.WriteString("{")
+	for i, column := range e.columns {
+		value, err := json.Marshal(columnValue(user, column))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			document.WriteString(",")
+		}
+		document.WriteString(strconv.Quote(column) + ":")
+		document.Write(value)
+	}
+	document.WriteString("}")
+	if e.format == ndjsonFormat {
+		document.WriteString("\n")
+	}
+	_, err := io.WriteString(e.w, document.String())
+	return err
+}
+
+// flush writes the users kept in buffers.
+func (e *exportWriter) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// end writes what goes after the last user.
+func (e *exportWriter) end() error {
+	switch e.format {
+	case csvFormat:
+		e.csv.Flush()
+		return e.csv.Error()
+	case jsonFormat:
+		_, err := io.WriteString(e.w, "]\n")
+		return err
+	}
+	return nil
+}
+
+// columnValue returns the value of a column of the user.
+func columnValue(user *User, column string) interface{} {
+	switch column {
+	case "id":
+		return user.ID
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case "city":
+		return user.City
+	case "skills":
+		if user.Skills == nil {
+			return []UserSkill{}
+		}
+		return user.Skills
+	case "version":
+		return user.Version
+	case "created_at":
+		return user.CreatedAt
+	case "created_by":
+		return user.CreatedBy
+	case "updated_at":
+		return user.UpdatedAt
+	case "updated_by":
+		return user.UpdatedBy
+	}
+	return nil
+}
+
+// csvValue returns the value of a column of the user as it is written in CSV,
+// skills are written as they are imported, e.g. Go:advanced:5;SQL
+func csvValue(user *User, column string) string {
+	switch value := columnValue(user, column).(type) {
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	case []UserSkill:
+		skills := make([]string, 0, len(value))
+		for _, v := range value {
+			skill := v.Name
+			switch {
+			case v.Years > 0:
+				skill += ":" + v.Level + ":" + strconv.Itoa(v.Years)
+			case v.Level != "":
+				skill += ":" + v.Level
+			}
+			skills = append(skills, skill)
+		}
+		return strings.Join(skills, ";")
+	}
+	return ""
+}
@@ -1,42 +1,75 @@
 package web
 
-import "github.com/fernandoocampo/users-micro/internal/users"
+import (
+	"encoding/json"
+	"errors"
+	"time"
 
-// Result standard result for the service
+	"github.com/fernandoocampo/users-micro/internal/skills"
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+// Result standard result for the service, failures are reported
+// as problem details instead.
 type Result struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data"`
-	Errors  []string    `json:"errors"`
+}
+
+// ResultError describes a field level error, field is only
+// set when the error is related to a specific field.
+type ResultError struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// UserSkill contains a skill of a user, its proficiency level and years
+// of experience. Clients can also send a skill as a plain string with its name.
+type UserSkill struct {
+	Name  string `json:"name"`
+	Level string `json:"level,omitempty"`
+	Years int    `json:"years,omitempty"`
 }
 
 // User contains user data.
 type User struct {
 	ID string `json:"id"`
+	// Version current version of the user, it is also sent as ETag.
+	Version int `json:"version"`
 	// City user's city.
 	City string `json:"city"`
 	// Skill skill of the user.
-	Skills []string `json:"skills"`
+	Skills []UserSkill `json:"skills"`
 	// FirstName name of the person who is owner of this user.
 	FirstName string `json:"first_name"`
 	// LastName last name of the person who is owner of this user.
 	LastName string `json:"last_name"`
+	// CreatedAt moment the user was created.
+	CreatedAt time.Time `json:"created_at"`
+	// CreatedBy identity of who created the user.
+	CreatedBy string `json:"created_by"`
+	// UpdatedAt moment of the last change of the user.
+	UpdatedAt time.Time `json:"updated_at"`
+	// UpdatedBy identity of who made the last change of the user.
+	UpdatedBy string `json:"updated_by"`
 }
 
 // NewUser contains the expected data for a new user.
 type NewUser struct {
-	FirstName string   `json:"first_name"`
-	LastName  string   `json:"last_name"`
-	City      string   `json:"city"`
-	Skills    []string `json:"skills"`
+	FirstName string      `json:"first_name"`
+	LastName  string      `json:"last_name"`
+	City      string      `json:"city"`
+	Skills    []UserSkill `json:"skills"`
 }
 
 // UpdateUser contains the expected data to update an user.
 type UpdateUser struct {
-	ID        string   `json:"id"`
-	FirstName string   `json:"first_name"`
-	LastName  string   `json:"last_name"`
-	City      string   `json:"city"`
-	Skills    []string `json:"skills"`
+	ID        string      `json:"id"`
+	FirstName string      `json:"first_name"`
+	LastName  string      `json:"last_name"`
+	City      string      `json:"city"`
+	Skills    []UserSkill `json:"skills"`
 }
 
 // CreateUserResponse standard response for create User
@@ -61,8 +94,26 @@ type SearchUsersResponse struct {
 type SearchUserFilter struct {
 	// City user's city.
 	City string
-	// Skill skill of the user.
+	// Skills the users must have all of them.
 	Skills []string
+	// SkillsAny the users must have at least one of them.
+	SkillsAny []string
+	// SkillsNone the users must not have any of them.
+	SkillsNone []string
+	// SkillRequirements proficiency the users must have on some skills.
+	SkillRequirements []users.SkillRequirement
+	// UpdatedSince only users changed at or after this moment.
+	UpdatedSince time.Time
+	// Query text to look for in the names and skills of the users.
+	Query string
+	// Sort fields to sort the users by.
+	Sort []users.SortField
+	// Cursor cursor of the page to read, it replaces Page.
+	Cursor string
+	// Facets facets to count the users found by.
+	Facets []string
+	// TotalMode how the users found are counted.
+	TotalMode string
 	// Page page to query
 	Page int
 	// rows per page
@@ -71,10 +122,67 @@ type SearchUserFilter struct {
 
 // SearchUsersResult contains search users result data.
 type SearchUsersResult struct {
-	Users    []User `json:"users"`
-	Total    int    `json:"total"`
-	Page     int    `json:"page"`
-	PageSize int    `json:"page_size"`
+	Users      []User `json:"users"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// Facets counts of users found by facet value, only the facets asked.
+	Facets map[string][]FacetValue `json:"facets,omitempty"`
+	// TotalMode how total was counted if it is not exact: estimate, or none
+	// if the users found were not counted.
+	TotalMode string `json:"total_mode,omitempty"`
+}
+
+// ImportUsersReport contains the result of every imported row.
+type ImportUsersReport struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// ImportRowResult result of importing a row, the id of the user created or
+// the reason why it was not created.
+type ImportRowResult struct {
+	Row   int      `json:"row"`
+	ID    string   `json:"id,omitempty"`
+	Error *Problem `json:"error,omitempty"`
+}
+
+// FacetValue number of users found with a value of a facet.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// FieldChange contains the value of a user field before and after a change.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry describes a change on a user.
+type AuditEntry struct {
+	Operation string        `json:"operation"`
+	Actor     string        `json:"actor"`
+	ChangedAt time.Time     `json:"changed_at"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// UserHistoryResult contains the change history of a user, newest first.
+type UserHistoryResult struct {
+	Entries  []AuditEntry `json:"entries"`
+	Total    int          `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
+
+// Skill contains a canonical skill and its aliases.
+type Skill struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
 }
 
 // toUser transforms new user to a user object.
@@ -86,8 +194,13 @@ func toUser(user *users.User) *User {
 		ID:        user.ID,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
-		Skills:    user.Skills,
+		Skills:    toWebUserSkills(user.Skills),
 		City:      user.City,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		CreatedBy: user.CreatedBy,
+		UpdatedAt: user.UpdatedAt,
+		UpdatedBy: user.UpdatedBy,
 	}
 	return &webUser
 }
@@ -103,14 +216,35 @@ func toSearchUserResult(result *users.SearchUsersResult) *SearchUsersResult {
 		usersFound = append(usersFound, *userFound)
 	}
 	webUser := SearchUsersResult{
-		Users:    usersFound,
-		Total:    result.Total,
-		Page:     result.Page,
-		PageSize: result.RowsPerPage,
+		Users:      usersFound,
+		Total:      result.Total,
+		Page:       result.Page,
+		PageSize:   result.RowsPerPage,
+		NextCursor: result.NextCursor,
+		PrevCursor: result.PrevCursor,
+		Facets:     toWebFacets(result.Facets),
+	}
+	if result.TotalMode != users.TotalExact {
+		webUser.TotalMode = result.TotalMode
 	}
 	return &webUser
 }
 
+func toWebFacets(facets map[string][]users.FacetValue) map[string][]FacetValue {
+	if facets == nil {
+		return nil
+	}
+	webFacets := make(map[string][]FacetValue, len(facets))
+	for facet, values := range facets {
+		webValues := make([]FacetValue, 0, len(values))
+		for _, v := range values {
+			webValues = append(webValues, FacetValue(v))
+		}
+		webFacets[facet] = webValues
+	}
+	return webFacets
+}
+
 // toUser transforms new user to a user object.
 func (n *NewUser) toUser() *users.NewUser {
 	if n == nil {
@@ -119,7 +253,7 @@ func (n *NewUser) toUser() *users.NewUser {
 	userDomain := users.NewUser{
 		FirstName: n.FirstName,
 		LastName:  n.LastName,
-		Skills:    n.Skills,
+		Skills:    toUserSkills(n.Skills),
 		City:      n.City,
 	}
 	return &userDomain
@@ -134,66 +268,224 @@ func (u *UpdateUser) toUser() *users.UpdateUser {
 		ID:        u.ID,
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
-		Skills:    u.Skills,
+		Skills:    toUserSkills(u.Skills),
 		City:      u.City,
 	}
 	return &userDomain
 }
 
 func toCreateUserResponse(userResult users.CreateUserResult) Result {
-	var message Result
-	if userResult.Err == "" {
-		message.Success = true
-		message.Data = userResult.ID
-	}
-	if userResult.Err != "" {
-		message.Errors = []string{userResult.Err}
+	return Result{
+		Success: true,
+		Data:    userResult.ID,
 	}
-	return message
 }
 
-func toUpdateUserResponse(userResult users.UpdateUserResult) Result {
-	var message Result
-	if userResult.Err == "" {
-		message.Success = true
+// toSuccessResponse builds the response of operations that don't return data.
+func toSuccessResponse() Result {
+	return Result{
+		Success: true,
 	}
-	if userResult.Err != "" {
-		message.Errors = []string{userResult.Err}
+}
+
+// asValidationErrors checks if the given error contains validation errors.
+func asValidationErrors(err error, violations *users.ValidationErrors) bool {
+	return errors.As(err, violations)
+}
+
+// toResultErrors transforms the given validation errors into result errors.
+func toResultErrors(violations users.ValidationErrors) []ResultError {
+	resultErrors := make([]ResultError, 0, len(violations))
+	for _, violation := range violations {
+		resultErrors = append(resultErrors, ResultError{
+			Field:   violation.Field,
+			Code:    violation.Code,
+			Message: violation.Message,
+		})
 	}
-	return message
+	return resultErrors
 }
 
 func toGetUserWithIDResponse(userResult users.GetUserWithIDResult) Result {
-	var message Result
-	newUser := toUser(userResult.User)
-	if userResult.Err == "" {
-		message.Success = true
-		message.Data = newUser
-	}
-	if userResult.Err != "" {
-		message.Errors = []string{userResult.Err}
+	return Result{
+		Success: true,
+		Data:    toUser(userResult.User),
 	}
-	return message
 }
 
 func toSearchUsersResponse(userResult users.SearchUsersDataResult) Result {
-	var message Result
+	return Result{
+		Success: true,
+		Data:    toSearchUserResult(userResult.SearchResult),
+	}
+}
 
-	if userResult.Err == "" {
-		message.Success = true
-		message.Data = toSearchUserResult(userResult.SearchResult)
+// toImportUsersResponse builds the response with the report of an import.
+func toImportUsersResponse(result users.ImportUsersResult) Result {
+	return Result{
+		Success: true,
+		Data:    toImportUsersReport(result.Report),
 	}
-	if userResult.Err != "" {
-		message.Errors = []string{userResult.Err}
+}
+
+// toImportUsersReport transforms the report of an import to its web representation.
+func toImportUsersReport(report *users.ImportUsersReport) *ImportUsersReport {
+	if report == nil {
+		return nil
+	}
+	rows := make([]ImportRowResult, 0, len(report.Rows))
+	for _, v := range report.Rows {
+		row := ImportRowResult{
+			Row: v.Row,
+			ID:  v.ID,
+		}
+		if v.Err != nil {
+			problem := toProblem(v.Err)
+			row.Error = &problem
+		}
+		rows = append(rows, row)
+	}
+	return &ImportUsersReport{
+		Created: report.Created,
+		Failed:  report.Failed,
+		Rows:    rows,
+	}
+}
+
+// toUserHistoryResult transforms the history of a user to its web representation.
+func toUserHistoryResult(result *users.UserHistoryResult) *UserHistoryResult {
+	if result == nil {
+		return nil
+	}
+	entries := make([]AuditEntry, 0, len(result.Entries))
+	for _, v := range result.Entries {
+		changes := make([]FieldChange, 0, len(v.Changes))
+		for _, change := range v.Changes {
+			changes = append(changes, FieldChange(change))
+		}
+		entries = append(entries, AuditEntry{
+			Operation: v.Operation,
+			Actor:     v.Actor,
+			ChangedAt: v.ChangedAt,
+			Changes:   changes,
+		})
+	}
+	return &UserHistoryResult{
+		Entries:  entries,
+		Total:    result.Total,
+		Page:     result.Page,
+		PageSize: result.RowsPerPage,
+	}
+}
+
+func toUserHistoryResponse(historyResult users.GetUserHistoryResult) Result {
+	return Result{
+		Success: true,
+		Data:    toUserHistoryResult(historyResult.History),
 	}
-	return message
 }
 
 func (s SearchUserFilter) toSearchUserFilter() users.SearchUserFilter {
 	return users.SearchUserFilter{
-		City:        s.City,
-		Skills:      s.Skills,
-		Page:        s.Page,
-		RowsPerPage: s.PageSize,
+		City:              s.City,
+		Skills:            s.Skills,
+		SkillsAny:         s.SkillsAny,
+		SkillsNone:        s.SkillsNone,
+		SkillRequirements: s.SkillRequirements,
+		UpdatedSince:      s.UpdatedSince,
+		Query:             s.Query,
+		Sort:              s.Sort,
+		Cursor:            s.Cursor,
+		Facets:            s.Facets,
+		TotalMode:         s.TotalMode,
+		Page:              s.Page,
+		RowsPerPage:       s.PageSize,
+	}
+}
+
+// UnmarshalJSON decodes a skill given as an object or as a plain string.
+func (u *UserSkill) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*u = UserSkill{Name: name}
+		return nil
+	}
+	type userSkill UserSkill
+	var skill userSkill
+	err := json.Unmarshal(data, &skill)
+	if err != nil {
+		return err
+	}
+	*u = UserSkill(skill)
+	return nil
+}
+
+// toUserSkills transforms the given skills to user skills.
+func toUserSkills(skills []UserSkill) users.UserSkills {
+	if skills == nil {
+		return nil
+	}
+	userSkills := make(users.UserSkills, 0, len(skills))
+	for _, v := range skills {
+		userSkills = append(userSkills, users.UserSkill(v))
+	}
+	return userSkills
+}
+
+// toWebUserSkills transforms the given user skills to skills.
+func toWebUserSkills(userSkills users.UserSkills) []UserSkill {
+	if userSkills == nil {
+		return nil
+	}
+	skills := make([]UserSkill, 0, len(userSkills))
+	for _, v := range userSkills {
+		skills = append(skills, UserSkill(v))
+	}
+	return skills
+}
+
+// toSkill transforms the skill to a catalog skill.
+func (s Skill) toSkill() *skills.Skill {
+	return &skills.Skill{
+		Name:    s.Name,
+		Aliases: s.Aliases,
+	}
+}
+
+// toWebSkill transforms the given catalog skill to a skill.
+func toWebSkill(skill skills.Skill) Skill {
+	return Skill{
+		Name:    skill.Name,
+		Aliases: skill.Aliases,
+	}
+}
+
+func toListSkillsResponse(result skills.ListSkillsResult) Result {
+	catalog := make([]Skill, 0, len(result.Skills))
+	for _, v := range result.Skills {
+		catalog = append(catalog, toWebSkill(v))
+	}
+	return Result{
+		Success: true,
+		Data:    catalog,
+	}
+}
+
+func toGetSkillResponse(result skills.GetSkillResult) Result {
+	var skill *Skill
+	if result.Skill != nil {
+		webSkill := toWebSkill(*result.Skill)
+		skill = &webSkill
+	}
+	return Result{
+		Success: true,
+		Data:    skill,
+	}
+}
+
+func toCreateSkillResponse(result skills.CreateSkillResult) Result {
+	return Result{
+		Success: true,
+		Data:    result.Name,
 	}
 }
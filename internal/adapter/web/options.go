@@ -0,0 +1,27 @@
+package web
+
+// Option sets an optional setting of the http server.
+type Option func(*settings)
+
+// settings optional settings of the http server.
+type settings struct {
+	// adminToken shared secret the /admin routes require, they are not served
+	// when it is empty.
+	adminToken string
+}
+
+// WithAdminToken serves the /admin routes to the requests that carry the
+// given token as a bearer token of their Authorization header.
+func WithAdminToken(token string) Option {
+	return func(s *settings) {
+		s.adminToken = token
+	}
+}
+
+func newSettings(options []Option) settings {
+	result := settings{}
+	for _, option := range options {
+		option(&result)
+	}
+	return result
+}
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+// problemMediaType media type of error responses, see RFC 7807.
+const problemMediaType = "application/problem+json"
+
+// codes of the errors of the requests that the users domain doesn't classify.
+const (
+	// errorCodeUnauthorized code of the requests to the admin routes without
+	// a valid admin token.
+	errorCodeUnauthorized = "unauthorized"
+	// errorCodeUnsupportedMediaType code of the requests whose body has a
+	// media type the route doesn't read.
+	errorCodeUnsupportedMediaType = "unsupported_media_type"
+	// errorCodeRequestTooLarge code of the requests whose body is larger
+	// than the route reads.
+	errorCodeRequestTooLarge = "request_too_large"
+)
+
+// requestError is an error of the http request itself, it carries the status
+// it is answered with.
+type requestError struct {
+	status  int
+	code    string
+	message string
+	err     error
+}
+
+// Error implements error interface, the cause is not included on purpose.
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the cause of the error.
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// newUnsupportedMediaTypeError creates an error for bodies the route doesn't
+// read.
+func newUnsupportedMediaTypeError(message string, cause error) error {
+	return &requestError{
+		status:  http.StatusUnsupportedMediaType,
+		code:    errorCodeUnsupportedMediaType,
+		message: message,
+		err:     cause,
+	}
+}
+
+// Problem describes an error following RFC 7807 problem details.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Code stable error code, clients should use it instead of the detail.
+	Code string `json:"code"`
+	// Errors contains field level errors when the input was not valid.
+	Errors []ResultError `json:"errors,omitempty"`
+}
+
+// encodeError writes the given error as a problem details response.
+// It is used as the error encoder of the http servers and by the response
+// encoders when a result contains an error.
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	problem := toProblem(err)
+	w.Header().Set("Content-Type", problemMediaType)
+	w.WriteHeader(problem.Status)
+	encodeErr := json.NewEncoder(w).Encode(problem)
+	if encodeErr != nil {
+		log.Println("level", "ERROR", "msg", "problem response could not be encoded", "error", encodeErr)
+	}
+}
+
+// encodeUnauthorized writes the problem of a request without valid
+// credentials.
+func encodeUnauthorized(w http.ResponseWriter) {
+	status := http.StatusUnauthorized
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: "a valid admin token is required",
+		Code:   errorCodeUnauthorized,
+	}
+	w.Header().Set("Content-Type", problemMediaType)
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.WriteHeader(status)
+	encodeErr := json.NewEncoder(w).Encode(problem)
+	if encodeErr != nil {
+		log.Println("level", "ERROR", "msg", "problem response could not be encoded", "error", encodeErr)
+	}
+}
+
+// newRequestTooLargeError creates an error for bodies larger than the route
+// reads.
+func newRequestTooLargeError(message string, cause error) error {
+	return &requestError{
+		status:  http.StatusRequestEntityTooLarge,
+		code:    errorCodeRequestTooLarge,
+		message: message,
+		err:     cause,
+	}
+}
+
+func toProblem(err error) Problem {
+	status := statusFromError(err)
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   users.CodeOf(err),
+	}
+	var violations users.ValidationErrors
+	var usersError *users.Error
+	var rejected *requestError
+	switch {
+	case errors.As(err, &rejected):
+		problem.Detail = rejected.message
+		problem.Code = rejected.code
+	case asValidationErrors(err, &violations):
+		problem.Detail = "user data is not valid"
+		problem.Errors = toResultErrors(violations)
+	case errors.As(err, &usersError):
+		problem.Detail = err.Error()
+	default:
+		// unclassified errors can contain internal details, so they are only
+		// logged.
+		log.Println("level", "ERROR", "msg", "unexpected error", "error", err)
+		problem.Detail = "unexpected error"
+	}
+	return problem
+}
+
+// statusFromError maps the kind of the given error to a http status code.
+func statusFromError(err error) int {
+	var rejected *requestError
+	if errors.As(err, &rejected) {
+		return rejected.status
+	}
+	switch users.KindOf(err) {
+	case users.ErrorKindNotFound:
+		return http.StatusNotFound
+	case users.ErrorKindInvalidInput:
+		return http.StatusBadRequest
+	case users.ErrorKindConflict:
+		return http.StatusConflict
+	case users.ErrorKindUnavailable:
+		return http.StatusServiceUnavailable
+	case users.ErrorKindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case users.ErrorKindTimeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// newInvalidRequestError wraps errors found while decoding requests.
+func newInvalidRequestError(message string, cause error) error {
+	return users.NewInvalidInputError(users.ErrorCodeInvalidRequest, message, cause)
+}
@@ -1,39 +1,156 @@
 package web
 
 import (
+	"crypto/subtle"
 	"net/http"
 
+	"github.com/fernandoocampo/users-micro/internal/skills"
 	"github.com/fernandoocampo/users-micro/internal/users"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 )
 
 // NewHTTPServer is a factory to create http servers for this project.
-func NewHTTPServer(endpoints users.Endpoints) http.Handler {
+// The /admin routes are only served when an admin token is given.
+func NewHTTPServer(endpoints users.Endpoints, skillEndpoints skills.Endpoints, options ...Option) http.Handler {
+	setup := newSettings(options)
 	router := mux.NewRouter()
+	serverOptions := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(actorToContext),
+	}
 	router.Methods(http.MethodGet).Path("/users/{id}").Handler(
 		httptransport.NewServer(
 			endpoints.GetUserWithIDEndpoint,
 			decodeGetUserWithIDRequest,
-			encodeGetUserWithIDResponse),
+			encodeGetUserWithIDResponse,
+			serverOptions...),
 	)
 	router.Methods(http.MethodPost).Path("/users").Handler(
 		httptransport.NewServer(
 			endpoints.CreateUserEndpoint,
 			decodeCreateUserRequest,
-			encodeCreateUserResponse),
+			encodeCreateUserResponse,
+			serverOptions...),
+	)
+	router.Methods(http.MethodPost).Path("/users:import").Handler(
+		httptransport.NewServer(
+			endpoints.ImportUsersEndpoint,
+			decodeImportUsersRequest,
+			encodeImportUsersResponse,
+			serverOptions...),
 	)
 	router.Methods(http.MethodPut).Path("/users").Handler(
 		httptransport.NewServer(
 			endpoints.UpdateUserEndpoint,
 			decodeUpdateUserRequest,
-			encodeUpdateUserResponse),
+			encodeUpdateUserResponse,
+			serverOptions...),
+	)
+	router.Methods(http.MethodPatch).Path("/users/{id}").Handler(
+		httptransport.NewServer(
+			endpoints.PatchUserEndpoint,
+			decodePatchUserRequest,
+			encodePatchUserResponse,
+			serverOptions...),
+	)
+	router.Methods(http.MethodGet).Path("/users/{id}/history").Handler(
+		httptransport.NewServer(
+			endpoints.GetUserHistoryEndpoint,
+			decodeUserHistoryRequest,
+			encodeUserHistoryResponse,
+			serverOptions...),
+	)
+	router.Methods(http.MethodGet).Path("/users:export").Handler(
+		httptransport.NewServer(
+			endpoints.ExportUsersEndpoint,
+			decodeExportUsersRequest,
+			encodeExportUsersResponse,
+			append(serverOptions, httptransport.ServerBefore(exportOptionsToContext))...),
 	)
 	router.Methods(http.MethodGet).Path("/users").Handler(
 		httptransport.NewServer(
 			endpoints.SearchUsersEndpoint,
 			decodeSearchUsersRequest,
-			encodeSearchUsersResponse),
+			encodeSearchUsersResponse,
+			serverOptions...),
+	)
+	router.Methods(http.MethodDelete).Path("/users/{id}").Handler(
+		httptransport.NewServer(
+			endpoints.DeleteUserEndpoint,
+			decodeGetUserWithIDRequest,
+			encodeDeleteUserResponse,
+			serverOptions...),
+	)
+	router.Methods(http.MethodPost).Path("/users/{id}/restore").Handler(
+		httptransport.NewServer(
+			endpoints.RestoreUserEndpoint,
+			decodeGetUserWithIDRequest,
+			encodeRestoreUserResponse,
+			serverOptions...),
+	)
+	if setup.adminToken == "" {
+		return router
+	}
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(requireAdminToken(setup.adminToken))
+	admin.Methods(http.MethodDelete).Path("/users/{id}").Handler(
+		httptransport.NewServer(
+			endpoints.PurgeUserEndpoint,
+			decodeGetUserWithIDRequest,
+			encodePurgeUserResponse,
+			serverOptions...),
+	)
+	admin.Methods(http.MethodGet).Path("/skills").Handler(
+		httptransport.NewServer(
+			skillEndpoints.ListSkillsEndpoint,
+			decodeListSkillsRequest,
+			encodeListSkillsResponse,
+			serverOptions...),
+	)
+	admin.Methods(http.MethodPost).Path("/skills").Handler(
+		httptransport.NewServer(
+			skillEndpoints.CreateSkillEndpoint,
+			decodeCreateSkillRequest,
+			encodeCreateSkillResponse,
+			serverOptions...),
+	)
+	admin.Methods(http.MethodGet).Path("/skills/{name}").Handler(
+		httptransport.NewServer(
+			skillEndpoints.GetSkillEndpoint,
+			decodeSkillNameRequest,
+			encodeGetSkillResponse,
+			serverOptions...),
+	)
+	admin.Methods(http.MethodPut).Path("/skills/{name}").Handler(
+		httptransport.NewServer(
+			skillEndpoints.UpdateSkillEndpoint,
+			decodeUpdateSkillRequest,
+			encodeUpdateSkillResponse,
+			serverOptions...),
+	)
+	admin.Methods(http.MethodDelete).Path("/skills/{name}").Handler(
+		httptransport.NewServer(
+			skillEndpoints.DeleteSkillEndpoint,
+			decodeSkillNameRequest,
+			encodeDeleteSkillResponse,
+			serverOptions...),
 	)
 	return router
 }
+
+// requireAdminToken rejects the requests that do not carry the given token
+// as a bearer token of their Authorization header.
+func requireAdminToken(token string) mux.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := []byte(r.Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(given, expected) != 1 {
+				encodeUnauthorized(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -5,11 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/fernandoocampo/users-micro/internal/adapter/memorydb"
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
 	"github.com/fernandoocampo/users-micro/internal/adapter/web"
+	"github.com/fernandoocampo/users-micro/internal/skills"
 	"github.com/fernandoocampo/users-micro/internal/users"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/stretchr/testify/assert"
@@ -18,25 +24,31 @@ import (
 type webResultGetUser struct {
 	Success bool      `json:"success"`
 	Data    *web.User `json:"data"`
-	Errors  []string  `json:"errors"`
 }
 
 type webResultSearchUsers struct {
 	Success bool                   `json:"success"`
 	Data    *web.SearchUsersResult `json:"data"`
-	Errors  []string               `json:"errors"`
+}
+
+type webResultUserHistory struct {
+	Success bool                   `json:"success"`
+	Data    *web.UserHistoryResult `json:"data"`
+}
+
+type webResultImportUsers struct {
+	Success bool                   `json:"success"`
+	Data    *web.ImportUsersReport `json:"data"`
 }
 
 type webResultCreateUser struct {
-	Success bool     `json:"success"`
-	Data    string   `json:"data"`
-	Errors  []string `json:"errors"`
+	Success bool   `json:"success"`
+	Data    string `json:"data"`
 }
 
 type webResultUpdateUser struct {
-	Success bool     `json:"success"`
-	Data    string   `json:"data"`
-	Errors  []string `json:"errors"`
+	Success bool   `json:"success"`
+	Data    string `json:"data"`
 }
 
 func TestGetUserSuccessfully(t *testing.T) {
@@ -46,23 +58,24 @@ func TestGetUserSuccessfully(t *testing.T) {
 		Data: &web.User{
 			ID:        "1234",
 			City:      "Cali",
-			Skills:    []string{"jack"},
+			Skills:    []web.UserSkill{{Name: "jack"}},
 			FirstName: "Lucia",
 			LastName:  "Mendez",
+			Version:   2,
 		},
-		Errors: nil,
 	}
 	userToReturn := users.User{
 		ID:        "1234",
 		City:      "Cali",
-		Skills:    []string{"jack"},
+		Skills:    users.UserSkills{{Name: "jack"}},
 		FirstName: "Lucia",
 		LastName:  "Mendez",
+		Version:   2,
 	}
 	userEndpoints := users.Endpoints{
 		GetUserWithIDEndpoint: makeDummyGetUserWithIDSuccessfullyEndpoint(t, &userToReturn, nil),
 	}
-	httpHandler := web.NewHTTPServer(userEndpoints)
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
 	dummyServer := httptest.NewServer(httpHandler)
 	defer dummyServer.Close()
 
@@ -82,15 +95,17 @@ func TestGetUserSuccessfully(t *testing.T) {
 	}
 
 	assert.Equal(t, expectedResponse, result)
+	assert.Equal(t, `"2"`, response.Header.Get("ETag"))
 }
 
 func TestSearchUsersSuccessfully(t *testing.T) {
-	queryParams := "?city=Cali&skills=gardener&page=1&pagesize=10"
+	queryParams := "?city=Cali&skills=gardener&updated_since=2021-03-04T05:06:07Z&page=1&pagesize=10"
 	expectedFilter := users.SearchUserFilter{
-		City:        "Cali",
-		Skills:      []string{"gardener"},
-		Page:        1,
-		RowsPerPage: 10,
+		City:         "Cali",
+		Skills:       []string{"gardener"},
+		UpdatedSince: time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC),
+		Page:         1,
+		RowsPerPage:  10,
 	}
 	expectedResponse := webResultSearchUsers{
 		Success: true,
@@ -99,14 +114,14 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 				{
 					ID:        "1234",
 					City:      "Cali",
-					Skills:    users.UserSkills([]string{"jack", "gardener"}),
+					Skills:    []web.UserSkill{{Name: "jack"}, {Name: "gardener"}},
 					FirstName: "Alicia",
 					LastName:  "Mendez",
 				},
 				{
 					ID:        "1240",
 					City:      "Cali",
-					Skills:    users.UserSkills([]string{"gardener", "painter"}),
+					Skills:    []web.UserSkill{{Name: "gardener"}, {Name: "painter"}},
 					FirstName: "Oliver",
 					LastName:  "Vasquez",
 				},
@@ -115,7 +130,6 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 			Page:     1,
 			PageSize: 10,
 		},
-		Errors: nil,
 	}
 
 	serviceResult := users.SearchUsersResult{
@@ -123,14 +137,14 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 			{
 				ID:        "1234",
 				City:      "Cali",
-				Skills:    users.UserSkills([]string{"jack", "gardener"}),
+				Skills:    users.UserSkills{{Name: "jack"}, {Name: "gardener"}},
 				FirstName: "Alicia",
 				LastName:  "Mendez",
 			},
 			{
 				ID:        "1240",
 				City:      "Cali",
-				Skills:    users.UserSkills([]string{"gardener", "painter"}),
+				Skills:    users.UserSkills{{Name: "gardener"}, {Name: "painter"}},
 				FirstName: "Oliver",
 				LastName:  "Vasquez",
 			},
@@ -142,7 +156,7 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 	userEndpoints := users.Endpoints{
 		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &serviceResult, nil),
 	}
-	httpHandler := web.NewHTTPServer(userEndpoints)
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
 	dummyServer := httptest.NewServer(httpHandler)
 	defer dummyServer.Close()
 
@@ -164,28 +178,22 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 	assert.Equal(t, expectedResponse, result)
 }
 
-func TestGetUserNotFound(t *testing.T) {
-	userID := "1234"
-	expectedResponse := webResultGetUser{
-		Success: true,
-		Data:    nil,
-		Errors:  nil,
-	}
+func TestSearchUsersWithInvalidUpdatedSince(t *testing.T) {
 	userEndpoints := users.Endpoints{
-		GetUserWithIDEndpoint: makeDummyGetUserWithIDSuccessfullyEndpoint(t, nil, nil),
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, users.SearchUserFilter{}, nil, nil),
 	}
-	httpHandler := web.NewHTTPServer(userEndpoints)
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
 	dummyServer := httptest.NewServer(httpHandler)
 	defer dummyServer.Close()
 
-	response, err := http.Get(dummyServer.URL + "/users/" + userID)
+	response, err := http.Get(dummyServer.URL + "/users?updated_since=yesterday")
 	if err != nil {
 		t.Error("unexpected error", err)
 		t.FailNow()
 	}
 	defer response.Body.Close()
 
-	var result webResultGetUser
+	var result web.Problem
 
 	err = json.NewDecoder(response.Body).Decode(&result)
 	if err != nil {
@@ -193,75 +201,117 @@ func TestGetUserNotFound(t *testing.T) {
 		t.FailNow()
 	}
 
-	assert.Equal(t, expectedResponse, result)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, users.ErrorCodeInvalidRequest, result.Code)
 }
 
-func TestGetUserWithError(t *testing.T) {
-	userID := "1234"
-	expectedResponse := webResultGetUser{
-		Success: false,
-		Data:    nil,
-		Errors:  []string{"any error"},
+func TestSearchUsersWithSkillRequirements(t *testing.T) {
+	queryParams := "?skill_level=Go:advanced&skill_years=PostgreSQL:3"
+	expectedFilter := users.SearchUserFilter{
+		SkillRequirements: []users.SkillRequirement{
+			{Name: "Go", MinLevel: "advanced"},
+			{Name: "PostgreSQL", MinYears: 3},
+		},
+		Page:        1,
+		RowsPerPage: 10,
 	}
-	errorToReturn := errors.New("any error")
 	userEndpoints := users.Endpoints{
-		GetUserWithIDEndpoint: makeDummyGetUserWithIDSuccessfullyEndpoint(t, nil, errorToReturn),
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &users.SearchUsersResult{}, nil),
 	}
-	httpHandler := web.NewHTTPServer(userEndpoints)
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
 	dummyServer := httptest.NewServer(httpHandler)
 	defer dummyServer.Close()
 
-	response, err := http.Get(dummyServer.URL + "/users/" + userID)
+	response, err := http.Get(dummyServer.URL + "/users" + queryParams)
 	if err != nil {
 		t.Error("unexpected error", err)
 		t.FailNow()
 	}
 	defer response.Body.Close()
 
-	var result webResultGetUser
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
 
-	err = json.NewDecoder(response.Body).Decode(&result)
+func TestSearchUsersWithSkillModes(t *testing.T) {
+	queryParams := "?skills=Go&skills_all=Docker&skills_any=Java&skills_any=Scala&skills_none=Kotlin"
+	expectedFilter := users.SearchUserFilter{
+		Skills:      []string{"Go", "Docker"},
+		SkillsAny:   []string{"Java", "Scala"},
+		SkillsNone:  []string{"Kotlin"},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userEndpoints := users.Endpoints{
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &users.SearchUsersResult{}, nil),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users" + queryParams)
 	if err != nil {
 		t.Error("unexpected error", err)
 		t.FailNow()
 	}
+	defer response.Body.Close()
 
-	assert.Equal(t, expectedResponse, result)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 }
 
-func TestPostUserSuccessfully(t *testing.T) {
-	newUser := web.NewUser{
-		FirstName: "lucia",
-		LastName:  "mendez",
-		City:      "Cali",
-		Skills:    []string{"jack"},
+func TestSearchUsersSorted(t *testing.T) {
+	queryParams := "?q=Fernando+Ocampo&sort=last_name,-updated_at"
+	expectedFilter := users.SearchUserFilter{
+		Query: "Fernando Ocampo",
+		Sort: []users.SortField{
+			{Field: "last_name"},
+			{Field: "updated_at", Descending: true},
+		},
+		Page:        1,
+		RowsPerPage: 10,
 	}
-	newUserJson, err := json.Marshal(newUser)
+	userEndpoints := users.Endpoints{
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &users.SearchUsersResult{}, nil),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users" + queryParams)
 	if err != nil {
-		t.Errorf("unexpected error marshalling new user: %s", err)
+		t.Error("unexpected error", err)
 		t.FailNow()
 	}
-	userEndpoints := users.Endpoints{
-		CreateUserEndpoint: makeDummyCreateUserSuccessfullyEndpoint(t, "1234", nil),
-	}
-	userHandler := web.NewHTTPServer(userEndpoints)
+	defer response.Body.Close()
 
-	dummyServer := httptest.NewServer(userHandler)
-	defer dummyServer.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
 
-	expectedResponse := webResultCreateUser{
-		Success: true,
-		Data:    "1234",
-		Errors:  nil,
+func TestSearchUsersWithCursor(t *testing.T) {
+	queryParams := "?cursor=abc&page=-1&pagesize=5"
+	expectedFilter := users.SearchUserFilter{
+		Cursor:      "abc",
+		Page:        1,
+		RowsPerPage: 5,
 	}
+	searchResult := users.SearchUsersResult{
+		NextCursor: "def",
+		PrevCursor: "xyz",
+	}
+	userEndpoints := users.Endpoints{
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &searchResult, nil),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
 
-	response, err := http.Post(dummyServer.URL+"/users", "application/json", bytes.NewBuffer(newUserJson))
+	response, err := http.Get(dummyServer.URL + "/users" + queryParams)
 	if err != nil {
-		t.Errorf("unexected error creating post request: %s", err)
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
 	defer response.Body.Close()
 
-	var result webResultCreateUser
+	var result webResultSearchUsers
 
 	err = json.NewDecoder(response.Body).Decode(&result)
 	if err != nil {
@@ -269,49 +319,80 @@ func TestPostUserSuccessfully(t *testing.T) {
 		t.FailNow()
 	}
 
-	assert.Equal(t, expectedResponse, result)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "def", result.Data.NextCursor)
+	assert.Equal(t, "xyz", result.Data.PrevCursor)
 }
 
-func TestPutUserSuccessfully(t *testing.T) {
-	updateUser := web.UpdateUser{
-		ID:        "123",
-		FirstName: "lucia",
-		LastName:  "mendez",
-		City:      "Cali",
-		Skills:    []string{"jack", "painter"},
+func TestSearchUsersWithFacets(t *testing.T) {
+	queryParams := "?city=Cali&facets=skills,%20city"
+	expectedFilter := users.SearchUserFilter{
+		City:        "Cali",
+		Facets:      []string{"skills", "city"},
+		Page:        1,
+		RowsPerPage: 10,
 	}
-	updateUserJson, err := json.Marshal(updateUser)
-	if err != nil {
-		t.Errorf("unexpected error marshalling update user: %s", err)
-		t.FailNow()
+	searchResult := users.SearchUsersResult{
+		Facets: map[string][]users.FacetValue{
+			"skills": {{Value: "Go", Count: 120}},
+			"city":   {{Value: "Cali", Count: 45}},
+		},
+	}
+	expectedFacets := map[string][]web.FacetValue{
+		"skills": {{Value: "Go", Count: 120}},
+		"city":   {{Value: "Cali", Count: 45}},
 	}
 	userEndpoints := users.Endpoints{
-		UpdateUserEndpoint: makeDummyUpdateUserSuccessfullyEndpoint(t, nil),
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &searchResult, nil),
 	}
-	userHandler := web.NewHTTPServer(userEndpoints)
-
-	dummyServer := httptest.NewServer(userHandler)
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
 	defer dummyServer.Close()
 
-	expectedResponse := webResultUpdateUser{
-		Success: true,
-		Data:    "",
-		Errors:  nil,
+	response, err := http.Get(dummyServer.URL + "/users" + queryParams)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
+	defer response.Body.Close()
 
-	updateRequest, err := http.NewRequest("PUT", dummyServer.URL+"/users", bytes.NewBuffer(updateUserJson))
+	var result webResultSearchUsers
+
+	err = json.NewDecoder(response.Body).Decode(&result)
 	if err != nil {
-		t.Errorf("unexpected error creating update request: %s", err)
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
 
-	client := &http.Client{}
-	response, err := client.Do(updateRequest)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, expectedFacets, result.Data.Facets)
+}
+
+func TestSearchUsersWithoutTotal(t *testing.T) {
+	queryParams := "?total=none"
+	expectedFilter := users.SearchUserFilter{
+		TotalMode:   "none",
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	searchResult := users.SearchUsersResult{
+		TotalMode: users.TotalNone,
+	}
+	userEndpoints := users.Endpoints{
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, expectedFilter, &searchResult, nil),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users" + queryParams)
 	if err != nil {
-		t.Errorf("unexected error creating put request: %s", err)
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
 	defer response.Body.Close()
 
-	var result webResultUpdateUser
+	var result webResultSearchUsers
 
 	err = json.NewDecoder(response.Body).Decode(&result)
 	if err != nil {
@@ -319,42 +400,95 @@ func TestPutUserSuccessfully(t *testing.T) {
 		t.FailNow()
 	}
 
-	assert.Equal(t, expectedResponse, result)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "none", result.Data.TotalMode)
 }
 
-func TestPostUserWithError(t *testing.T) {
-	newUser := web.NewUser{
-		FirstName: "lucia",
-		LastName:  "mendez",
-		City:      "Cali",
-		Skills:    []string{"jack"},
+func TestSearchUsersWithInvalidSkillYears(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		SearchUsersEndpoint: makeDummySearchUsersSuccessfullyEndpoint(t, users.SearchUserFilter{}, nil, nil),
 	}
-	newUserJson, err := json.Marshal(newUser)
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users?skill_years=PostgreSQL:many")
 	if err != nil {
-		t.Errorf("unexpected error marshalling new user: %s", err)
+		t.Error("unexpected error", err)
 		t.FailNow()
 	}
-	userEndpoints := users.Endpoints{
-		CreateUserEndpoint: makeDummyCreateUserSuccessfullyEndpoint(t, "", errors.New("any error")),
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
-	userHandler := web.NewHTTPServer(userEndpoints)
 
-	dummyServer := httptest.NewServer(userHandler)
-	defer dummyServer.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, users.ErrorCodeInvalidRequest, result.Code)
+}
 
-	expectedResponse := webResultCreateUser{
-		Success: false,
-		Data:    "",
-		Errors:  []string{"any error"},
+func TestGetUserHistorySuccessfully(t *testing.T) {
+	changedAt := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	expectedFilter := users.UserHistoryFilter{
+		UserID:      "1234",
+		Page:        2,
+		RowsPerPage: 5,
+	}
+	expectedResponse := webResultUserHistory{
+		Success: true,
+		Data: &web.UserHistoryResult{
+			Entries: []web.AuditEntry{
+				{
+					Operation: "update",
+					Actor:     "recruiter-1",
+					ChangedAt: changedAt,
+					Changes: []web.FieldChange{
+						{Field: "city", Before: "Cali", After: "Bogota"},
+					},
+				},
+			},
+			Total:    6,
+			Page:     2,
+			PageSize: 5,
+		},
+	}
+	serviceResult := users.UserHistoryResult{
+		Entries: []users.AuditEntry{
+			{
+				Operation: "update",
+				Actor:     "recruiter-1",
+				ChangedAt: changedAt,
+				Changes: []users.FieldChange{
+					{Field: "city", Before: "Cali", After: "Bogota"},
+				},
+			},
+		},
+		Total:       6,
+		Page:        2,
+		RowsPerPage: 5,
+	}
+	userEndpoints := users.Endpoints{
+		GetUserHistoryEndpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+			assert.Equal(t, expectedFilter, request)
+			return users.GetUserHistoryResult{History: &serviceResult}, nil
+		},
 	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
 
-	response, err := http.Post(dummyServer.URL+"/users", "application/json", bytes.NewBuffer(newUserJson))
+	response, err := http.Get(dummyServer.URL + "/users/1234/history?page=2&pagesize=5")
 	if err != nil {
-		t.Errorf("unexected error creating post request: %s", err)
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
 	defer response.Body.Close()
 
-	var result webResultCreateUser
+	var result webResultUserHistory
 
 	err = json.NewDecoder(response.Body).Decode(&result)
 	if err != nil {
@@ -365,84 +499,1367 @@ func TestPostUserWithError(t *testing.T) {
 	assert.Equal(t, expectedResponse, result)
 }
 
-func makeDummyGetUserWithIDSuccessfullyEndpoint(t *testing.T, userToReturn *users.User, err error) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		t.Helper()
-		userID, ok := request.(string)
-		if !ok {
-			t.Errorf("user id parameter is not valid: %+v", userID)
-			t.FailNow()
-		}
-
-		var errMessage string
-		if err != nil {
-			errMessage = err.Error()
-		}
-		result := users.GetUserWithIDResult{
-			User: userToReturn,
-			Err:  errMessage,
-		}
-		return result, nil
+func TestGetUserNotFound(t *testing.T) {
+	userID := "1234"
+	expectedResponse := web.Problem{
+		Type:   "about:blank",
+		Title:  "Not Found",
+		Status: http.StatusNotFound,
+		Detail: "user was not found",
+		Code:   "user_not_found",
 	}
-}
+	userEndpoints := users.Endpoints{
+		GetUserWithIDEndpoint: makeDummyGetUserWithIDSuccessfullyEndpoint(t, nil, users.ErrUserNotFound),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
 
-func makeDummySearchUsersSuccessfullyEndpoint(t *testing.T, expectedFilter users.SearchUserFilter, resultToReturn *users.SearchUsersResult, err error) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		t.Helper()
-		filter, ok := request.(users.SearchUserFilter)
-		if !ok {
-			t.Errorf("user filter parameter is not valid: %T", request)
-			t.FailNow()
-		}
+	response, err := http.Get(dummyServer.URL + "/users/" + userID)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
 
-		assert.Equal(t, expectedFilter, filter)
+	var result web.Problem
 
-		var errMessage string
-		if err != nil {
-			errMessage = err.Error()
-		}
-		result := users.SearchUsersDataResult{
-			SearchResult: resultToReturn,
-			Err:          errMessage,
-		}
-		return result, nil
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
 	}
-}
 
-func makeDummyCreateUserSuccessfullyEndpoint(t *testing.T, newUserID string, err error) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		t.Helper()
-		_, ok := request.(*users.NewUser)
-		if !ok {
-			t.Errorf("user parameter is not valid: %T", request)
-			t.FailNow()
-		}
-		var errMessage string
-		if err != nil {
-			errMessage = err.Error()
-		}
-		result := users.CreateUserResult{
-			ID:  newUserID,
-			Err: errMessage,
-		}
-		return result, nil
-	}
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, "application/problem+json", response.Header.Get("Content-Type"))
+	assert.Equal(t, expectedResponse, result)
 }
 
-func makeDummyUpdateUserSuccessfullyEndpoint(t *testing.T, err error) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		t.Helper()
-		_, ok := request.(*users.UpdateUser)
-		if !ok {
-			t.Errorf("update user parameter is not valid: %T", request)
-			t.FailNow()
+func TestGetUserWithError(t *testing.T) {
+	userID := "1234"
+	expectedResponse := web.Problem{
+		Type:   "about:blank",
+		Title:  "Service Unavailable",
+		Status: http.StatusServiceUnavailable,
+		Detail: "user cannot be read in the database",
+		Code:   "repository_unavailable",
+	}
+	errorToReturn := users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "user cannot be read in the database", errors.New("any error"))
+	userEndpoints := users.Endpoints{
+		GetUserWithIDEndpoint: makeDummyGetUserWithIDSuccessfullyEndpoint(t, nil, errorToReturn),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users/" + userID)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestGetUserTimesOut(t *testing.T) {
+	expectedResponse := web.Problem{
+		Type:   "about:blank",
+		Title:  "Gateway Timeout",
+		Status: http.StatusGatewayTimeout,
+		Detail: "user cannot be read in the database",
+		Code:   "deadline_exceeded",
+	}
+	errorToReturn := users.NewTimeoutError(users.ErrorCodeDeadlineExceeded, "user cannot be read in the database", context.DeadlineExceeded)
+	userEndpoints := users.Endpoints{
+		GetUserWithIDEndpoint: makeDummyGetUserWithIDSuccessfullyEndpoint(t, nil, errorToReturn),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users/1234")
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusGatewayTimeout, response.StatusCode)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestPostUserSuccessfully(t *testing.T) {
+	newUser := web.NewUser{
+		FirstName: "lucia",
+		LastName:  "mendez",
+		City:      "Cali",
+		Skills:    []web.UserSkill{{Name: "jack"}},
+	}
+	newUserJson, err := json.Marshal(newUser)
+	if err != nil {
+		t.Errorf("unexpected error marshalling new user: %s", err)
+		t.FailNow()
+	}
+	userEndpoints := users.Endpoints{
+		CreateUserEndpoint: makeDummyCreateUserSuccessfullyEndpoint(t, "1234", nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultCreateUser{
+		Success: true,
+		Data:    "1234",
+	}
+
+	response, err := http.Post(dummyServer.URL+"/users", "application/json", bytes.NewBuffer(newUserJson))
+	if err != nil {
+		t.Errorf("unexected error creating post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result webResultCreateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "/users/1234", response.Header.Get("Location"))
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestPostSkillSuccessfully(t *testing.T) {
+	newSkill := web.Skill{
+		Name:    "Visual Basic",
+		Aliases: []string{"vb"},
+	}
+	newSkillJson, err := json.Marshal(newSkill)
+	if err != nil {
+		t.Errorf("unexpected error marshalling new skill: %s", err)
+		t.FailNow()
+	}
+	skillEndpoints := skills.Endpoints{
+		CreateSkillEndpoint: makeDummyCreateSkillEndpoint(t, "Visual Basic", nil),
+	}
+	skillHandler := web.NewHTTPServer(users.Endpoints{}, skillEndpoints, web.WithAdminToken("t0k3n"))
+
+	dummyServer := httptest.NewServer(skillHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultCreateUser{
+		Success: true,
+		Data:    "Visual Basic",
+	}
+
+	postRequest, err := http.NewRequest(http.MethodPost, dummyServer.URL+"/admin/skills", bytes.NewBuffer(newSkillJson))
+	if err != nil {
+		t.Errorf("unexpected error creating post request: %s", err)
+		t.FailNow()
+	}
+	postRequest.Header.Set("Content-Type", "application/json")
+	postRequest.Header.Set("Authorization", "Bearer t0k3n")
+
+	response, err := http.DefaultClient.Do(postRequest)
+	if err != nil {
+		t.Errorf("unexected error creating post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result webResultCreateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "/admin/skills/Visual%20Basic", response.Header.Get("Location"))
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestPostSkillWithConflict(t *testing.T) {
+	conflict := users.NewConflictError(skills.ErrorCodeSkillConflict, `"vb" is already used by skill "Visual Basic"`, nil)
+	skillEndpoints := skills.Endpoints{
+		CreateSkillEndpoint: makeDummyCreateSkillEndpoint(t, "", conflict),
+	}
+	skillHandler := web.NewHTTPServer(users.Endpoints{}, skillEndpoints, web.WithAdminToken("t0k3n"))
+
+	dummyServer := httptest.NewServer(skillHandler)
+	defer dummyServer.Close()
+
+	postRequest, err := http.NewRequest(http.MethodPost, dummyServer.URL+"/admin/skills", bytes.NewBufferString(`{"name":"VB.NET","aliases":["vb"]}`))
+	if err != nil {
+		t.Errorf("unexpected error creating post request: %s", err)
+		t.FailNow()
+	}
+	postRequest.Header.Set("Content-Type", "application/json")
+	postRequest.Header.Set("Authorization", "Bearer t0k3n")
+
+	response, err := http.DefaultClient.Do(postRequest)
+	if err != nil {
+		t.Errorf("unexected error creating post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+}
+
+func TestImportUsersFromCSV(t *testing.T) {
+	givenBody := "first_name,last_name,city,skills\n" +
+		"Alicia,Mendez,Cali,Go:advanced:5;PostgreSQL\n" +
+		"Oliver,Vasquez,Cali,Go:expert:many\n"
+	expectedRows := []users.ImportRow{
+		{Row: 1, User: users.NewUser{FirstName: "Alicia", LastName: "Mendez", City: "Cali", Skills: users.UserSkills{
+			{Name: "Go", Level: "advanced", Years: 5},
+			{Name: "PostgreSQL"},
+		}}},
+	}
+	report := users.ImportUsersReport{
+		Created: 1,
+		Failed:  1,
+		Rows: []users.ImportRowResult{
+			{Row: 1, ID: "1234"},
+			{Row: 2, Err: users.NewInvalidInputError(users.ErrorCodeInvalidRequest, "row 2 has invalid skills", nil)},
+		},
+	}
+	var receivedRows []users.ImportRow
+	userEndpoints := users.Endpoints{
+		ImportUsersEndpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+			receivedRows, _ = request.([]users.ImportRow)
+			return users.ImportUsersResult{Report: &report}, nil
+		},
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Post(dummyServer.URL+"/users:import", "text/csv; charset=utf-8", strings.NewReader(givenBody))
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var result webResultImportUsers
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	if assert.Len(t, receivedRows, 2) {
+		assert.Equal(t, expectedRows[0], receivedRows[0])
+		assert.Equal(t, 2, receivedRows[1].Row)
+		assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(receivedRows[1].Err))
+	}
+	assert.Equal(t, 1, result.Data.Created)
+	assert.Equal(t, "1234", result.Data.Rows[0].ID)
+	assert.Nil(t, result.Data.Rows[0].Error)
+	assert.Equal(t, users.ErrorCodeInvalidRequest, result.Data.Rows[1].Error.Code)
+}
+
+func TestImportUsersFromNDJSON(t *testing.T) {
+	givenBody := `{"first_name":"Alicia","last_name":"Mendez","city":"Cali","skills":[{"name":"Go","level":"advanced"}]}` + "\n" +
+		"\n" +
+		`{"first_name":` + "\n"
+	expectedUser := users.NewUser{FirstName: "Alicia", LastName: "Mendez", City: "Cali", Skills: users.UserSkills{{Name: "Go", Level: "advanced"}}}
+	var receivedRows []users.ImportRow
+	userEndpoints := users.Endpoints{
+		ImportUsersEndpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+			receivedRows, _ = request.([]users.ImportRow)
+			return users.ImportUsersResult{Report: &users.ImportUsersReport{}}, nil
+		},
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Post(dummyServer.URL+"/users:import", "application/x-ndjson", strings.NewReader(givenBody))
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	if assert.Len(t, receivedRows, 2) {
+		assert.Equal(t, users.ImportRow{Row: 1, User: expectedUser}, receivedRows[0])
+		assert.Equal(t, 3, receivedRows[1].Row)
+		assert.Error(t, receivedRows[1].Err)
+	}
+}
+
+func TestImportUsersTooLarge(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		ImportUsersEndpoint: makeUnexpectedCallEndpoint(t),
+	}
+	httpHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	givenBody := strings.Repeat("\n", 32<<20+1)
+	response, err := http.Post(dummyServer.URL+"/users:import", "application/x-ndjson", strings.NewReader(givenBody))
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var problem web.Problem
+	err = json.NewDecoder(response.Body).Decode(&problem)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+	assert.Equal(t, "request_too_large", problem.Code)
+}
+
+func TestImportUsersWithUnsupportedContentType(t *testing.T) {
+	httpHandler := web.NewHTTPServer(users.Endpoints{}, skills.Endpoints{})
+	dummyServer := httptest.NewServer(httpHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Post(dummyServer.URL+"/users:import", "application/json", strings.NewReader(`[]`))
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestPutUserSuccessfully(t *testing.T) {
+	updateUser := web.UpdateUser{
+		ID:        "123",
+		FirstName: "lucia",
+		LastName:  "mendez",
+		City:      "Cali",
+		Skills:    []web.UserSkill{{Name: "jack"}, {Name: "painter"}},
+	}
+	updateUserJson, err := json.Marshal(updateUser)
+	if err != nil {
+		t.Errorf("unexpected error marshalling update user: %s", err)
+		t.FailNow()
+	}
+	userEndpoints := users.Endpoints{
+		UpdateUserEndpoint: makeDummyUpdateUserSuccessfullyEndpoint(t, 3, nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultUpdateUser{
+		Success: true,
+		Data:    "",
+	}
+
+	updateRequest, err := http.NewRequest("PUT", dummyServer.URL+"/users", bytes.NewBuffer(updateUserJson))
+	if err != nil {
+		t.Errorf("unexpected error creating update request: %s", err)
+	}
+	updateRequest.Header.Set("If-Match", `"3"`)
+
+	client := &http.Client{}
+	response, err := client.Do(updateRequest)
+	if err != nil {
+		t.Errorf("unexected error creating put request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result webResultUpdateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, expectedResponse, result)
+	assert.Equal(t, `"4"`, response.Header.Get("ETag"))
+}
+
+func TestPutUserWithETagList(t *testing.T) {
+	updateUserJson := []byte(`{"id":"123","first_name":"lucia","last_name":"mendez","city":"Cali"}`)
+	var receivedVersions []int
+	userEndpoints := users.Endpoints{
+		UpdateUserEndpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+			updateUser, _ := request.(*users.UpdateUser)
+			receivedVersions = updateUser.Versions
+			return users.UpdateUserResult{Version: 4}, nil
+		},
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	updateRequest, err := http.NewRequest("PUT", dummyServer.URL+"/users", bytes.NewBuffer(updateUserJson))
+	if err != nil {
+		t.Errorf("unexpected error creating update request: %s", err)
+	}
+	updateRequest.Header.Set("If-Match", `"2", "3"`)
+
+	client := &http.Client{}
+	response, err := client.Do(updateRequest)
+	if err != nil {
+		t.Errorf("unexected error creating put request: %s", err)
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []int{2, 3}, receivedVersions)
+}
+
+func TestPutUserWithAnyVersion(t *testing.T) {
+	updateUserJson := []byte(`{"id":"123","first_name":"lucia","last_name":"mendez","city":"Cali"}`)
+	userEndpoints := users.Endpoints{
+		UpdateUserEndpoint: makeUnexpectedCallEndpoint(t),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	updateRequest, err := http.NewRequest("PUT", dummyServer.URL+"/users", bytes.NewBuffer(updateUserJson))
+	if err != nil {
+		t.Errorf("unexpected error creating update request: %s", err)
+	}
+	updateRequest.Header.Set("If-Match", "*")
+
+	client := &http.Client{}
+	response, err := client.Do(updateRequest)
+	if err != nil {
+		t.Errorf("unexected error creating put request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var problem web.Problem
+	err = json.NewDecoder(response.Body).Decode(&problem)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusPreconditionRequired, response.StatusCode)
+	assert.Equal(t, "version_required", problem.Code)
+	assert.Contains(t, problem.Detail, "If-Match: *")
+}
+
+func TestPutUserWithoutVersion(t *testing.T) {
+	updateUserJson := []byte(`{"id":"123","first_name":"lucia","last_name":"mendez","city":"Cali"}`)
+	userEndpoints := users.Endpoints{
+		UpdateUserEndpoint: makeDummyUpdateUserSuccessfullyEndpoint(t, 0, users.ErrVersionRequired),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	updateRequest, err := http.NewRequest("PUT", dummyServer.URL+"/users", bytes.NewBuffer(updateUserJson))
+	if err != nil {
+		t.Errorf("unexpected error creating update request: %s", err)
+	}
+
+	client := &http.Client{}
+	response, err := client.Do(updateRequest)
+	if err != nil {
+		t.Errorf("unexected error creating put request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusPreconditionRequired, response.StatusCode)
+	assert.Equal(t, users.ErrorCodeVersionRequired, result.Code)
+}
+
+func TestPostUserWithActor(t *testing.T) {
+	var actorID string
+	userEndpoints := users.Endpoints{
+		CreateUserEndpoint: func(ctx context.Context, request interface{}) (interface{}, error) {
+			actorID = users.ActorFromContext(ctx)
+			return users.CreateUserResult{ID: "1234"}, nil
+		},
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	createRequest, err := http.NewRequest(http.MethodPost, dummyServer.URL+"/users", bytes.NewBufferString(`{"first_name":"lucia","last_name":"mendez"}`))
+	if err != nil {
+		t.Errorf("unexpected error creating post request: %s", err)
+	}
+	createRequest.Header.Set("X-Actor-ID", "recruiter-1")
+
+	client := &http.Client{}
+	response, err := client.Do(createRequest)
+	if err != nil {
+		t.Errorf("unexected error executing post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "recruiter-1", actorID)
+}
+
+func TestPostUserWithError(t *testing.T) {
+	newUser := web.NewUser{
+		FirstName: "lucia",
+		LastName:  "mendez",
+		City:      "Cali",
+		Skills:    []web.UserSkill{{Name: "jack"}},
+	}
+	newUserJson, err := json.Marshal(newUser)
+	if err != nil {
+		t.Errorf("unexpected error marshalling new user: %s", err)
+		t.FailNow()
+	}
+	userEndpoints := users.Endpoints{
+		CreateUserEndpoint: makeDummyCreateUserSuccessfullyEndpoint(t, "", errors.New("any error")),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := web.Problem{
+		Type:   "about:blank",
+		Title:  "Internal Server Error",
+		Status: http.StatusInternalServerError,
+		Detail: "unexpected error",
+		Code:   "unexpected_error",
+	}
+
+	response, err := http.Post(dummyServer.URL+"/users", "application/json", bytes.NewBuffer(newUserJson))
+	if err != nil {
+		t.Errorf("unexected error creating post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestExportUsersAsCSV(t *testing.T) {
+	expectedBody := "id,first_name,skills\n" +
+		"1,Alicia,Go:advanced:5;PostgreSQL\n" +
+		"2,Fernando,\n"
+	dummyServer := httptest.NewServer(web.NewHTTPServer(newExportEndpoints(t), skills.Endpoints{}))
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users:export?format=csv&columns=id,first_name,skills&city=Cali&sort=id")
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/csv", response.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.csv"`, response.Header.Get("Content-Disposition"))
+	assert.Equal(t, expectedBody, string(body))
+}
+
+func TestExportUsersAsNDJSONFromAccept(t *testing.T) {
+	expectedBody := `{"id":"1","first_name":"Alicia"}` + "\n" +
+		`{"id":"2","first_name":"Fernando"}` + "\n"
+	dummyServer := httptest.NewServer(web.NewHTTPServer(newExportEndpoints(t), skills.Endpoints{}))
+	defer dummyServer.Close()
+
+	request, err := http.NewRequest(http.MethodGet, dummyServer.URL+"/users:export?columns=id,first_name&city=Cali&sort=id", nil)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	request.Header.Set("Accept", "text/html, application/x-ndjson;q=0.9")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+	assert.Equal(t, expectedBody, string(body))
+}
+
+func TestExportUsersAsJSON(t *testing.T) {
+	dummyServer := httptest.NewServer(web.NewHTTPServer(newExportEndpoints(t), skills.Endpoints{}))
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users:export?format=json&sort=id")
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var result []web.User
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	if assert.Len(t, result, 3) {
+		assert.Equal(t, "Alicia", result[0].FirstName)
+		assert.Equal(t, []web.UserSkill{{Name: "Go", Level: "advanced", Years: 5}, {Name: "PostgreSQL"}}, result[0].Skills)
+		assert.Equal(t, "Bogota", result[2].City)
+	}
+}
+
+func TestExportUsersWithInvalidFormat(t *testing.T) {
+	dummyServer := httptest.NewServer(web.NewHTTPServer(newExportEndpoints(t), skills.Endpoints{}))
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users:export?format=xml")
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestExportUsersFailsBeforeFirstUser(t *testing.T) {
+	userRepository := failingExportRepository{UserMemoryRepository: newExportRepository(t)}
+	userEndpoints := users.Endpoints{
+		ExportUsersEndpoint: users.MakeExportUsersEndpoint(users.NewService(userRepository)),
+	}
+	dummyServer := httptest.NewServer(web.NewHTTPServer(userEndpoints, skills.Endpoints{}))
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users:export?format=json")
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, "application/problem+json", response.Header.Get("Content-Type"))
+}
+
+func TestExportUsersInterrupted(t *testing.T) {
+	userRepository := failingExportRepository{UserMemoryRepository: newExportRepository(t), failAfter: 1}
+	userEndpoints := users.Endpoints{
+		ExportUsersEndpoint: users.MakeExportUsersEndpoint(users.NewService(userRepository)),
+	}
+	dummyServer := httptest.NewServer(web.NewHTTPServer(userEndpoints, skills.Endpoints{}))
+	defer dummyServer.Close()
+
+	response, err := http.Get(dummyServer.URL + "/users:export?format=json&sort=id")
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+	_, readErr := ioutil.ReadAll(response.Body)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Error(t, readErr)
+}
+
+// failingExportRepository memory repository whose exports fail after some
+// users.
+type failingExportRepository struct {
+	*memorydb.UserMemoryRepository
+	// failAfter number of users exported before the export fails.
+	failAfter int
+}
+
+func (f failingExportRepository) ExportWithFilters(ctx context.Context, filter repository.UserFilter, fn func(repository.User) error) error {
+	exported := 0
+	return f.UserMemoryRepository.ExportWithFilters(ctx, filter, func(user repository.User) error {
+		if exported == f.failAfter {
+			return users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "something went wrong trying to export users", nil)
+		}
+		exported++
+		return fn(user)
+	})
+}
+
+// newExportEndpoints returns endpoints that export from a memory repository
+// with three users, two of them from Cali.
+func newExportEndpoints(t *testing.T) users.Endpoints {
+	t.Helper()
+	return users.Endpoints{
+		ExportUsersEndpoint: users.MakeExportUsersEndpoint(users.NewService(newExportRepository(t))),
+	}
+}
+
+// newExportRepository returns a memory repository with three users, two of
+// them from Cali.
+func newExportRepository(t *testing.T) *memorydb.UserMemoryRepository {
+	t.Helper()
+	storedUsers := []repository.User{
+		{ID: "1", FirstName: "Alicia", LastName: "Mendez", City: "Cali", Skills: repository.Skills{
+			{Name: "Go", Level: "advanced", Years: 5},
+			{Name: "PostgreSQL"},
+		}},
+		{ID: "2", FirstName: "Fernando", LastName: "Ocampo", City: "Cali"},
+		{ID: "3", FirstName: "Oliver", LastName: "Vasquez", City: "Bogota"},
+	}
+	userRepository := memorydb.NewUserDryRunRepository()
+	for _, v := range storedUsers {
+		err := userRepository.Save(context.TODO(), v)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	return userRepository
+}
+
+func TestPostUserWithInvalidJSON(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		CreateUserEndpoint: makeDummyCreateUserSuccessfullyEndpoint(t, "1234", nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Post(dummyServer.URL+"/users", "application/json", bytes.NewBufferString(`{"first_name":`))
+	if err != nil {
+		t.Errorf("unexected error creating post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, "invalid_request", result.Code)
+}
+
+func TestDeleteUserSuccessfully(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		DeleteUserEndpoint: makeDummyDeleteUserEndpoint(t, "1234", nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultUpdateUser{
+		Success: true,
+		Data:    "",
+	}
+
+	deleteRequest, err := http.NewRequest(http.MethodDelete, dummyServer.URL+"/users/1234", nil)
+	if err != nil {
+		t.Errorf("unexpected error creating delete request: %s", err)
+	}
+
+	client := &http.Client{}
+	response, err := client.Do(deleteRequest)
+	if err != nil {
+		t.Errorf("unexected error executing delete request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result webResultUpdateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestRestoreUserSuccessfully(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		RestoreUserEndpoint: makeDummyRestoreUserEndpoint(t, "1234", nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultUpdateUser{
+		Success: true,
+		Data:    "",
+	}
+
+	response, err := http.Post(dummyServer.URL+"/users/1234/restore", "application/json", nil)
+	if err != nil {
+		t.Errorf("unexected error executing restore request: %s", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var result webResultUpdateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestRestoreUserNotFound(t *testing.T) {
+	notFound := users.NewNotFoundError(users.ErrorCodeUserNotFound, "user 1234 was not found")
+	userEndpoints := users.Endpoints{
+		RestoreUserEndpoint: makeDummyRestoreUserEndpoint(t, "1234", notFound),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	response, err := http.Post(dummyServer.URL+"/users/1234/restore", "application/json", nil)
+	if err != nil {
+		t.Errorf("unexected error executing restore request: %s", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestPurgeUserSuccessfully(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		PurgeUserEndpoint: makeDummyPurgeUserEndpoint(t, "1234", nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{}, web.WithAdminToken("t0k3n"))
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultUpdateUser{
+		Success: true,
+		Data:    "",
+	}
+
+	purgeRequest, err := http.NewRequest(http.MethodDelete, dummyServer.URL+"/admin/users/1234", nil)
+	if err != nil {
+		t.Errorf("unexpected error creating purge request: %s", err)
+		t.FailNow()
+	}
+	purgeRequest.Header.Set("Authorization", "Bearer t0k3n")
+
+	response, err := http.DefaultClient.Do(purgeRequest)
+	if err != nil {
+		t.Errorf("unexected error executing purge request: %s", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var result webResultUpdateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestPurgeUserWithInvalidAdminToken(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		PurgeUserEndpoint: makeUnexpectedCallEndpoint(t),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{}, web.WithAdminToken("t0k3n"))
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	purgeRequest, err := http.NewRequest(http.MethodDelete, dummyServer.URL+"/admin/users/1234", nil)
+	if err != nil {
+		t.Errorf("unexpected error creating purge request: %s", err)
+		t.FailNow()
+	}
+	purgeRequest.Header.Set("Authorization", "Bearer wrong")
+
+	response, err := http.DefaultClient.Do(purgeRequest)
+	if err != nil {
+		t.Errorf("unexected error executing purge request: %s", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	var problem web.Problem
+	err = json.NewDecoder(response.Body).Decode(&problem)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	assert.Equal(t, "unauthorized", problem.Code)
+}
+
+func TestPurgeUserWhenAdminRoutesAreDisabled(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		PurgeUserEndpoint: makeUnexpectedCallEndpoint(t),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	purgeRequest, err := http.NewRequest(http.MethodDelete, dummyServer.URL+"/admin/users/1234", nil)
+	if err != nil {
+		t.Errorf("unexpected error creating purge request: %s", err)
+		t.FailNow()
+	}
+	purgeRequest.Header.Set("Authorization", "Bearer ")
+
+	response, err := http.DefaultClient.Do(purgeRequest)
+	if err != nil {
+		t.Errorf("unexected error executing purge request: %s", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestPatchUserWithMergePatch(t *testing.T) {
+	expectedPatch := users.PatchUser{
+		ID: "1234",
+		Operations: []users.PatchOperation{
+			{Op: users.PatchReplace, Path: "/city", Value: json.RawMessage(`"Bogota"`)},
+		},
+	}
+	userEndpoints := users.Endpoints{
+		PatchUserEndpoint: makeDummyPatchUserEndpoint(t, expectedPatch, nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := webResultUpdateUser{
+		Success: true,
+		Data:    "",
+	}
+
+	patchRequest, err := http.NewRequest(http.MethodPatch, dummyServer.URL+"/users/1234", bytes.NewBufferString(`{"city":"Bogota"}`))
+	if err != nil {
+		t.Errorf("unexpected error creating patch request: %s", err)
+	}
+	patchRequest.Header.Set("Content-Type", "application/merge-patch+json")
+
+	client := &http.Client{}
+	response, err := client.Do(patchRequest)
+	if err != nil {
+		t.Errorf("unexected error executing patch request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result webResultUpdateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestPatchUserWithJSONPatch(t *testing.T) {
+	expectedPatch := users.PatchUser{
+		ID: "1234",
+		Operations: []users.PatchOperation{
+			{Op: users.PatchAdd, Path: "/skills/-", Value: json.RawMessage(`"painter"`)},
+		},
+	}
+	userEndpoints := users.Endpoints{
+		PatchUserEndpoint: makeDummyPatchUserEndpoint(t, expectedPatch, nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	givenBody := `[{"op":"add","path":"/skills/-","value":"painter"}]`
+	patchRequest, err := http.NewRequest(http.MethodPatch, dummyServer.URL+"/users/1234", bytes.NewBufferString(givenBody))
+	if err != nil {
+		t.Errorf("unexpected error creating patch request: %s", err)
+	}
+	patchRequest.Header.Set("Content-Type", "application/json-patch+json")
+
+	client := &http.Client{}
+	response, err := client.Do(patchRequest)
+	if err != nil {
+		t.Errorf("unexected error executing patch request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result webResultUpdateUser
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.True(t, result.Success)
+}
+
+func TestPatchUserWithPlainJSON(t *testing.T) {
+	expectedPatch := users.PatchUser{
+		ID: "1234",
+		Operations: []users.PatchOperation{
+			{Op: users.PatchReplace, Path: "/city", Value: json.RawMessage(`"Bogota"`)},
+		},
+	}
+	userEndpoints := users.Endpoints{
+		PatchUserEndpoint: makeDummyPatchUserEndpoint(t, expectedPatch, nil),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	patchRequest, err := http.NewRequest(http.MethodPatch, dummyServer.URL+"/users/1234", bytes.NewBufferString(`{"city":"Bogota"}`))
+	if err != nil {
+		t.Errorf("unexpected error creating patch request: %s", err)
+	}
+	patchRequest.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	client := &http.Client{}
+	response, err := client.Do(patchRequest)
+	if err != nil {
+		t.Errorf("unexected error executing patch request: %s", err)
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestPatchUserWithUnsupportedContentType(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		PatchUserEndpoint: makeUnexpectedCallEndpoint(t),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	patchRequest, err := http.NewRequest(http.MethodPatch, dummyServer.URL+"/users/1234", bytes.NewBufferString(`city=Bogota`))
+	if err != nil {
+		t.Errorf("unexpected error creating patch request: %s", err)
+	}
+	patchRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{}
+	response, err := client.Do(patchRequest)
+	if err != nil {
+		t.Errorf("unexected error executing patch request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var problem web.Problem
+	err = json.NewDecoder(response.Body).Decode(&problem)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, response.StatusCode)
+	assert.Equal(t, "unsupported_media_type", problem.Code)
+}
+
+func TestPatchUserWithoutContentType(t *testing.T) {
+	userEndpoints := users.Endpoints{
+		PatchUserEndpoint: makeUnexpectedCallEndpoint(t),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	patchRequest, err := http.NewRequest(http.MethodPatch, dummyServer.URL+"/users/1234", bytes.NewBufferString(`{"city":"Bogota"}`))
+	if err != nil {
+		t.Errorf("unexpected error creating patch request: %s", err)
+	}
+
+	client := &http.Client{}
+	response, err := client.Do(patchRequest)
+	if err != nil {
+		t.Errorf("unexected error executing patch request: %s", err)
+	}
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, response.StatusCode)
+}
+
+func TestPostInvalidUser(t *testing.T) {
+	newUser := web.NewUser{
+		LastName: "mendez",
+		City:     "Cali",
+		Skills:   []web.UserSkill{{Name: "jack"}},
+	}
+	newUserJson, err := json.Marshal(newUser)
+	if err != nil {
+		t.Errorf("unexpected error marshalling new user: %s", err)
+		t.FailNow()
+	}
+	violations := users.ValidationErrors{
+		{Field: "first_name", Code: users.ValidationRequired, Message: "first_name is required"},
+	}
+	userEndpoints := users.Endpoints{
+		CreateUserEndpoint: makeDummyCreateUserSuccessfullyEndpoint(t, "", violations),
+	}
+	userHandler := web.NewHTTPServer(userEndpoints, skills.Endpoints{})
+
+	dummyServer := httptest.NewServer(userHandler)
+	defer dummyServer.Close()
+
+	expectedResponse := web.Problem{
+		Type:   "about:blank",
+		Title:  "Bad Request",
+		Status: http.StatusBadRequest,
+		Detail: "user data is not valid",
+		Code:   "invalid_user",
+		Errors: []web.ResultError{
+			{Field: "first_name", Code: "required", Message: "first_name is required"},
+		},
+	}
+
+	response, err := http.Post(dummyServer.URL+"/users", "application/json", bytes.NewBuffer(newUserJson))
+	if err != nil {
+		t.Errorf("unexected error creating post request: %s", err)
+	}
+	defer response.Body.Close()
+
+	var result web.Problem
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func makeDummyGetUserWithIDSuccessfullyEndpoint(t *testing.T, userToReturn *users.User, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		userID, ok := request.(string)
+		if !ok {
+			t.Errorf("user id parameter is not valid: %+v", userID)
+			t.FailNow()
 		}
-		var errMessage string
-		if err != nil {
-			errMessage = err.Error()
+
+		result := users.GetUserWithIDResult{
+			User: userToReturn,
+			Err:  err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummySearchUsersSuccessfullyEndpoint(t *testing.T, expectedFilter users.SearchUserFilter, resultToReturn *users.SearchUsersResult, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		filter, ok := request.(users.SearchUserFilter)
+		if !ok {
+			t.Errorf("user filter parameter is not valid: %T", request)
+			t.FailNow()
+		}
+
+		assert.Equal(t, expectedFilter, filter)
+
+		result := users.SearchUsersDataResult{
+			SearchResult: resultToReturn,
+			Err:          err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummyCreateUserSuccessfullyEndpoint(t *testing.T, newUserID string, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		_, ok := request.(*users.NewUser)
+		if !ok {
+			t.Errorf("user parameter is not valid: %T", request)
+			t.FailNow()
+		}
+		result := users.CreateUserResult{
+			ID:  newUserID,
+			Err: err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummyCreateSkillEndpoint(t *testing.T, name string, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		_, ok := request.(*skills.Skill)
+		if !ok {
+			t.Errorf("skill parameter is not valid: %T", request)
+			t.FailNow()
+		}
+		result := skills.CreateSkillResult{
+			Name: name,
+			Err:  err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummyUpdateUserSuccessfullyEndpoint(t *testing.T, expectedVersion int, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		updateUser, ok := request.(*users.UpdateUser)
+		if !ok {
+			t.Errorf("update user parameter is not valid: %T", request)
+			t.FailNow()
 		}
+		assert.Equal(t, expectedVersion, updateUser.Version)
 		result := users.UpdateUserResult{
-			Err: errMessage,
+			Version: updateUser.Version + 1,
+			Err:     err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummyDeleteUserEndpoint(t *testing.T, expectedUserID string, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		userID, ok := request.(string)
+		if !ok {
+			t.Errorf("user id parameter is not valid: %T", request)
+			t.FailNow()
+		}
+		assert.Equal(t, expectedUserID, userID)
+		result := users.DeleteUserResult{
+			Err: err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummyRestoreUserEndpoint(t *testing.T, expectedUserID string, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		userID, ok := request.(string)
+		if !ok {
+			t.Errorf("user id parameter is not valid: %T", request)
+			t.FailNow()
+		}
+		assert.Equal(t, expectedUserID, userID)
+		result := users.RestoreUserResult{
+			Err: err,
+		}
+		return result, nil
+	}
+}
+
+func makeDummyPurgeUserEndpoint(t *testing.T, expectedUserID string, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		userID, ok := request.(string)
+		if !ok {
+			t.Errorf("user id parameter is not valid: %T", request)
+			t.FailNow()
+		}
+		assert.Equal(t, expectedUserID, userID)
+		result := users.PurgeUserResult{
+			Err: err,
+		}
+		return result, nil
+	}
+}
+
+func makeUnexpectedCallEndpoint(t *testing.T) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		t.Errorf("endpoint was not expected to be called with: %+v", request)
+		return nil, errors.New("unexpected call")
+	}
+}
+
+func makeDummyPatchUserEndpoint(t *testing.T, expectedPatch users.PatchUser, err error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		t.Helper()
+		patch, ok := request.(*users.PatchUser)
+		if !ok {
+			t.Errorf("patch user parameter is not valid: %T", request)
+			t.FailNow()
+		}
+		assert.Equal(t, expectedPatch, *patch)
+		result := users.PatchUserResult{
+			Err: err,
 		}
 		return result, nil
 	}
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/fernandoocampo/users-micro/internal/adapter/postgresql"
 	"github.com/fernandoocampo/users-micro/internal/adapter/web"
 	"github.com/fernandoocampo/users-micro/internal/configurations"
+	"github.com/fernandoocampo/users-micro/internal/skills"
 	"github.com/fernandoocampo/users-micro/internal/users"
 )
 
@@ -28,6 +31,15 @@ type Event struct {
 type Instance struct {
 	dbConn        *sql.DB
 	configuration configurations.Application
+	// dryRunUsers user repository of dry run mode, it is snapshotted if
+	// the configuration says so.
+	dryRunUsers   *memorydb.UserMemoryRepository
+	stopSnapshots chan struct{}
+	// stopPoolStats stops logging the stats of the database pool.
+	stopPoolStats chan struct{}
+	// users user repository of the database, its prepared statements are
+	// closed when the application stops.
+	users *postgresql.UserRDB
 }
 
 // NewInstance creates a new application instance
@@ -52,14 +64,38 @@ func (i *Instance) Run() error {
 		log.Println("level", "ERROR", "msg", "database connection could not be stablished")
 		return err
 	}
+	if i.dbConn != nil && i.configuration.Repository.StatsInterval > 0 {
+		i.startPoolStats()
+	}
+
+	if i.configuration.MigrateOnStart && !i.configuration.DryRun {
+		err = i.migrate([]string{"up"})
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "database could not be migrated", "error", err)
+			return err
+		}
+	}
+
+	repoSkill := i.createSkillRepository()
+	serviceSkill := skills.NewService(repoSkill)
+	skillEndpoints := skills.NewEndpoints(serviceSkill)
 
-	repoUser := i.createUserRepository()
-	serviceUser := users.NewService(repoUser)
+	repoUser, err := i.createUserRepository()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "user repository could not be initialized", "error", err)
+		return err
+	}
+	transactor, err := i.createTransactor()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "transactions could not be initialized", "error", err)
+		return err
+	}
+	serviceUser := users.NewService(repoUser, users.WithSkillCatalog(serviceSkill), users.WithTransactor(transactor))
 	endpoints := users.NewEndpoints(serviceUser)
 
 	eventStream := make(chan Event)
 	i.listenToOSSignal(eventStream)
-	i.startWebServer(endpoints, eventStream)
+	i.startWebServer(endpoints, skillEndpoints, eventStream)
 
 	eventMessage := <-eventStream
 	fmt.Println(
@@ -79,9 +115,86 @@ func (i *Instance) Run() error {
 	return nil
 }
 
+// Migrate runs a migration command on the database: up applies every pending
+// migration, down [n] reverts the last n migrations, one by default, and
+// version prints the version of the database.
+func (i *Instance) Migrate(args []string) error {
+	confError := i.loadConfiguration()
+	if confError != nil {
+		return confError
+	}
+	if i.configuration.DryRun {
+		return errors.New("dry run mode has no database to migrate")
+	}
+	err := i.openPostgresConnection()
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "database connection could not be stablished")
+		return err
+	}
+	return i.migrate(args)
+}
+
+func (i *Instance) migrate(args []string) error {
+	migrator, err := postgresql.NewMigrator(
+		i.dbConn,
+		postgresql.WithSchema(i.configuration.Repository.Schema),
+		postgresql.WithTable(i.configuration.Repository.Table),
+	)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Println("level", "INFO", "msg", "database migrated", "applied", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("number of migrations to revert must be a positive number, not %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Println("level", "INFO", "msg", "database migrated", "reverted", reverted)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+	default:
+		return fmt.Errorf("unknown migrate command %q, it must be up, down or version", command)
+	}
+	return nil
+}
+
 // Stop stop application, take advantage of this to clean resources
 func (i *Instance) Stop() {
 	log.Println("level", "INFO", "msg", "stopping the application")
+	if i.stopSnapshots != nil {
+		close(i.stopSnapshots)
+	}
+	if i.stopPoolStats != nil {
+		close(i.stopPoolStats)
+	}
+	if i.dryRunUsers != nil && i.configuration.DryRunSnapshot != "" {
+		i.saveSnapshot()
+	}
+	if i.users != nil {
+		i.users.Close()
+	}
 	if i.dbConn != nil {
 		i.dbConn.Close()
 	}
@@ -100,10 +213,10 @@ func (i *Instance) listenToOSSignal(eventStream chan<- Event) {
 }
 
 // startWebServer starts the web server.
-func (i *Instance) startWebServer(endpoints users.Endpoints, eventStream chan<- Event) {
+func (i *Instance) startWebServer(endpoints users.Endpoints, skillEndpoints skills.Endpoints, eventStream chan<- Event) {
 	go func() {
 		log.Println("msg", "starting http server", "http:", i.configuration.ApplicationPort)
-		handler := web.NewHTTPServer(endpoints)
+		handler := web.NewHTTPServer(endpoints, skillEndpoints, web.WithAdminToken(i.configuration.AdminToken))
 		err := http.ListenAndServe(i.configuration.ApplicationPort, handler)
 		if err != nil {
 			eventStream <- Event{
@@ -128,7 +241,7 @@ func (i *Instance) loadConfiguration() error {
 	return nil
 }
 
-func (i *Instance) createUserRepository() users.Repository {
+func (i *Instance) createUserRepository() (users.Repository, error) {
 	if i.configuration.DryRun {
 		log.Println("level", "INFO", "msg", "initializing dry run database")
 		return i.loadDryRunUserRepository()
@@ -136,6 +249,31 @@ func (i *Instance) createUserRepository() users.Repository {
 	return i.loadUserRepository()
 }
 
+// createTransactor creates the transactor of the user repository, it must be
+// called after the repository is created.
+func (i *Instance) createTransactor() (users.Transactor, error) {
+	if i.configuration.DryRun {
+		return i.dryRunUsers, nil
+	}
+	isolation, err := postgresql.ParseIsolation(i.configuration.Repository.Isolation)
+	if err != nil {
+		return nil, err
+	}
+	return postgresql.NewTransactor(i.dbConn, isolation), nil
+}
+
+func (i *Instance) createSkillRepository() skills.Repository {
+	if i.configuration.DryRun {
+		return memorydb.NewSkillDryRunRepository()
+	}
+	log.Println("level", "INFO", "msg", "initializing skill repository")
+	return postgresql.NewSkillRepository(
+		i.dbConn,
+		postgresql.WithSchema(i.configuration.Repository.Schema),
+		postgresql.WithTimeouts(repositoryTimeouts(i.configuration.Repository)),
+	)
+}
+
 func (i *Instance) openDBConnection() error {
 	if i.configuration.DryRun {
 		return nil
@@ -163,21 +301,138 @@ func (i *Instance) openPostgresConnection() error {
 	return nil
 }
 
-func (i *Instance) loadDryRunUserRepository() *memorydb.UserMemoryRepository {
-	return memorydb.NewUserDryRunRepository()
+// startPoolStats logs the stats of the database pool on every interval until
+// the application stops.
+func (i *Instance) startPoolStats() {
+	i.stopPoolStats = make(chan struct{})
+	ticker := time.NewTicker(i.configuration.Repository.StatsInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				i.logPoolStats()
+			case <-i.stopPoolStats:
+				return
+			}
+		}
+	}()
+}
+
+func (i *Instance) logPoolStats() {
+	stats := i.dbConn.Stats()
+	log.Println(
+		"level", "INFO",
+		"msg", "database pool stats",
+		"max open", stats.MaxOpenConnections,
+		"open", stats.OpenConnections,
+		"in use", stats.InUse,
+		"idle", stats.Idle,
+		"wait count", stats.WaitCount,
+		"wait duration", stats.WaitDuration,
+		"max idle closed", stats.MaxIdleClosed,
+		"max idle time closed", stats.MaxIdleTimeClosed,
+		"max lifetime closed", stats.MaxLifetimeClosed,
+	)
 }
 
-func (i *Instance) loadUserRepository() *postgresql.UserRDB {
-	log.Println("level", "INFO", "msg", "initializing user repository")
-	return postgresql.NewUserRepository(i.dbConn)
+// loadDryRunUserRepository creates the dry run user repository with the users
+// of the last snapshot, or with the fixtures if there is no snapshot yet.
+func (i *Instance) loadDryRunUserRepository() (*memorydb.UserMemoryRepository, error) {
+	repoUser := memorydb.NewUserDryRunRepository(
+		memorydb.WithCapacity(i.configuration.DryRunCapacity),
+		memorydb.WithEviction(memorydb.EvictionPolicy(i.configuration.DryRunEviction)),
+	)
+	ctx := context.Background()
+	var restored bool
+	if i.configuration.DryRunSnapshot != "" {
+		var err error
+		restored, err = repoUser.LoadSnapshot(ctx, i.configuration.DryRunSnapshot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !restored && i.configuration.DryRunFixtures != "" {
+		err := repoUser.LoadFixtures(ctx, i.configuration.DryRunFixtures)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.dryRunUsers = repoUser
+	if i.configuration.DryRunSnapshot != "" && i.configuration.DryRunSnapshotInterval > 0 {
+		i.startSnapshots()
+	}
+	return repoUser, nil
+}
+
+// startSnapshots snapshots the dry run users on every interval until the
+// application stops.
+func (i *Instance) startSnapshots() {
+	i.stopSnapshots = make(chan struct{})
+	ticker := time.NewTicker(i.configuration.DryRunSnapshotInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				i.saveSnapshot()
+			case <-i.stopSnapshots:
+				return
+			}
+		}
+	}()
+}
+
+func (i *Instance) saveSnapshot() {
+	err := i.dryRunUsers.SaveSnapshot(context.Background(), i.configuration.DryRunSnapshot)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "dry run snapshot could not be saved", "path", i.configuration.DryRunSnapshot, "error", err)
+	}
+}
+
+func (i *Instance) loadUserRepository() (*postgresql.UserRDB, error) {
+	log.Println("level", "INFO", "msg", "initializing user repository", "schema", i.configuration.Repository.Schema, "table", i.configuration.Repository.Table)
+	isolation, err := postgresql.ParseIsolation(i.configuration.Repository.Isolation)
+	if err != nil {
+		return nil, err
+	}
+	userRepository, err := postgresql.NewUserRepository(
+		i.dbConn,
+		postgresql.WithSchema(i.configuration.Repository.Schema),
+		postgresql.WithTable(i.configuration.Repository.Table),
+		postgresql.WithTimeouts(repositoryTimeouts(i.configuration.Repository)),
+		postgresql.WithStatementCacheSize(i.configuration.Repository.StatementCacheSize),
+		postgresql.WithIsolation(isolation),
+	)
+	if err != nil {
+		return nil, err
+	}
+	i.users = userRepository
+	return userRepository, nil
+}
+
+func repositoryTimeouts(parameters configurations.RepositoryParameters) postgresql.Timeouts {
+	return postgresql.Timeouts{
+		Read:   parameters.ReadTimeout,
+		Write:  parameters.WriteTimeout,
+		Search: parameters.SearchTimeout,
+	}
 }
 
 func toPostgresqlParameters(parameters configurations.RepositoryParameters) postgresql.Parameters {
 	return postgresql.Parameters{
-		Host:     parameters.Host,
-		User:     parameters.User,
-		Password: parameters.Password,
-		DBName:   parameters.DBName,
-		Port:     parameters.Port,
+		URL:             parameters.URL,
+		Host:            parameters.Host,
+		User:            parameters.User,
+		Password:        parameters.Password,
+		DBName:          parameters.DBName,
+		Port:            parameters.Port,
+		SSLMode:         parameters.SSLMode,
+		SSLRootCert:     parameters.SSLRootCert,
+		SSLCert:         parameters.SSLCert,
+		SSLKey:          parameters.SSLKey,
+		MaxOpenConns:    parameters.MaxOpenConns,
+		MaxIdleConns:    parameters.MaxIdleConns,
+		ConnMaxLifetime: parameters.ConnMaxLifetime,
 	}
 }
@@ -1,23 +1,101 @@
 package configurations
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/caarlos0/env"
 )
 
+// redactedSecret replaces the secrets of the parameters when they are printed.
+const redactedSecret = "xxxxx"
+
+// passwordSetting password of a key/value connection string, quoted or not.
+var passwordSetting = regexp.MustCompile(`password=('(\\.|[^'])*'|\S*)`)
+
 // Application contains data related to application configuration parameters.
 type Application struct {
-	DryRun          bool   `env:"DRY_RUN" envDefault:"false"`
-	ApplicationPort string `env:"APPLICATION_PORT" envDefault:":8080"`
-	Repository      RepositoryParameters
+	DryRun                 bool          `env:"DRY_RUN" envDefault:"false"`
+	DryRunCapacity         int           `env:"DRY_RUN_CAPACITY" envDefault:"100"`
+	DryRunEviction         string        `env:"DRY_RUN_EVICTION" envDefault:"reject"`
+	DryRunFixtures         string        `env:"DRY_RUN_FIXTURES"`
+	DryRunSnapshot         string        `env:"DRY_RUN_SNAPSHOT"`
+	DryRunSnapshotInterval time.Duration `env:"DRY_RUN_SNAPSHOT_INTERVAL" envDefault:"0s"`
+	ApplicationPort        string        `env:"APPLICATION_PORT" envDefault:":8080"`
+	MigrateOnStart         bool          `env:"MIGRATE_ON_START" envDefault:"false"`
+	AdminToken             string        `env:"ADMIN_TOKEN"`
+	Repository             RepositoryParameters
+}
+
+// String describes the parameters without the admin token so they can be
+// logged.
+func (a Application) String() string {
+	// parameters drops the String method so they can be printed as they are.
+	type parameters Application
+	redacted := parameters(a)
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = redactedSecret
+	}
+	return fmt.Sprintf("%+v", redacted)
 }
 
 // RepositoryParameters contains data related to a repository.
 type RepositoryParameters struct {
-	Host     string `env:"DB_HOST" envDefault:"localhost"`
-	Port     int    `env:"DB_PORT" envDefault:"5432"`
-	User     string `env:"DB_USER" envDefault:"postgres"`
-	Password string `env:"DB_PASSWORD" envDefault:"postgres"`
-	DBName   string `env:"DBNAME" envDefault:"postgres"`
+	URL                string        `env:"DATABASE_URL"`
+	Host               string        `env:"DB_HOST" envDefault:"localhost"`
+	Port               int           `env:"DB_PORT" envDefault:"5432"`
+	User               string        `env:"DB_USER" envDefault:"postgres"`
+	Password           string        `env:"DB_PASSWORD" envDefault:"postgres"`
+	DBName             string        `env:"DBNAME" envDefault:"postgres"`
+	Schema             string        `env:"SCHEMA"`
+	Table              string        `env:"DB_TABLE" envDefault:"jobseeker"`
+	Isolation          string        `env:"DB_ISOLATION" envDefault:"read committed"`
+	ReadTimeout        time.Duration `env:"DB_READ_TIMEOUT" envDefault:"5s"`
+	WriteTimeout       time.Duration `env:"DB_WRITE_TIMEOUT" envDefault:"10s"`
+	SearchTimeout      time.Duration `env:"DB_SEARCH_TIMEOUT" envDefault:"30s"`
+	StatementCacheSize int           `env:"DB_STATEMENT_CACHE_SIZE" envDefault:"100"`
+	SSLMode            string        `env:"DB_SSLMODE"`
+	SSLRootCert        string        `env:"DB_SSLROOTCERT"`
+	SSLCert            string        `env:"DB_SSLCERT"`
+	SSLKey             string        `env:"DB_SSLKEY"`
+	MaxOpenConns       int           `env:"DB_MAX_OPEN_CONNS" envDefault:"0"`
+	MaxIdleConns       int           `env:"DB_MAX_IDLE_CONNS" envDefault:"2"`
+	ConnMaxLifetime    time.Duration `env:"DB_CONN_MAX_LIFETIME" envDefault:"0s"`
+	StatsInterval      time.Duration `env:"DB_STATS_INTERVAL" envDefault:"1m"`
+}
+
+// String describes the parameters without the password, neither the one
+// given apart nor the one of the URL, so they can be logged.
+func (r RepositoryParameters) String() string {
+	// parameters drops the String method so they can be printed as they are.
+	type parameters RepositoryParameters
+	redacted := parameters(r)
+	if redacted.Password != "" {
+		redacted.Password = redactedSecret
+	}
+	redacted.URL = redactURL(r.URL)
+	return fmt.Sprintf("%+v", redacted)
+}
+
+// redactURL replaces the password of the given connection string, in url or
+// key/value format.
+func redactURL(connection string) string {
+	if strings.HasPrefix(connection, "postgres://") || strings.HasPrefix(connection, "postgresql://") {
+		parsed, err := url.Parse(connection)
+		if err != nil {
+			return redactedSecret
+		}
+		query := parsed.Query()
+		if query.Get("password") != "" {
+			query.Set("password", redactedSecret)
+			parsed.RawQuery = query.Encode()
+		}
+		return parsed.Redacted()
+	}
+	return passwordSetting.ReplaceAllString(connection, "password="+redactedSecret)
 }
 
 // Load load application configuration
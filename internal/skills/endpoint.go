@@ -0,0 +1,105 @@
+package skills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints is a wrapper for the skill catalog endpoints.
+type Endpoints struct {
+	GetSkillEndpoint    endpoint.Endpoint
+	ListSkillsEndpoint  endpoint.Endpoint
+	CreateSkillEndpoint endpoint.Endpoint
+	UpdateSkillEndpoint endpoint.Endpoint
+	DeleteSkillEndpoint endpoint.Endpoint
+}
+
+// NewEndpoints Create the endpoints of the skill catalog.
+func NewEndpoints(service *Service) Endpoints {
+	return Endpoints{
+		GetSkillEndpoint:    MakeGetSkillEndpoint(service),
+		ListSkillsEndpoint:  MakeListSkillsEndpoint(service),
+		CreateSkillEndpoint: MakeCreateSkillEndpoint(service),
+		UpdateSkillEndpoint: MakeUpdateSkillEndpoint(service),
+		DeleteSkillEndpoint: MakeDeleteSkillEndpoint(service),
+	}
+}
+
+// MakeGetSkillEndpoint create endpoint for get a skill by name.
+func MakeGetSkillEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		name, ok := request.(string)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid skill name", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid skill name")
+		}
+		skill, err := srv.GetSkill(ctx, name)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to get a skill", "error", err)
+		}
+		return GetSkillResult{Skill: skill, Err: err}, nil
+	}
+}
+
+// MakeListSkillsEndpoint create endpoint for list the skill catalog.
+func MakeListSkillsEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		catalog, err := srv.ListSkills(ctx)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to list skills", "error", err)
+		}
+		return ListSkillsResult{Skills: catalog, Err: err}, nil
+	}
+}
+
+// MakeCreateSkillEndpoint create endpoint for create a skill.
+func MakeCreateSkillEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		newSkill, ok := request.(*Skill)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid new skill type", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid new skill type")
+		}
+		name, err := srv.CreateSkill(ctx, *newSkill)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to create a skill", "error", err)
+		}
+		return CreateSkillResult{Name: name, Err: err}, nil
+	}
+}
+
+// MakeUpdateSkillEndpoint create endpoint for update a skill.
+func MakeUpdateSkillEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		skill, ok := request.(*Skill)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid update skill type", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid update skill type")
+		}
+		err := srv.UpdateSkill(ctx, *skill)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to update a skill", "error", err)
+		}
+		return UpdateSkillResult{Err: err}, nil
+	}
+}
+
+// MakeDeleteSkillEndpoint create endpoint for delete a skill.
+func MakeDeleteSkillEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		name, ok := request.(string)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid skill name", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid skill name")
+		}
+		err := srv.DeleteSkill(ctx, name)
+		if err != nil {
+			log.Println("level", "ERROR", "msg", "something went wrong trying to delete a skill", "error", err)
+		}
+		return DeleteSkillResult{Err: err}, nil
+	}
+}
@@ -0,0 +1,13 @@
+package skills
+
+import "github.com/fernandoocampo/users-micro/internal/users"
+
+// Stable error codes of the skill catalog, clients can rely on them.
+const (
+	ErrorCodeSkillNotFound = "skill_not_found"
+	ErrorCodeInvalidSkill  = "invalid_skill"
+	ErrorCodeSkillConflict = "skill_conflict"
+)
+
+// ErrSkillNotFound is returned when the requested skill doesn't exist.
+var ErrSkillNotFound = users.NewNotFoundError(ErrorCodeSkillNotFound, "skill not found")
@@ -0,0 +1,122 @@
+package skills
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+)
+
+// Skill contains a canonical skill and the aliases that mean the same skill.
+type Skill struct {
+	// Name canonical name of the skill.
+	Name string `json:"name"`
+	// Aliases other names of the skill, e.g. golang for Go.
+	Aliases []string `json:"aliases"`
+}
+
+// GetSkillResult standard response for get a skill.
+type GetSkillResult struct {
+	Skill *Skill
+	Err   error
+}
+
+// ListSkillsResult standard response for list the skill catalog.
+type ListSkillsResult struct {
+	Skills []Skill
+	Err    error
+}
+
+// CreateSkillResult standard response for create a skill.
+type CreateSkillResult struct {
+	Name string
+	Err  error
+}
+
+// UpdateSkillResult standard response for update a skill.
+type UpdateSkillResult struct {
+	Err error
+}
+
+// DeleteSkillResult standard response for delete a skill.
+type DeleteSkillResult struct {
+	Err error
+}
+
+// normalize returns the key used to compare skill names and aliases.
+func normalize(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// clean trims the name and aliases of the skill and removes the aliases
+// that are repeated or mean the skill name.
+func (s Skill) clean() Skill {
+	cleaned := Skill{
+		Name:    strings.TrimSpace(s.Name),
+		Aliases: make([]string, 0, len(s.Aliases)),
+	}
+	seen := map[string]bool{normalize(cleaned.Name): true}
+	for _, alias := range s.Aliases {
+		alias = strings.TrimSpace(alias)
+		key := normalize(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned.Aliases = append(cleaned.Aliases, alias)
+	}
+	return cleaned
+}
+
+// toRepositorySkill transforms the skill to a repository skill.
+func (s Skill) toRepositorySkill() repository.Skill {
+	return repository.Skill{
+		Name:    s.Name,
+		Aliases: repository.Aliases(s.Aliases),
+	}
+}
+
+// toSkill transforms the given repository skill to a skill.
+func toSkill(repoSkill repository.Skill) Skill {
+	aliases := []string(repoSkill.Aliases)
+	if aliases == nil {
+		aliases = make([]string, 0)
+	}
+	return Skill{
+		Name:    repoSkill.Name,
+		Aliases: aliases,
+	}
+}
+
+// catalogIndex maps every normalized skill name and alias to its canonical name.
+type catalogIndex map[string]string
+
+// newCatalogIndex builds the index of the given skills.
+func newCatalogIndex(catalog []repository.Skill) catalogIndex {
+	index := make(catalogIndex)
+	for _, skill := range catalog {
+		index[normalize(skill.Name)] = skill.Name
+		for _, alias := range skill.Aliases {
+			index[normalize(alias)] = skill.Name
+		}
+	}
+	return index
+}
+
+// canonical returns the canonical name of the given skill, unknown skills
+// are returned trimmed.
+func (c catalogIndex) canonical(name string) string {
+	canonicalName, ok := c[normalize(name)]
+	if !ok {
+		return strings.TrimSpace(name)
+	}
+	return canonicalName
+}
+
+func (s Skill) String() string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
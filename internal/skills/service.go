@@ -0,0 +1,183 @@
+package skills
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+)
+
+// maxSkillLength maximum length of a skill name or alias, it is the same
+// limit users have for their skills.
+const maxSkillLength = 50
+
+// Repository defines portout behavior to store the skill catalog.
+type Repository interface {
+	FindByName(ctx context.Context, name string) (*repository.Skill, error)
+	FindAll(ctx context.Context) ([]repository.Skill, error)
+	Save(ctx context.Context, skill repository.Skill) error
+	Update(ctx context.Context, skill repository.Skill) error
+	Delete(ctx context.Context, name string) error
+}
+
+// Service implements the skill catalog logic.
+type Service struct {
+	skillRepository Repository
+}
+
+// NewService creates a new skill catalog service.
+func NewService(skillRepository Repository) *Service {
+	return &Service{
+		skillRepository: skillRepository,
+	}
+}
+
+// GetSkill get the skill with the given canonical name.
+func (s *Service) GetSkill(ctx context.Context, name string) (*Skill, error) {
+	log.Println("level", "DEBUG", "msg", "getting skill", "method", "skills.Service.GetSkill", "name", name)
+	result, err := s.skillRepository.FindByName(ctx, name)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "something went wrong trying to get a skill", "method", "skills.Service.GetSkill", "name", name)
+		return nil, err
+	}
+	skill := toSkill(*result)
+	return &skill, nil
+}
+
+// ListSkills returns the whole skill catalog ordered by name.
+func (s *Service) ListSkills(ctx context.Context) ([]Skill, error) {
+	log.Println("level", "DEBUG", "msg", "listing skills", "method", "skills.Service.ListSkills")
+	catalog, err := s.skillRepository.FindAll(ctx)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "something went wrong trying to list skills", "method", "skills.Service.ListSkills")
+		return nil, err
+	}
+	result := make([]Skill, 0, len(catalog))
+	for _, v := range catalog {
+		result = append(result, toSkill(v))
+	}
+	return result, nil
+}
+
+// CreateSkill adds a skill to the catalog, its name and aliases must not be
+// used by any other skill. It returns the canonical name of the new skill.
+func (s *Service) CreateSkill(ctx context.Context, newSkill Skill) (string, error) {
+	log.Println("level", "DEBUG", "msg", "creating skill", "method", "skills.Service.CreateSkill", "skill", newSkill)
+	skill := newSkill.clean()
+	err := skill.validate()
+	if err != nil {
+		return "", err
+	}
+	err = s.checkConflicts(ctx, skill, "")
+	if err != nil {
+		return "", err
+	}
+	err = s.skillRepository.Save(ctx, skill.toRepositorySkill())
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "something goes wrong creating skill", "method", "skills.Service.CreateSkill", "skill", skill)
+		return "", err
+	}
+	log.Println("level", "INFO", "msg", "skill was created successfuly", "method", "skills.Service.CreateSkill", "skill", skill)
+	return skill.Name, nil
+}
+
+// UpdateSkill replaces the aliases of the skill with the given name, the
+// canonical name of a skill cannot be changed.
+func (s *Service) UpdateSkill(ctx context.Context, skillToUpdate Skill) error {
+	log.Println("level", "DEBUG", "msg", "updating skill", "method", "skills.Service.UpdateSkill", "skill", skillToUpdate)
+	skill := skillToUpdate.clean()
+	err := skill.validate()
+	if err != nil {
+		return err
+	}
+	current, err := s.skillRepository.FindByName(ctx, skill.Name)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "something goes wrong reading skill to update", "method", "skills.Service.UpdateSkill", "name", skill.Name)
+		return err
+	}
+	skill.Name = current.Name
+	err = s.checkConflicts(ctx, skill, current.Name)
+	if err != nil {
+		return err
+	}
+	err = s.skillRepository.Update(ctx, skill.toRepositorySkill())
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "something goes wrong updating skill", "method", "skills.Service.UpdateSkill", "skill", skill)
+		return err
+	}
+	log.Println("level", "INFO", "msg", "skill was updated successfuly", "method", "skills.Service.UpdateSkill", "skill", skill)
+	return nil
+}
+
+// DeleteSkill removes the skill with the given name from the catalog, users
+// keep the skill as it was written.
+func (s *Service) DeleteSkill(ctx context.Context, name string) error {
+	log.Println("level", "DEBUG", "msg", "deleting skill", "method", "skills.Service.DeleteSkill", "name", name)
+	err := s.skillRepository.Delete(ctx, name)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "something goes wrong deleting skill", "method", "skills.Service.DeleteSkill", "name", name)
+		return err
+	}
+	log.Println("level", "INFO", "msg", "skill was deleted successfuly", "method", "skills.Service.DeleteSkill", "name", name)
+	return nil
+}
+
+// Canonicalize returns the canonical name of every given skill in the same
+// position, skills are known by the catalog by name or by alias. Unknown skills
+// are kept trimmed.
+func (s *Service) Canonicalize(ctx context.Context, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return names, nil
+	}
+	catalog, err := s.skillRepository.FindAll(ctx)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "skill catalog cannot be read", "method", "skills.Service.Canonicalize", "error", err)
+		return nil, err
+	}
+	index := newCatalogIndex(catalog)
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, index.canonical(name))
+	}
+	return result, nil
+}
+
+// checkConflicts checks that the name and aliases of the given skill are not
+// used by other skill of the catalog, the skill with the ignored name is skipped.
+func (s *Service) checkConflicts(ctx context.Context, skill Skill, ignoredName string) error {
+	catalog, err := s.skillRepository.FindAll(ctx)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "skill catalog cannot be read", "method", "skills.Service.checkConflicts", "error", err)
+		return err
+	}
+	others := make([]repository.Skill, 0, len(catalog))
+	for _, v := range catalog {
+		if v.Name != ignoredName {
+			others = append(others, v)
+		}
+	}
+	index := newCatalogIndex(others)
+	for _, name := range append([]string{skill.Name}, skill.Aliases...) {
+		owner, ok := index[normalize(name)]
+		if ok {
+			return users.NewConflictError(ErrorCodeSkillConflict, fmt.Sprintf("%q is already used by skill %q", name, owner), nil)
+		}
+	}
+	return nil
+}
+
+// validate checks the name and aliases of a skill.
+func (s Skill) validate() error {
+	if s.Name == "" {
+		return users.NewInvalidInputError(ErrorCodeInvalidSkill, "skill name is required", nil)
+	}
+	for _, name := range append([]string{s.Name}, s.Aliases...) {
+		if utf8.RuneCountInString(name) > maxSkillLength {
+			return users.NewInvalidInputError(ErrorCodeInvalidSkill, fmt.Sprintf("%q must have at most %d characters", name, maxSkillLength), nil)
+		}
+	}
+	return nil
+}
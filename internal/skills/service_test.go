@@ -0,0 +1,173 @@
+package skills_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/skills"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSkillSuccessfully(t *testing.T) {
+	expectedSkill := repository.Skill{
+		Name:    "Go",
+		Aliases: repository.Aliases{"golang"},
+	}
+	skillRepository := skillRepoMock{
+		repo: make(map[string]repository.Skill),
+	}
+	givenSkill := skills.Skill{
+		Name:    " Go ",
+		Aliases: []string{"golang", " GO", "Golang "},
+	}
+	skillService := skills.NewService(&skillRepository)
+	ctx := context.TODO()
+
+	name, err := skillService.CreateSkill(ctx, givenSkill)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Go", name)
+	assert.Equal(t, expectedSkill, skillRepository.repo["Go"])
+}
+
+func TestCreateSkillWithAliasOfOtherSkill(t *testing.T) {
+	skillRepository := skillRepoMock{
+		repo: map[string]repository.Skill{
+			"Go": {Name: "Go", Aliases: repository.Aliases{"golang"}},
+		},
+	}
+	givenSkill := skills.Skill{
+		Name:    "Google Go",
+		Aliases: []string{"GoLang"},
+	}
+	skillService := skills.NewService(&skillRepository)
+	ctx := context.TODO()
+
+	_, err := skillService.CreateSkill(ctx, givenSkill)
+
+	assert.Equal(t, users.ErrorKindConflict, users.KindOf(err))
+	assert.Equal(t, skills.ErrorCodeSkillConflict, users.CodeOf(err))
+	assert.Len(t, skillRepository.repo, 1)
+}
+
+func TestCreateSkillWithoutName(t *testing.T) {
+	skillRepository := skillRepoMock{
+		repo: make(map[string]repository.Skill),
+	}
+	skillService := skills.NewService(&skillRepository)
+	ctx := context.TODO()
+
+	_, err := skillService.CreateSkill(ctx, skills.Skill{Name: "  "})
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, skills.ErrorCodeInvalidSkill, users.CodeOf(err))
+}
+
+func TestUpdateSkillSuccessfully(t *testing.T) {
+	expectedSkill := repository.Skill{
+		Name:    "Go",
+		Aliases: repository.Aliases{"golang", "go lang"},
+	}
+	skillRepository := skillRepoMock{
+		repo: map[string]repository.Skill{
+			"Go": {Name: "Go", Aliases: repository.Aliases{"golang"}},
+		},
+	}
+	givenSkill := skills.Skill{
+		Name:    "Go",
+		Aliases: []string{"golang", "go lang"},
+	}
+	skillService := skills.NewService(&skillRepository)
+	ctx := context.TODO()
+
+	err := skillService.UpdateSkill(ctx, givenSkill)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSkill, skillRepository.repo["Go"])
+}
+
+func TestGetSkillNotFound(t *testing.T) {
+	skillRepository := skillRepoMock{
+		repo: make(map[string]repository.Skill),
+	}
+	skillService := skills.NewService(&skillRepository)
+	ctx := context.TODO()
+
+	skill, err := skillService.GetSkill(ctx, "Go")
+
+	assert.Nil(t, skill)
+	assert.Equal(t, skills.ErrSkillNotFound, err)
+}
+
+func TestCanonicalize(t *testing.T) {
+	skillRepository := skillRepoMock{
+		repo: map[string]repository.Skill{
+			"Go":         {Name: "Go", Aliases: repository.Aliases{"golang"}},
+			"PostgreSQL": {Name: "PostgreSQL", Aliases: repository.Aliases{"postgres", "psql"}},
+		},
+	}
+	skillService := skills.NewService(&skillRepository)
+	ctx := context.TODO()
+
+	got, err := skillService.Canonicalize(ctx, []string{"Golang", "GO ", "postgres", " painter ", "go"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Go", "Go", "PostgreSQL", "painter", "Go"}, got)
+}
+
+type skillRepoMock struct {
+	err  error
+	repo map[string]repository.Skill
+}
+
+func (s *skillRepoMock) FindByName(ctx context.Context, name string) (*repository.Skill, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	skill, ok := s.repo[name]
+	if !ok {
+		return nil, skills.ErrSkillNotFound
+	}
+	return &skill, nil
+}
+
+func (s *skillRepoMock) FindAll(ctx context.Context) ([]repository.Skill, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	catalog := make([]repository.Skill, 0, len(s.repo))
+	for _, v := range s.repo {
+		catalog = append(catalog, v)
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Name < catalog[j].Name
+	})
+	return catalog, nil
+}
+
+func (s *skillRepoMock) Save(ctx context.Context, skill repository.Skill) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.repo[skill.Name] = skill
+	return nil
+}
+
+func (s *skillRepoMock) Update(ctx context.Context, skill repository.Skill) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.repo[skill.Name] = skill
+	return nil
+}
+
+func (s *skillRepoMock) Delete(ctx context.Context, name string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.repo, name)
+	return nil
+}
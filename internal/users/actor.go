@@ -0,0 +1,24 @@
+package users
+
+import "context"
+
+// AnonymousActor identity used when the caller of the service is unknown.
+const AnonymousActor = "anonymous"
+
+// actorKey context key for the identity of the caller.
+type actorKey struct{}
+
+// WithActor returns a copy of the given context that carries the identity of the caller.
+func WithActor(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+// ActorFromContext returns the identity of the caller carried by the given context,
+// AnonymousActor if there is none.
+func ActorFromContext(ctx context.Context) string {
+	actorID, ok := ctx.Value(actorKey{}).(string)
+	if !ok || actorID == "" {
+		return AnonymousActor
+	}
+	return actorID
+}
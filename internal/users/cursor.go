@@ -0,0 +1,121 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+)
+
+// pageCursor is the content of the opaque cursors given to clients.
+type pageCursor struct {
+	// Sort sort fields the cursor was created with, e.g. last_name,-updated_at,id
+	Sort string `json:"s"`
+	// Values values of the sort fields of the user the cursor points to.
+	Values []string `json:"v"`
+	// Backward true if the cursor reads the page before the user.
+	Backward bool `json:"b,omitempty"`
+}
+
+// encodeCursor creates the cursor of the page after the given user, or before
+// it if backward is true.
+func encodeCursor(fields []repository.SortField, user repository.User, backward bool) string {
+	content := pageCursor{
+		Sort:     sortKey(fields),
+		Values:   make([]string, 0, len(fields)),
+		Backward: backward,
+	}
+	for _, v := range fields {
+		content.Values = append(content.Values, repository.SortValue(user, v.Field))
+	}
+	b, err := json.Marshal(content)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads the given cursor, it must have been created for the
+// same sort fields.
+func decodeCursor(value string, fields []repository.SortField) (*repository.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, NewInvalidInputError(ErrorCodeInvalidCursor, "cursor is not valid", err)
+	}
+	var content pageCursor
+	err = json.Unmarshal(b, &content)
+	if err != nil {
+		return nil, NewInvalidInputError(ErrorCodeInvalidCursor, "cursor is not valid", err)
+	}
+	if content.Sort != sortKey(fields) || len(content.Values) != len(fields) {
+		return nil, NewInvalidInputError(ErrorCodeInvalidCursor, "cursor was created for a different sort, search again without cursor", nil)
+	}
+	for i, v := range fields {
+		if v.Field != repository.SortByCreatedAt && v.Field != repository.SortByUpdatedAt {
+			continue
+		}
+		_, err := time.Parse(time.RFC3339Nano, content.Values[i])
+		if err != nil {
+			return nil, NewInvalidInputError(ErrorCodeInvalidCursor, "cursor is not valid", err)
+		}
+	}
+	return &repository.Cursor{
+		Values:   content.Values,
+		Backward: content.Backward,
+	}, nil
+}
+
+// pageCursors returns the cursors of the pages next to the users found, there
+// are none for searches sorted by relevance.
+func pageCursors(filter repository.UserFilter, result repository.FindUsersResult) (string, string) {
+	if len(result.Users) == 0 || sortsByRelevance(filter.Sort) {
+		return "", ""
+	}
+	first, last := result.Users[0], result.Users[len(result.Users)-1]
+	var hasNext, hasPrev bool
+	switch {
+	case filter.Cursor == nil:
+		hasNext = result.HasMore
+		hasPrev = filter.Page > 1
+	case filter.Cursor.Backward:
+		hasNext = true
+		hasPrev = result.HasMore
+	default:
+		hasNext = result.HasMore
+		hasPrev = true
+	}
+	var next, prev string
+	if hasNext {
+		next = encodeCursor(filter.Sort, last, false)
+	}
+	if hasPrev {
+		prev = encodeCursor(filter.Sort, first, true)
+	}
+	return next, prev
+}
+
+// sortsByRelevance checks if any of the given fields is the relevance, it has
+// no value to keep in a cursor.
+func sortsByRelevance(fields []repository.SortField) bool {
+	for _, v := range fields {
+		if v.Field == repository.SortByRelevance {
+			return true
+		}
+	}
+	return false
+}
+
+// sortKey writes the sort fields as they are given by clients.
+func sortKey(fields []repository.SortField) string {
+	keys := make([]string, 0, len(fields))
+	for _, v := range fields {
+		key := v.Field
+		if v.Descending {
+			key = "-" + key
+		}
+		keys = append(keys, key)
+	}
+	return strings.Join(keys, ",")
+}
@@ -11,19 +11,33 @@ import (
 
 // Endpoints is a wrapper for endpoints
 type Endpoints struct {
-	GetUserWithIDEndpoint endpoint.Endpoint
-	CreateUserEndpoint    endpoint.Endpoint
-	UpdateUserEndpoint    endpoint.Endpoint
-	SearchUsersEndpoint   endpoint.Endpoint
+	GetUserWithIDEndpoint  endpoint.Endpoint
+	CreateUserEndpoint     endpoint.Endpoint
+	UpdateUserEndpoint     endpoint.Endpoint
+	PatchUserEndpoint      endpoint.Endpoint
+	SearchUsersEndpoint    endpoint.Endpoint
+	DeleteUserEndpoint     endpoint.Endpoint
+	RestoreUserEndpoint    endpoint.Endpoint
+	PurgeUserEndpoint      endpoint.Endpoint
+	GetUserHistoryEndpoint endpoint.Endpoint
+	ImportUsersEndpoint    endpoint.Endpoint
+	ExportUsersEndpoint    endpoint.Endpoint
 }
 
 // NewEndpoints Create the endpoints for users-micro application.
 func NewEndpoints(service *Service) Endpoints {
 	return Endpoints{
-		GetUserWithIDEndpoint: MakeGetUserWithIDEndpoint(service),
-		CreateUserEndpoint:    MakeCreateUserEndpoint(service),
-		UpdateUserEndpoint:    MakeUpdateUserEndpoint(service),
-		SearchUsersEndpoint:   MakeSearchUsersEndpoint(service),
+		GetUserWithIDEndpoint:  MakeGetUserWithIDEndpoint(service),
+		CreateUserEndpoint:     MakeCreateUserEndpoint(service),
+		UpdateUserEndpoint:     MakeUpdateUserEndpoint(service),
+		PatchUserEndpoint:      MakePatchUserEndpoint(service),
+		SearchUsersEndpoint:    MakeSearchUsersEndpoint(service),
+		DeleteUserEndpoint:     MakeDeleteUserEndpoint(service),
+		RestoreUserEndpoint:    MakeRestoreUserEndpoint(service),
+		PurgeUserEndpoint:      MakePurgeUserEndpoint(service),
+		GetUserHistoryEndpoint: MakeGetUserHistoryEndpoint(service),
+		ImportUsersEndpoint:    MakeImportUsersEndpoint(service),
+		ExportUsersEndpoint:    MakeExportUsersEndpoint(service),
 	}
 }
 
@@ -70,6 +84,48 @@ func MakeCreateUserEndpoint(srv *Service) endpoint.Endpoint {
 	}
 }
 
+// MakeImportUsersEndpoint create endpoint for import users service.
+func MakeImportUsersEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		rows, ok := request.([]ImportRow)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid import rows type", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid import rows type")
+		}
+
+		report, err := srv.ImportUsers(ctx, rows)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to import users",
+				"error", err,
+			)
+		}
+		return newImportUsersResult(report, err), nil
+	}
+}
+
+// MakeExportUsersEndpoint user endpoint to export the users found with filters.
+func MakeExportUsersEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		userFilters, ok := request.(SearchUserFilter)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid user filters", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid user filters")
+		}
+
+		export, err := srv.ExportUsers(ctx, userFilters)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to export users with the given filter",
+				"error", err,
+			)
+		}
+		return newExportUsersResult(export, err), nil
+	}
+}
+
 // MakeUpdateUserEndpoint create endpoint for update user service.
 func MakeUpdateUserEndpoint(srv *Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
@@ -79,7 +135,7 @@ func MakeUpdateUserEndpoint(srv *Service) endpoint.Endpoint {
 			return nil, errors.New("invalid update user type")
 		}
 
-		err := srv.Update(ctx, *updateUser)
+		version, err := srv.Update(ctx, *updateUser)
 		if err != nil {
 			log.Println(
 				"level", "ERROR",
@@ -87,7 +143,28 @@ func MakeUpdateUserEndpoint(srv *Service) endpoint.Endpoint {
 				"error", err,
 			)
 		}
-		return newUpdateUserResult(err), nil
+		return newUpdateUserResult(version, err), nil
+	}
+}
+
+// MakePatchUserEndpoint create endpoint for patch user service.
+func MakePatchUserEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		patchUser, ok := request.(*PatchUser)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid patch user type", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid patch user type")
+		}
+
+		version, err := srv.Patch(ctx, *patchUser)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to patch an user with the given id",
+				"error", err,
+			)
+		}
+		return newPatchUserResult(version, err), nil
 	}
 }
 
@@ -112,3 +189,87 @@ func MakeSearchUsersEndpoint(srv *Service) endpoint.Endpoint {
 		return newSearchUsersDataResult(searchResult, err), nil
 	}
 }
+
+// MakeDeleteUserEndpoint create endpoint for soft delete user service.
+func MakeDeleteUserEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		userID, ok := request.(string)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid user id", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid user id")
+		}
+
+		err := srv.Delete(ctx, userID)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to delete an user with the given id",
+				"error", err,
+			)
+		}
+		return newDeleteUserResult(err), nil
+	}
+}
+
+// MakeRestoreUserEndpoint create endpoint for restore user service.
+func MakeRestoreUserEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		userID, ok := request.(string)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid user id", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid user id")
+		}
+
+		err := srv.Restore(ctx, userID)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to restore an user with the given id",
+				"error", err,
+			)
+		}
+		return newRestoreUserResult(err), nil
+	}
+}
+
+// MakePurgeUserEndpoint create endpoint for purge user service.
+func MakePurgeUserEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		userID, ok := request.(string)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid user id", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid user id")
+		}
+
+		err := srv.Purge(ctx, userID)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to purge an user with the given id",
+				"error", err,
+			)
+		}
+		return newPurgeUserResult(err), nil
+	}
+}
+
+// MakeGetUserHistoryEndpoint user endpoint to read the change history of a user.
+func MakeGetUserHistoryEndpoint(srv *Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		filter, ok := request.(UserHistoryFilter)
+		if !ok {
+			log.Println("level", "ERROR", "msg", "invalid user history filter", "received", fmt.Sprintf("%t", request))
+			return nil, errors.New("invalid user history filter")
+		}
+
+		history, err := srv.GetUserHistory(ctx, filter)
+		if err != nil {
+			log.Println(
+				"level", "ERROR",
+				"msg", "something went wrong trying to read the user history",
+				"error", err,
+			)
+		}
+		return newGetUserHistoryResult(history, err), nil
+	}
+}
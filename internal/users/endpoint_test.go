@@ -15,11 +15,11 @@ func TestGetUserSuccessfully(t *testing.T) {
 		User: &users.User{
 			ID:        "1234",
 			City:      "Cali",
-			Skills:    users.UserSkills([]string{"jack"}),
+			Skills:    users.UserSkills{{Name: "jack"}},
 			FirstName: "Alicia",
 			LastName:  "Mendez",
 		},
-		Err: "",
+		Err: nil,
 	}
 	userRepository := userRepoMock{
 		repo: make(map[string]repository.User),
@@ -27,7 +27,7 @@ func TestGetUserSuccessfully(t *testing.T) {
 	existingUser := repository.User{
 		ID:        "1234",
 		City:      "Cali",
-		Skills:    repository.Skills([]string{"jack"}),
+		Skills:    repository.Skills{{Name: "jack"}},
 		FirstName: "Alicia",
 		LastName:  "Mendez",
 	}
@@ -46,7 +46,7 @@ func TestGetUserNotFound(t *testing.T) {
 	userID := "1234"
 	expectedResponse := users.GetUserWithIDResult{
 		User: nil,
-		Err:  "",
+		Err:  users.ErrUserNotFound,
 	}
 	userRepository := userRepoMock{
 		repo: make(map[string]repository.User),
@@ -67,7 +67,7 @@ func TestCreateUserSuccessfully(t *testing.T) {
 	}
 	newUser := users.NewUser{
 		City:      "Cali",
-		Skills:    users.UserSkills([]string{"jack"}),
+		Skills:    users.UserSkills{{Name: "jack"}},
 		FirstName: "Alicia",
 		LastName:  "Mendez",
 	}
@@ -83,15 +83,17 @@ func TestCreateUserSuccessfully(t *testing.T) {
 
 func TestUpdateUserSuccessfully(t *testing.T) {
 	expectedResponse := users.UpdateUserResult{
-		Err: "",
+		Version: 2,
+		Err:     nil,
 	}
 	userRepository := userRepoMock{
 		repo: make(map[string]repository.User),
 	}
 	updatewUser := users.UpdateUser{
 		ID:        "123",
+		Version:   1,
 		City:      "Cali",
-		Skills:    users.UserSkills([]string{"jack"}),
+		Skills:    users.UserSkills{{Name: "jack"}},
 		FirstName: "Alicia",
 		LastName:  "Mendez",
 	}
@@ -108,7 +110,7 @@ func TestUpdateUserSuccessfully(t *testing.T) {
 func TestSearchUsersEndpointSuccessfully(t *testing.T) {
 	givenFilter := users.SearchUserFilter{
 		City:        "Cali",
-		Skills:      users.UserSkills([]string{"gardener"}),
+		Skills:      []string{"gardener"},
 		Page:        1,
 		RowsPerPage: 10,
 	}
@@ -117,14 +119,14 @@ func TestSearchUsersEndpointSuccessfully(t *testing.T) {
 			{
 				ID:        "1234",
 				City:      "Cali",
-				Skills:    users.UserSkills([]string{"jack", "gardener"}),
+				Skills:    users.UserSkills{{Name: "jack"}, {Name: "gardener"}},
 				FirstName: "Alicia",
 				LastName:  "Mendez",
 			},
 			{
 				ID:        "1240",
 				City:      "Cali",
-				Skills:    users.UserSkills([]string{"gardener", "painter"}),
+				Skills:    users.UserSkills{{Name: "gardener"}, {Name: "painter"}},
 				FirstName: "Oliver",
 				LastName:  "Vasquez",
 			},
@@ -141,14 +143,14 @@ func TestSearchUsersEndpointSuccessfully(t *testing.T) {
 			{
 				ID:        "1234",
 				City:      "Cali",
-				Skills:    repository.Skills([]string{"jack", "gardener"}),
+				Skills:    repository.Skills{{Name: "jack"}, {Name: "gardener"}},
 				FirstName: "Alicia",
 				LastName:  "Mendez",
 			},
 			{
 				ID:        "1240",
 				City:      "Cali",
-				Skills:    repository.Skills([]string{"gardener", "painter"}),
+				Skills:    repository.Skills{{Name: "gardener"}, {Name: "painter"}},
 				FirstName: "Oliver",
 				LastName:  "Vasquez",
 			},
@@ -170,3 +172,44 @@ func TestSearchUsersEndpointSuccessfully(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedResult, usersFound)
 }
+
+func TestDeleteUserEndpointSuccessfully(t *testing.T) {
+	expectedResponse := users.DeleteUserResult{
+		Err: nil,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userService := users.NewService(&userRepository)
+	deleteUserEndpoint := users.MakeDeleteUserEndpoint(userService)
+	ctx := context.TODO()
+
+	result, err := deleteUserEndpoint(ctx, "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedResponse, result)
+}
+
+func TestPurgeUserEndpointSuccessfully(t *testing.T) {
+	expectedResponse := users.PurgeUserResult{
+		Err: nil,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	purgeUserEndpoint := users.MakePurgeUserEndpoint(userService)
+	ctx := context.TODO()
+
+	result, err := purgeUserEndpoint(ctx, "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedResponse, result)
+}
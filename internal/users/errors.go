@@ -0,0 +1,127 @@
+package users
+
+import "errors"
+
+// ErrorKind classifies users errors, transports use it to pick a status code.
+type ErrorKind int
+
+// Kinds of errors.
+const (
+	// ErrorKindUnexpected is any error that was not classified.
+	ErrorKindUnexpected ErrorKind = iota
+	// ErrorKindNotFound the requested user doesn't exist.
+	ErrorKindNotFound
+	// ErrorKindInvalidInput the given data or request is not valid.
+	ErrorKindInvalidInput
+	// ErrorKindConflict the operation conflicts with the stored data.
+	ErrorKindConflict
+	// ErrorKindUnavailable the storage or a dependency is not available.
+	ErrorKindUnavailable
+	// ErrorKindPreconditionRequired the operation needs the version of the data it is based on.
+	ErrorKindPreconditionRequired
+	// ErrorKindTimeout the storage or a dependency didn't answer in time.
+	ErrorKindTimeout
+)
+
+// Stable error codes, clients can rely on them.
+const (
+	ErrorCodeUnexpected            = "unexpected_error"
+	ErrorCodeUserNotFound          = "user_not_found"
+	ErrorCodeInvalidRequest        = "invalid_request"
+	ErrorCodeInvalidUser           = "invalid_user"
+	ErrorCodeInvalidPatch          = "invalid_patch"
+	ErrorCodeInvalidCursor         = "invalid_cursor"
+	ErrorCodeUserAlreadyExists     = "user_already_exists"
+	ErrorCodeVersionConflict       = "version_conflict"
+	ErrorCodeVersionRequired       = "version_required"
+	ErrorCodeRepositoryUnavailable = "repository_unavailable"
+	ErrorCodeDeadlineExceeded      = "deadline_exceeded"
+)
+
+// Error is an error of the users domain.
+type Error struct {
+	// Kind classifies the error.
+	Kind ErrorKind
+	// Code stable code of the error.
+	Code string
+	// Message human readable description, it is safe to show it to clients.
+	Message string
+	// Err the cause of this error if any.
+	Err error
+}
+
+// Error implements error interface, the cause is not included on purpose
+// because it can contain internal details.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewNotFoundError creates an error for a resource that doesn't exist.
+func NewNotFoundError(code, message string) error {
+	return &Error{Kind: ErrorKindNotFound, Code: code, Message: message}
+}
+
+// NewInvalidInputError creates an error for invalid requests or data.
+func NewInvalidInputError(code, message string, cause error) error {
+	return &Error{Kind: ErrorKindInvalidInput, Code: code, Message: message, Err: cause}
+}
+
+// NewConflictError creates an error for operations that conflict with stored data.
+func NewConflictError(code, message string, cause error) error {
+	return &Error{Kind: ErrorKindConflict, Code: code, Message: message, Err: cause}
+}
+
+// NewUnavailableError creates an error for storages or dependencies that failed.
+func NewUnavailableError(code, message string, cause error) error {
+	return &Error{Kind: ErrorKindUnavailable, Code: code, Message: message, Err: cause}
+}
+
+// NewTimeoutError creates an error for storages or dependencies that didn't
+// answer before the deadline of the operation.
+func NewTimeoutError(code, message string, cause error) error {
+	return &Error{Kind: ErrorKindTimeout, Code: code, Message: message, Err: cause}
+}
+
+// ErrUserNotFound is returned when the requested user doesn't exist.
+var ErrUserNotFound = NewNotFoundError(ErrorCodeUserNotFound, "user was not found")
+
+// ErrVersionConflict is returned when the stored user changed after the version the change was based on.
+var ErrVersionConflict = NewConflictError(ErrorCodeVersionConflict, "user was modified by someone else, read it again and retry", nil)
+
+// ErrVersionRequired is returned when a change doesn't say which version it is based on.
+var ErrVersionRequired = &Error{
+	Kind:    ErrorKindPreconditionRequired,
+	Code:    ErrorCodeVersionRequired,
+	Message: "the version of the user to change is required",
+}
+
+// KindOf returns the kind of the given error.
+func KindOf(err error) ErrorKind {
+	var violations ValidationErrors
+	if errors.As(err, &violations) {
+		return ErrorKindInvalidInput
+	}
+	var usersError *Error
+	if errors.As(err, &usersError) {
+		return usersError.Kind
+	}
+	return ErrorKindUnexpected
+}
+
+// CodeOf returns the stable code of the given error.
+func CodeOf(err error) string {
+	var violations ValidationErrors
+	if errors.As(err, &violations) {
+		return ErrorCodeInvalidUser
+	}
+	var usersError *Error
+	if errors.As(err, &usersError) {
+		return usersError.Code
+	}
+	return ErrorCodeUnexpected
+}
@@ -2,42 +2,200 @@ package users
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
 )
 
+// Skill proficiency levels, from the lowest to the highest.
+const (
+	SkillBeginner     = "beginner"
+	SkillIntermediate = "intermediate"
+	SkillAdvanced     = "advanced"
+	SkillExpert       = "expert"
+)
+
+// skillLevels proficiency levels ordered from the lowest to the highest.
+var skillLevels = []string{SkillBeginner, SkillIntermediate, SkillAdvanced, SkillExpert}
+
+// UserSkill contains a skill of a user and how good the user is at it.
+type UserSkill struct {
+	// Name name of the skill.
+	Name string `json:"name"`
+	// Level proficiency level: beginner, intermediate, advanced or expert.
+	// It is empty if unknown.
+	Level string `json:"level,omitempty"`
+	// Years years of experience with the skill.
+	Years int `json:"years,omitempty"`
+}
+
 // UserSkills a list of user skills
-type UserSkills []string
+type UserSkills []UserSkill
+
+// SkillRequirement minimum proficiency a user must have on a skill to be found.
+type SkillRequirement struct {
+	// Name name of the skill.
+	Name string
+	// MinLevel lowest proficiency level accepted, any level if it is empty.
+	MinLevel string
+	// MinYears minimum years of experience with the skill.
+	MinYears int
+}
 
 // CreateUserResult standard response for create User
 type CreateUserResult struct {
 	ID  string
-	Err string
+	Err error
 }
 
 // UpdateUserResult standard response for updating a user
 type UpdateUserResult struct {
-	Err string
+	Version int
+	Err     error
+}
+
+// PatchUserResult standard response for patching a user
+type PatchUserResult struct {
+	Version int
+	Err     error
+}
+
+// DeleteUserResult standard response for deleting a user
+type DeleteUserResult struct {
+	Err error
+}
+
+// RestoreUserResult standard response for restoring a deleted user
+type RestoreUserResult struct {
+	Err error
+}
+
+// PurgeUserResult standard response for purging a user
+type PurgeUserResult struct {
+	Err error
 }
 
 // GetUserWithIDResult standard roespnse for get a User with an ID.
 type GetUserWithIDResult struct {
 	User *User
-	Err  string
+	Err  error
 }
 
 // SearchUsersDataResult standard roespnse for get a User with an ID.
 type SearchUsersDataResult struct {
 	SearchResult *SearchUsersResult
-	Err          string
+	Err          error
+}
+
+// Import limits.
+const (
+	// maxImportRows max number of users of an import.
+	maxImportRows = 10000
+	// importBatchSize number of users saved at once while importing.
+	importBatchSize = 500
+)
+
+// ImportRow user to import and its position in the imported data.
+type ImportRow struct {
+	// Row number of the row in the imported data, starting at 1.
+	Row  int
+	User NewUser
+	// Err reason why the row could not be read, the user is not imported.
+	Err error
+}
+
+// ImportRowResult result of importing a row, ID is empty if Err is set.
+type ImportRowResult struct {
+	Row int
+	ID  string
+	Err error
+}
+
+// ImportUsersReport contains the result of every imported row.
+type ImportUsersReport struct {
+	Created int
+	Failed  int
+	Rows    []ImportRowResult
+}
+
+// ImportUsersResult standard response for importing users.
+type ImportUsersResult struct {
+	Report *ImportUsersReport
+	Err    error
+}
+
+// ExportUsersResult standard response for exporting users, the users are
+// read when the export runs.
+type ExportUsersResult struct {
+	Export *UserExport
+	Err    error
+}
+
+// SortField field to sort the users found by and its direction.
+type SortField struct {
+	// Field one of id, first_name, last_name, city, created_at or updated_at.
+	Field      string
+	Descending bool
+}
+
+// sortableFields fields users can be sorted by.
+var sortableFields = []string{
+	repository.SortByID,
+	repository.SortByFirstName,
+	repository.SortByLastName,
+	repository.SortByCity,
+	repository.SortByCreatedAt,
+	repository.SortByUpdatedAt,
+	repository.SortByRelevance,
+}
+
+// facetFields facets users found can be counted by.
+var facetFields = []string{
+	repository.FacetSkills,
+	repository.FacetCity,
+}
+
+// Ways the total of the users found can be counted.
+const (
+	TotalExact    = repository.TotalExact
+	TotalEstimate = repository.TotalEstimate
+	TotalNone     = repository.TotalNone
+)
+
+// totalModes ways the total of the users found can be counted.
+var totalModes = []string{
+	TotalExact,
+	TotalEstimate,
+	TotalNone,
 }
 
 // SearchUserFilter contains filters to search users
 type SearchUserFilter struct {
 	// City user's city.
 	City string
-	// Skill skill of the user.
-	Skills UserSkills
+	// Skills names of skills the users must have, all of them.
+	Skills []string
+	// SkillsAny names of skills the users must have at least one of.
+	SkillsAny []string
+	// SkillsNone names of skills the users must not have.
+	SkillsNone []string
+	// SkillRequirements proficiency the users must have on some skills.
+	SkillRequirements []SkillRequirement
+	// UpdatedSince only users changed at or after this moment.
+	UpdatedSince time.Time
+	// Query text to look for in the names and skills of the users, the users
+	// found are sorted by relevance unless Sort is given.
+	Query string
+	// Sort fields to sort the users by, in order of precedence.
+	Sort []SortField
+	// Cursor opaque cursor returned by a previous search, when it is given
+	// Page is ignored and the page starts at the cursor.
+	Cursor string
+	// Facets facets to count the users found by, e.g. skills or city.
+	Facets []string
+	// TotalMode how the users found are counted: exact, the default, estimate
+	// or none. Estimating or skipping the total is cheaper on big results.
+	TotalMode string
 	// Page page to query
 	Page int
 	// rows per page
@@ -50,6 +208,63 @@ type SearchUsersResult struct {
 	Total       int
 	Page        int
 	RowsPerPage int
+	// NextCursor cursor of the next page, empty if there are no more users.
+	NextCursor string
+	// PrevCursor cursor of the previous page, empty if it is the first one.
+	PrevCursor string
+	// Facets counts of users found by value of every facet asked, keyed
+	// by facet.
+	Facets map[string][]FacetValue
+	// TotalMode how Total was counted, none if it was not.
+	TotalMode string
+}
+
+// FacetValue number of users found with a value of a facet.
+type FacetValue struct {
+	Value string
+	Count int
+}
+
+// UserHistoryFilter contains filters to read the change history of a user.
+type UserHistoryFilter struct {
+	UserID string
+	// Page page to query
+	Page int
+	// rows per page
+	RowsPerPage int
+}
+
+// FieldChange contains the value of a user field before and after a change.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry describes a change on a user.
+type AuditEntry struct {
+	// Operation kind of change, create, update, patch, delete, restore or purge.
+	Operation string `json:"operation"`
+	// Actor identity of who made the change.
+	Actor string `json:"actor"`
+	// ChangedAt moment of the change.
+	ChangedAt time.Time `json:"changed_at"`
+	// Changes field level changes.
+	Changes []FieldChange `json:"changes"`
+}
+
+// UserHistoryResult contains the change history of a user, newest first.
+type UserHistoryResult struct {
+	Entries     []AuditEntry
+	Total       int
+	Page        int
+	RowsPerPage int
+}
+
+// GetUserHistoryResult standard response for get the change history of a user.
+type GetUserHistoryResult struct {
+	History *UserHistoryResult
+	Err     error
 }
 
 // NewUser contains user data.
@@ -67,6 +282,11 @@ type NewUser struct {
 // UpdateUser contains user data to update.
 type UpdateUser struct {
 	ID string `json:"id"`
+	// Version version of the user the update is based on.
+	Version int `json:"version"`
+	// Versions versions the update can be based on when the client accepts
+	// several ones, the one of the current user is picked.
+	Versions []int `json:"-"`
 	// City user's city.
 	City string `json:"city"`
 	// Skill skill of the user.
@@ -80,6 +300,8 @@ type UpdateUser struct {
 // User contains user data.
 type User struct {
 	ID string `json:"id"`
+	// Version current version of the user.
+	Version int `json:"version"`
 	// City user's city.
 	City string `json:"city"`
 	// Skill skill of the user.
@@ -88,6 +310,14 @@ type User struct {
 	FirstName string `json:"first_name"`
 	// LastName last name of the person who is owner of this user.
 	LastName string `json:"last_name"`
+	// CreatedAt moment the user was created.
+	CreatedAt time.Time `json:"created_at"`
+	// CreatedBy identity of who created the user.
+	CreatedBy string `json:"created_by"`
+	// UpdatedAt moment of the last change of the user.
+	UpdatedAt time.Time `json:"updated_at"`
+	// UpdatedBy identity of who made the last change of the user.
+	UpdatedBy string `json:"updated_by"`
 }
 
 // ToUserPortOut transforms new user to a user port out.
@@ -98,6 +328,11 @@ func (u User) ToUserPortOut() repository.User {
 		LastName:  u.LastName,
 		Skills:    u.Skills.toDBSkills(),
 		City:      u.City,
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt,
+		CreatedBy: u.CreatedBy,
+		UpdatedAt: u.UpdatedAt,
+		UpdatedBy: u.UpdatedBy,
 	}
 }
 
@@ -120,19 +355,114 @@ func (u UpdateUser) UpdateUser() User {
 		LastName:  u.LastName,
 		Skills:    UserSkills(u.Skills),
 		City:      u.City,
+		Version:   u.Version,
 	}
 }
 
 func toUserSkills(repoSkills repository.Skills) UserSkills {
-	userSkills := UserSkills(repoSkills)
+	if repoSkills == nil {
+		return nil
+	}
+	userSkills := make(UserSkills, 0, len(repoSkills))
+	for _, v := range repoSkills {
+		userSkills = append(userSkills, UserSkill(v))
+	}
 	return userSkills
 }
 
 func (u UserSkills) toDBSkills() repository.Skills {
-	repoSkills := repository.Skills(u)
+	if u == nil {
+		return nil
+	}
+	repoSkills := make(repository.Skills, 0, len(u))
+	for _, v := range u {
+		repoSkills = append(repoSkills, repository.UserSkill(v))
+	}
+	return repoSkills
+}
+
+// names returns the names of the skills.
+func (u UserSkills) names() []string {
+	names := make([]string, 0, len(u))
+	for _, v := range u {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// UnmarshalJSON decodes a skill, a plain string is a skill with only a name.
+func (u *UserSkill) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*u = UserSkill{Name: name}
+		return nil
+	}
+	type userSkill UserSkill
+	var skill userSkill
+	err := json.Unmarshal(data, &skill)
+	if err != nil {
+		return err
+	}
+	*u = UserSkill(skill)
+	return nil
+}
+
+// isSkillLevel checks if the given level is a known proficiency level.
+func isSkillLevel(level string) bool {
+	for _, v := range skillLevels {
+		if v == level {
+			return true
+		}
+	}
+	return false
+}
+
+// levelsFrom returns the given proficiency level and the ones above it.
+func levelsFrom(minLevel string) []string {
+	for i, v := range skillLevels {
+		if v == minLevel {
+			return skillLevels[i:]
+		}
+	}
+	return nil
+}
+
+func toRepositorySkillNames(names []string) repository.Skills {
+	if len(names) == 0 {
+		return nil
+	}
+	repoSkills := make(repository.Skills, 0, len(names))
+	for _, v := range names {
+		repoSkills = append(repoSkills, repository.UserSkill{Name: v})
+	}
 	return repoSkills
 }
 
+// toRepositorySort transforms the given sort fields, id is added as the last
+// one if it is not there to break ties.
+func toRepositorySort(fields []SortField) []repository.SortField {
+	repoSort := make([]repository.SortField, 0, len(fields))
+	for _, v := range fields {
+		repoSort = append(repoSort, repository.SortField(v))
+	}
+	return repository.StableSort(repoSort)
+}
+
+func toRepositorySkillRequirements(requirements []SkillRequirement) []repository.SkillRequirement {
+	if len(requirements) == 0 {
+		return nil
+	}
+	repoRequirements := make([]repository.SkillRequirement, 0, len(requirements))
+	for _, v := range requirements {
+		repoRequirements = append(repoRequirements, repository.SkillRequirement{
+			Name:     v.Name,
+			Levels:   levelsFrom(v.MinLevel),
+			MinYears: v.MinYears,
+		})
+	}
+	return repoRequirements
+}
+
 // transformUserPortOuttoUser transforms the given user port out to service user.
 func transformUserPortOuttoUser(userRepo *repository.User) *User {
 	if userRepo == nil {
@@ -144,54 +474,97 @@ func transformUserPortOuttoUser(userRepo *repository.User) *User {
 		FirstName: userRepo.FirstName,
 		LastName:  userRepo.LastName,
 		Skills:    toUserSkills(userRepo.Skills),
+		Version:   userRepo.Version,
+		CreatedAt: userRepo.CreatedAt,
+		CreatedBy: userRepo.CreatedBy,
+		UpdatedAt: userRepo.UpdatedAt,
+		UpdatedBy: userRepo.UpdatedBy,
 	}
 	return &newuser
 }
 
 // newGetUserWithIDResult create a new GetUserWithIDResult
 func newGetUserWithIDResult(user *User, err error) GetUserWithIDResult {
-	var errmessage string
-	if err != nil {
-		errmessage = err.Error()
-	}
 	return GetUserWithIDResult{
 		User: user,
-		Err:  errmessage,
+		Err:  err,
+	}
+}
+
+// newExportUsersResult create a new ExportUsersResult
+func newExportUsersResult(export *UserExport, err error) ExportUsersResult {
+	return ExportUsersResult{
+		Export: export,
+		Err:    err,
+	}
+}
+
+// newImportUsersResult create a new ImportUsersResult
+func newImportUsersResult(report *ImportUsersReport, err error) ImportUsersResult {
+	return ImportUsersResult{
+		Report: report,
+		Err:    err,
 	}
 }
 
 // newSearchUsersResult create a new SearchUsersResult
 func newSearchUsersDataResult(result *SearchUsersResult, err error) SearchUsersDataResult {
-	var errmessage string
-	if err != nil {
-		errmessage = err.Error()
-	}
 	return SearchUsersDataResult{
 		SearchResult: result,
-		Err:          errmessage,
+		Err:          err,
+	}
+}
+
+// newGetUserHistoryResult create a new GetUserHistoryResult
+func newGetUserHistoryResult(history *UserHistoryResult, err error) GetUserHistoryResult {
+	return GetUserHistoryResult{
+		History: history,
+		Err:     err,
 	}
 }
 
 // newCreateUserResult create a new CreateUserResponse
 func newCreateUserResult(id string, err error) CreateUserResult {
-	var errmessage string
-	if err != nil {
-		errmessage = err.Error()
-	}
 	return CreateUserResult{
 		ID:  id,
-		Err: errmessage,
+		Err: err,
 	}
 }
 
 // newUpdateUserResult udpate a new UpdateUserResponse
-func newUpdateUserResult(err error) UpdateUserResult {
-	var errmessage string
-	if err != nil {
-		errmessage = err.Error()
-	}
+func newUpdateUserResult(version int, err error) UpdateUserResult {
 	return UpdateUserResult{
-		Err: errmessage,
+		Version: version,
+		Err:     err,
+	}
+}
+
+// newPatchUserResult create a new PatchUserResult
+func newPatchUserResult(version int, err error) PatchUserResult {
+	return PatchUserResult{
+		Version: version,
+		Err:     err,
+	}
+}
+
+// newDeleteUserResult create a new DeleteUserResult
+func newDeleteUserResult(err error) DeleteUserResult {
+	return DeleteUserResult{
+		Err: err,
+	}
+}
+
+// newRestoreUserResult create a new RestoreUserResult
+func newRestoreUserResult(err error) RestoreUserResult {
+	return RestoreUserResult{
+		Err: err,
+	}
+}
+
+// newPurgeUserResult create a new PurgeUserResult
+func newPurgeUserResult(err error) PurgeUserResult {
+	return PurgeUserResult{
+		Err: err,
 	}
 }
 
@@ -213,13 +586,30 @@ func (n NewUser) String() string {
 
 func (s SearchUserFilter) toRepositoryFilters() repository.UserFilter {
 	return repository.UserFilter{
-		City:        s.City,
-		Skills:      s.Skills.toDBSkills(),
-		Page:        s.Page,
-		RowsPerPage: s.RowsPerPage,
+		City:              s.City,
+		Skills:            toRepositorySkillNames(s.Skills),
+		SkillsAny:         toRepositorySkillNames(s.SkillsAny),
+		SkillsNone:        toRepositorySkillNames(s.SkillsNone),
+		SkillRequirements: toRepositorySkillRequirements(s.SkillRequirements),
+		UpdatedSince:      s.UpdatedSince,
+		Query:             s.Query,
+		Sort:              toRepositorySort(s.searchSort()),
+		Facets:            s.Facets,
+		TotalMode:         s.TotalMode,
+		Page:              s.Page,
+		RowsPerPage:       s.RowsPerPage,
 	}
 }
 
+// searchSort returns the sort fields of the search, the most relevant users go
+// first when searching by text without sort fields.
+func (s SearchUserFilter) searchSort() []SortField {
+	if s.Query != "" && len(s.Sort) == 0 {
+		return []SortField{{Field: repository.SortByRelevance, Descending: true}}
+	}
+	return s.Sort
+}
+
 func toSearchUsersResult(repoResult repository.FindUsersResult) SearchUsersResult {
 	var userCollection []User
 	for _, v := range repoResult.Users {
@@ -232,5 +622,52 @@ func toSearchUsersResult(repoResult repository.FindUsersResult) SearchUsersResul
 		Total:       repoResult.Total,
 		Page:        repoResult.Page,
 		RowsPerPage: repoResult.RowsPerPage,
+		Facets:      toFacets(repoResult.Facets),
+		TotalMode:   repoResult.TotalMode,
+	}
+}
+
+func toFacets(repoFacets map[string][]repository.FacetValue) map[string][]FacetValue {
+	if repoFacets == nil {
+		return nil
+	}
+	facets := make(map[string][]FacetValue, len(repoFacets))
+	for facet, values := range repoFacets {
+		facetValues := make([]FacetValue, 0, len(values))
+		for _, v := range values {
+			facetValues = append(facetValues, FacetValue(v))
+		}
+		facets[facet] = facetValues
+	}
+	return facets
+}
+
+func (h UserHistoryFilter) toRepositoryFilter() repository.HistoryFilter {
+	return repository.HistoryFilter{
+		UserID:      h.UserID,
+		Page:        h.Page,
+		RowsPerPage: h.RowsPerPage,
+	}
+}
+
+func toUserHistoryResult(repoResult repository.FindHistoryResult) UserHistoryResult {
+	entries := make([]AuditEntry, 0, len(repoResult.Entries))
+	for _, v := range repoResult.Entries {
+		changes := make([]FieldChange, 0, len(v.Changes))
+		for _, change := range v.Changes {
+			changes = append(changes, FieldChange(change))
+		}
+		entries = append(entries, AuditEntry{
+			Operation: v.Operation,
+			Actor:     v.Actor,
+			ChangedAt: v.ChangedAt,
+			Changes:   changes,
+		})
+	}
+	return UserHistoryResult{
+		Entries:     entries,
+		Total:       repoResult.Total,
+		Page:        repoResult.Page,
+		RowsPerPage: repoResult.RowsPerPage,
 	}
 }
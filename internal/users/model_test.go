@@ -14,14 +14,14 @@ func TestToUserPortOut(t *testing.T) {
 		City:      "Cali",
 		FirstName: "Lucia",
 		LastName:  "Mendez",
-		Skills:    repository.Skills([]string{"worker", "happy"}),
+		Skills:    repository.Skills{{Name: "worker"}, {Name: "happy"}},
 	}
 	givenUser := users.User{
 		ID:        "1234",
 		City:      "Cali",
 		FirstName: "Lucia",
 		LastName:  "Mendez",
-		Skills:    users.UserSkills([]string{"worker", "happy"}),
+		Skills:    users.UserSkills{{Name: "worker"}, {Name: "happy"}},
 	}
 
 	got := givenUser.ToUserPortOut()
@@ -35,14 +35,14 @@ func TestNewUser(t *testing.T) {
 		City:      "Cali",
 		FirstName: "Lucia",
 		LastName:  "Mendez",
-		Skills:    users.UserSkills([]string{"worker", "happy"}),
+		Skills:    users.UserSkills{{Name: "worker"}, {Name: "happy"}},
 	}
 	givenUserID := "1234"
 	givenNewUser := users.NewUser{
 		City:      "Cali",
 		FirstName: "Lucia",
 		LastName:  "Mendez",
-		Skills:    users.UserSkills([]string{"worker", "happy"}),
+		Skills:    users.UserSkills{{Name: "worker"}, {Name: "happy"}},
 	}
 
 	got := givenNewUser.NewUser(givenUserID)
@@ -0,0 +1,256 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+)
+
+// Patch operations supported, they follow RFC 6902 (JSON Patch).
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchTest    = "test"
+)
+
+// Patchable user fields, they are the json pointers to each field.
+const (
+	firstNamePath = "/first_name"
+	lastNamePath  = "/last_name"
+	cityPath      = "/city"
+	skillsPath    = "/skills"
+)
+
+// PatchOperation contains a change to apply on a user.
+type PatchOperation struct {
+	// Op operation to apply: add, remove, replace or test.
+	Op string `json:"op"`
+	// Path json pointer to the field to change, e.g. /city or /skills/-
+	Path string `json:"path"`
+	// Value new value for the field, it is ignored by remove operations.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchUser contains the partial changes to apply on an existing user.
+type PatchUser struct {
+	ID string
+	// Version version of the user the patch is based on.
+	Version int
+	// Versions versions the patch can be based on when the client accepts
+	// several ones.
+	Versions   []int
+	Operations []PatchOperation
+}
+
+// isBasedOn tells whether the patch can be applied on the given version of
+// the user.
+func (p PatchUser) isBasedOn(version int) bool {
+	if len(p.Versions) == 0 {
+		return p.Version == version
+	}
+	return containsVersion(p.Versions, version)
+}
+
+// containsVersion tells whether the given version is one of the versions.
+func containsVersion(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMergePatchUser transforms a RFC 7386 merge patch document into a PatchUser.
+// A field with a null value is removed, any other value replaces the current one.
+func NewMergePatchUser(userID string, document map[string]json.RawMessage) (*PatchUser, error) {
+	patch := PatchUser{
+		ID:         userID,
+		Operations: make([]PatchOperation, 0, len(document)),
+	}
+	for field, value := range document {
+		path := "/" + field
+		if !isPatchableField(path) {
+			return nil, patchError("field %q cannot be patched", field)
+		}
+		operation := PatchOperation{
+			Op:    PatchReplace,
+			Path:  path,
+			Value: value,
+		}
+		if string(value) == "null" {
+			operation.Op = PatchRemove
+			operation.Value = nil
+		}
+		patch.Operations = append(patch.Operations, operation)
+	}
+	return &patch, nil
+}
+
+// apply applies the patch operations on the given user and returns the patched
+// user plus the changes that must be stored.
+func (p PatchUser) apply(current User) (User, repository.UserPatch, error) {
+	patched := current
+	patched.Skills = append(UserSkills(nil), current.Skills...)
+	for _, operation := range p.Operations {
+		err := operation.apply(&patched)
+		if err != nil {
+			return current, repository.UserPatch{}, err
+		}
+	}
+	return patched, newUserPatch(current, patched), nil
+}
+
+func (o PatchOperation) apply(user *User) error {
+	switch o.Path {
+	case firstNamePath:
+		return o.applyString(&user.FirstName)
+	case lastNamePath:
+		return o.applyString(&user.LastName)
+	case cityPath:
+		return o.applyString(&user.City)
+	case skillsPath:
+		return o.applySkills(&user.Skills)
+	}
+	if strings.HasPrefix(o.Path, skillsPath+"/") {
+		return o.applySkill(&user.Skills, strings.TrimPrefix(o.Path, skillsPath+"/"))
+	}
+	return patchError("path %q cannot be patched", o.Path)
+}
+
+func (o PatchOperation) applyString(field *string) error {
+	if o.Op == PatchRemove {
+		*field = ""
+		return nil
+	}
+	var value string
+	err := json.Unmarshal(o.Value, &value)
+	if err != nil {
+		return patchError("value of %q must be a string", o.Path)
+	}
+	switch o.Op {
+	case PatchAdd, PatchReplace:
+		*field = value
+	case PatchTest:
+		if *field != value {
+			return patchError("test operation failed for %q", o.Path)
+		}
+	default:
+		return patchError("operation %q is not supported", o.Op)
+	}
+	return nil
+}
+
+func (o PatchOperation) applySkills(skills *UserSkills) error {
+	if o.Op == PatchRemove {
+		*skills = nil
+		return nil
+	}
+	var value UserSkills
+	err := json.Unmarshal(o.Value, &value)
+	if err != nil {
+		return patchError("value of %q must be a list of skills", o.Path)
+	}
+	switch o.Op {
+	case PatchAdd, PatchReplace:
+		*skills = value
+	case PatchTest:
+		if !equalSkills(*skills, value) {
+			return patchError("test operation failed for %q", o.Path)
+		}
+	default:
+		return patchError("operation %q is not supported", o.Op)
+	}
+	return nil
+}
+
+// applySkill applies the operation on one element of the skills list.
+func (o PatchOperation) applySkill(skills *UserSkills, index string) error {
+	current := *skills
+	position := len(current)
+	if index != "-" {
+		var err error
+		position, err = strconv.Atoi(index)
+		if err != nil || position < 0 || position > len(current) {
+			return patchError("path %q is out of range", o.Path)
+		}
+	}
+	if position == len(current) && o.Op != PatchAdd {
+		return patchError("path %q is out of range", o.Path)
+	}
+	if o.Op == PatchRemove {
+		*skills = append(current[:position:position], current[position+1:]...)
+		return nil
+	}
+	var value UserSkill
+	err := json.Unmarshal(o.Value, &value)
+	if err != nil {
+		return patchError("value of %q must be a skill", o.Path)
+	}
+	switch o.Op {
+	case PatchAdd:
+		newSkills := append(current[:position:position], value)
+		*skills = append(newSkills, current[position:]...)
+	case PatchReplace:
+		current[position] = value
+	case PatchTest:
+		if current[position] != value {
+			return patchError("test operation failed for %q", o.Path)
+		}
+	default:
+		return patchError("operation %q is not supported", o.Op)
+	}
+	return nil
+}
+
+// newUserPatch builds the repository changes with only the fields that
+// are different between the current and the patched user.
+func newUserPatch(current, patched User) repository.UserPatch {
+	changes := repository.UserPatch{
+		ID:      current.ID,
+		Version: current.Version,
+	}
+	if current.FirstName != patched.FirstName {
+		changes.FirstName = &patched.FirstName
+	}
+	if current.LastName != patched.LastName {
+		changes.LastName = &patched.LastName
+	}
+	if current.City != patched.City {
+		changes.City = &patched.City
+	}
+	if !equalSkills(current.Skills, patched.Skills) {
+		skills := patched.Skills.toDBSkills()
+		changes.Skills = &skills
+	}
+	return changes
+}
+
+func isPatchableField(path string) bool {
+	switch path {
+	case firstNamePath, lastNamePath, cityPath, skillsPath:
+		return true
+	}
+	return false
+}
+
+func equalSkills(a, b UserSkills) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// patchError creates an invalid input error for patches that cannot be applied.
+func patchError(format string, args ...interface{}) error {
+	return NewInvalidInputError(ErrorCodeInvalidPatch, fmt.Sprintf(format, args...), nil)
+}
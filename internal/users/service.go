@@ -2,7 +2,10 @@ package users
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
 	"github.com/google/uuid"
@@ -12,22 +15,76 @@ import (
 type Repository interface {
 	FindByID(ctx context.Context, userID string) (*repository.User, error)
 	Save(ctx context.Context, user repository.User) error
+	// SaveAll returns the error of every user that cannot be saved in the
+	// position of the user, and an error if none of them could be saved.
+	SaveAll(ctx context.Context, users []repository.User) ([]error, error)
 	Update(ctx context.Context, user repository.User) error
+	Patch(ctx context.Context, changes repository.UserPatch) error
 	SearchWithFilters(ctx context.Context, filter repository.UserFilter) (repository.FindUsersResult, error)
+	ExportWithFilters(ctx context.Context, filter repository.UserFilter, fn func(repository.User) error) error
+	Delete(ctx context.Context, change repository.UserStateChange) error
+	Restore(ctx context.Context, change repository.UserStateChange) error
+	Purge(ctx context.Context, change repository.UserStateChange) error
+	FindHistory(ctx context.Context, filter repository.HistoryFilter) (repository.FindHistoryResult, error)
+}
+
+// SkillCatalog translates the skills written by clients to their canonical names,
+// the canonical name of each given skill is returned in the same position.
+type SkillCatalog interface {
+	Canonicalize(ctx context.Context, skills []string) ([]string, error)
 }
 
 // Service implements user management logic.
 type Service struct {
 	userRepository Repository
+	skillCatalog   SkillCatalog
+	transactor     Transactor
+	now            func() time.Time
 }
 
+// ServiceOption sets an optional setting of the service.
+type ServiceOption func(*Service)
+
 // NewService creates a new application service
-func NewService(userRepository Repository) *Service {
-	return &Service{
+func NewService(userRepository Repository, options ...ServiceOption) *Service {
+	newService := Service{
 		userRepository: userRepository,
+		transactor:     noTransaction{},
+		now:            utcNow,
+	}
+	for _, option := range options {
+		option(&newService)
+	}
+	return &newService
+}
+
+// WithClock sets the clock the service uses to stamp user changes.
+func WithClock(now func() time.Time) ServiceOption {
+	return func(s *Service) {
+		s.now = now
 	}
 }
 
+// WithSkillCatalog sets the catalog used to store and search canonical skills.
+func WithSkillCatalog(catalog SkillCatalog) ServiceOption {
+	return func(s *Service) {
+		s.skillCatalog = catalog
+	}
+}
+
+// WithTransactor sets the transactor used to run the operations that read and
+// write the repository as one unit of work.
+func WithTransactor(transactor Transactor) ServiceOption {
+	return func(s *Service) {
+		s.transactor = transactor
+	}
+}
+
+// utcNow default service clock.
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
+
 // GetUserWithID get the user with the given id.
 func (s *Service) GetUserWithID(ctx context.Context, userID string) (*User, error) {
 	log.Println(
@@ -43,7 +100,9 @@ func (s *Service) GetUserWithID(ctx context.Context, userID string) (*User, erro
 		)
 		return nil, err
 	}
-	log.Println("level", "DEBUG", "endpoint result 1", result)
+	if result == nil {
+		return nil, ErrUserNotFound
+	}
 
 	user := transformUserPortOuttoUser(result)
 	log.Println(
@@ -61,14 +120,30 @@ func (s *Service) Create(ctx context.Context, newuser NewUser) (string, error) {
 		"msg", "creating user",
 		"method", "Service.Create",
 		"newuser", newuser)
+	err := newuser.validate()
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "new user is not valid",
+			"method", "Service.Create", "error", err,
+		)
+		return "", err
+	}
+	newuser.Skills, err = s.canonicalSkills(ctx, newuser.Skills)
+	if err != nil {
+		return "", err
+	}
 	id := uuid.New().String()
 	user := newuser.NewUser(id)
+	user.CreatedAt = s.now()
+	user.CreatedBy = ActorFromContext(ctx)
+	user.UpdatedAt = user.CreatedAt
+	user.UpdatedBy = user.CreatedBy
 	log.Println(
 		"level", "DEBUG",
 		"msg", "creating user",
 		"method", "Service.Create",
 		"user", user)
-	err := s.userRepository.Save(ctx, user.ToUserPortOut())
+	err = s.userRepository.Save(ctx, user.ToUserPortOut())
 	if err != nil {
 		log.Println("level", "ERROR",
 			"msg", "something goes wrong creating user",
@@ -84,33 +159,273 @@ func (s *Service) Create(ctx context.Context, newuser NewUser) (string, error) {
 	return id, nil
 }
 
-// Update updates an user
-func (s *Service) Update(ctx context.Context, userToUpdate UpdateUser) error {
+// ImportUsers creates the users of the given rows in batches, the skills of
+// a batch are canonicalized and its users saved at once. Rows that cannot be
+// created are reported with their error and don't stop the import.
+func (s *Service) ImportUsers(ctx context.Context, rows []ImportRow) (*ImportUsersReport, error) {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "importing users",
+		"method", "Service.ImportUsers",
+		"rows", len(rows))
+	if len(rows) == 0 {
+		return nil, NewInvalidInputError(ErrorCodeInvalidRequest, "there are no users to import", nil)
+	}
+	if len(rows) > maxImportRows {
+		return nil, NewInvalidInputError(ErrorCodeInvalidRequest, fmt.Sprintf("at most %d users can be imported at once", maxImportRows), nil)
+	}
+	report := ImportUsersReport{
+		Rows: make([]ImportRowResult, len(rows)),
+	}
+	actor := ActorFromContext(ctx)
+	pending := make([]NewUser, 0, importBatchSize)
+	batch := make([]repository.User, 0, importBatchSize)
+	batchRows := make([]int, 0, importBatchSize)
+	saveBatch := func() {
+		if len(pending) == 0 {
+			return
+		}
+		var rowErrs []error
+		err := s.canonicalUsersSkills(ctx, pending)
+		if err == nil {
+			for _, v := range pending {
+				batch = append(batch, s.newImportedUser(v, actor))
+			}
+			rowErrs, err = s.userRepository.SaveAll(ctx, batch)
+		}
+		for i, row := range batchRows {
+			if err == nil && rowErrs[i] == nil {
+				report.Rows[row].ID = batch[i].ID
+				continue
+			}
+			report.Rows[row].ID = ""
+			report.Rows[row].Err = err
+			if err == nil {
+				report.Rows[row].Err = rowErrs[i]
+			}
+		}
+		if err != nil {
+			log.Println("level", "ERROR",
+				"msg", "something goes wrong importing a batch of users",
+				"method", "Service.ImportUsers", "error", err,
+			)
+		}
+		pending = pending[:0]
+		batch = batch[:0]
+		batchRows = batchRows[:0]
+	}
+	for i, row := range rows {
+		report.Rows[i].Row = row.Row
+		if row.Err != nil {
+			report.Rows[i].Err = row.Err
+			continue
+		}
+		err := row.User.validate()
+		if err != nil {
+			report.Rows[i].Err = err
+			continue
+		}
+		pending = append(pending, row.User)
+		batchRows = append(batchRows, i)
+		if len(pending) == importBatchSize {
+			saveBatch()
+		}
+	}
+	saveBatch()
+	for _, v := range report.Rows {
+		if v.Err != nil {
+			report.Failed++
+			continue
+		}
+		report.Created++
+	}
+	log.Println(
+		"level", "INFO",
+		"msg", "users were imported",
+		"method", "Service.ImportUsers",
+		"created", report.Created,
+		"failed", report.Failed)
+	return &report, nil
+}
+
+// newImportedUser builds the user of a valid import row.
+func (s *Service) newImportedUser(newUser NewUser, actor string) repository.User {
+	user := newUser.NewUser(uuid.New().String())
+	user.CreatedAt = s.now()
+	user.CreatedBy = actor
+	user.UpdatedAt = user.CreatedAt
+	user.UpdatedBy = user.CreatedBy
+	return user.ToUserPortOut()
+}
+
+// Update updates an user, the given user must contain the version it is based on.
+// It returns the new version of the user.
+func (s *Service) Update(ctx context.Context, userToUpdate UpdateUser) (int, error) {
 	log.Println(
 		"level", "DEBUG",
 		"msg", "updating user",
 		"method", "Service.Update",
 		"user", userToUpdate)
+	err := userToUpdate.validate()
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "user to update is not valid",
+			"method", "Service.Update", "error", err,
+		)
+		return 0, err
+	}
+	if len(userToUpdate.Versions) > 0 {
+		userToUpdate.Version, err = s.currentVersionIn(ctx, userToUpdate.ID, userToUpdate.Versions)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if userToUpdate.Version < 1 {
+		return 0, ErrVersionRequired
+	}
+	userToUpdate.Skills, err = s.canonicalSkills(ctx, userToUpdate.Skills)
+	if err != nil {
+		return 0, err
+	}
 	user := userToUpdate.UpdateUser()
+	user.UpdatedAt = s.now()
+	user.UpdatedBy = ActorFromContext(ctx)
 	log.Println(
 		"level", "DEBUG",
 		"msg", "updating user",
 		"method", "Service.Update",
 		"user", user)
-	err := s.userRepository.Update(ctx, user.ToUserPortOut())
+	err = s.userRepository.Update(ctx, user.ToUserPortOut())
 	if err != nil {
 		log.Println("level", "ERROR",
 			"msg", "something goes wrong updating user",
 			"method", "Service.Update", "user", user,
 		)
-		return err
+		return 0, err
 	}
 	log.Println(
 		"level", "INFO",
 		"msg", "user was updated successfuly",
 		"method", "Service.Update",
 		"user", user)
-	return nil
+	return user.Version + 1, nil
+}
+
+// currentVersionIn returns the current version of the user if it is one of
+// the given versions. The repository still checks the version on write, so a
+// change made after this read is a conflict.
+func (s *Service) currentVersionIn(ctx context.Context, userID string, versions []int) (int, error) {
+	current, err := s.userRepository.FindByID(ctx, userID)
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong reading the version of the user",
+			"method", "Service.Update", "userID", userID,
+		)
+		return 0, err
+	}
+	if current == nil {
+		return 0, ErrUserNotFound
+	}
+	if !containsVersion(versions, current.Version) {
+		log.Println("level", "ERROR",
+			"msg", "update is based on an old version of the user",
+			"method", "Service.Update", "userID", userID,
+			"versions", versions, "current", current.Version,
+		)
+		return 0, ErrVersionConflict
+	}
+	return current.Version, nil
+}
+
+// Patch applies the given partial changes on an existing user, only
+// the fields that changed are sent to the repository. The patch must contain
+// the version it is based on. It returns the new version of the user.
+func (s *Service) Patch(ctx context.Context, patch PatchUser) (int, error) {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "patching user",
+		"method", "Service.Patch",
+		"userID", patch.ID)
+	if patch.Version < 1 && len(patch.Versions) == 0 {
+		return 0, ErrVersionRequired
+	}
+	var newVersion int
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		newVersion, err = s.patch(ctx, patch)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+// patch reads the user, applies the patch and writes the changes, the read
+// and the write must run in the same unit of work.
+func (s *Service) patch(ctx context.Context, patch PatchUser) (int, error) {
+	current, err := s.userRepository.FindByID(ctx, patch.ID)
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong reading user to patch",
+			"method", "Service.Patch", "userID", patch.ID,
+		)
+		return 0, err
+	}
+	if current == nil {
+		return 0, ErrUserNotFound
+	}
+	if !patch.isBasedOn(current.Version) {
+		log.Println("level", "ERROR",
+			"msg", "patch is based on an old version of the user",
+			"method", "Service.Patch", "userID", patch.ID,
+			"version", patch.Version, "versions", patch.Versions, "current", current.Version,
+		)
+		return 0, ErrVersionConflict
+	}
+	patched, changes, err := patch.apply(*transformUserPortOuttoUser(current))
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "patch cannot be applied",
+			"method", "Service.Patch", "userID", patch.ID, "error", err,
+		)
+		return 0, err
+	}
+	err = patched.validate()
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "patched user is not valid",
+			"method", "Service.Patch", "userID", patch.ID, "error", err,
+		)
+		return 0, err
+	}
+	if changes.Skills != nil {
+		patched.Skills, err = s.canonicalSkills(ctx, patched.Skills)
+		if err != nil {
+			return 0, err
+		}
+		changes = newUserPatch(*transformUserPortOuttoUser(current), patched)
+	}
+	if changes.IsEmpty() {
+		log.Println("level", "DEBUG", "msg", "patch doesn't change the user", "method", "Service.Patch", "userID", patch.ID)
+		return current.Version, nil
+	}
+	changes.UpdatedAt = s.now()
+	changes.UpdatedBy = ActorFromContext(ctx)
+	err = s.userRepository.Patch(ctx, changes)
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong patching user",
+			"method", "Service.Patch", "userID", patch.ID,
+		)
+		return 0, err
+	}
+	log.Println(
+		"level", "INFO",
+		"msg", "user was patched successfuly",
+		"method", "Service.Patch",
+		"user", patched)
+	return current.Version + 1, nil
 }
 
 // SearchUsers search users who match the given filters
@@ -121,7 +436,10 @@ func (s *Service) SearchUsers(ctx context.Context, givenFilter SearchUserFilter)
 		"method", "Service.SearchUsers",
 		"filter", givenFilter,
 	)
-	filters := givenFilter.toRepositoryFilters()
+	filters, err := s.searchFilters(ctx, givenFilter)
+	if err != nil {
+		return nil, err
+	}
 
 	repoResult, err := s.userRepository.SearchWithFilters(ctx, filters)
 	if err != nil {
@@ -134,6 +452,267 @@ func (s *Service) SearchUsers(ctx context.Context, givenFilter SearchUserFilter)
 	}
 
 	result := toSearchUsersResult(repoResult)
+	result.NextCursor, result.PrevCursor = pageCursors(filters, repoResult)
 
 	return &result, nil
 }
+
+// ExportUsers prepares the export of every user found with the given filter,
+// pagination is ignored. The filter is validated right away but the users are
+// read when the export runs, so they can be written as they come.
+func (s *Service) ExportUsers(ctx context.Context, givenFilter SearchUserFilter) (*UserExport, error) {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "exporting users",
+		"method", "Service.ExportUsers",
+		"filter", givenFilter,
+	)
+	givenFilter.Cursor = ""
+	givenFilter.Facets = nil
+	filters, err := s.searchFilters(ctx, givenFilter)
+	if err != nil {
+		return nil, err
+	}
+	filters.Page = 0
+	filters.RowsPerPage = 0
+	return &UserExport{
+		userRepository: s.userRepository,
+		filter:         filters,
+	}, nil
+}
+
+// searchFilters validates the given filter and transforms it to a repository
+// filter with canonical skill names.
+func (s *Service) searchFilters(ctx context.Context, givenFilter SearchUserFilter) (repository.UserFilter, error) {
+	givenFilter.Query = strings.TrimSpace(givenFilter.Query)
+	err := givenFilter.validate()
+	if err != nil {
+		return repository.UserFilter{}, err
+	}
+	requirementNames := make([]string, 0, len(givenFilter.SkillRequirements))
+	for _, v := range givenFilter.SkillRequirements {
+		requirementNames = append(requirementNames, v.Name)
+	}
+	canonical, err := s.canonicalLists(ctx, [][]string{givenFilter.Skills, givenFilter.SkillsAny, givenFilter.SkillsNone, requirementNames})
+	if err != nil {
+		return repository.UserFilter{}, err
+	}
+	givenFilter.Skills, givenFilter.SkillsAny, givenFilter.SkillsNone = canonical[0], canonical[1], canonical[2]
+	for i := range givenFilter.SkillRequirements {
+		givenFilter.SkillRequirements[i].Name = canonical[3][i]
+	}
+	filters := givenFilter.toRepositoryFilters()
+	if givenFilter.Cursor != "" {
+		if sortsByRelevance(filters.Sort) {
+			return repository.UserFilter{}, NewInvalidInputError(ErrorCodeInvalidCursor, "cursors cannot be used when sorting by relevance, use page instead", nil)
+		}
+		filters.Cursor, err = decodeCursor(givenFilter.Cursor, filters.Sort)
+		if err != nil {
+			return repository.UserFilter{}, err
+		}
+	}
+	return filters, nil
+}
+
+// UserExport export of the users found with a filter.
+type UserExport struct {
+	userRepository Repository
+	filter         repository.UserFilter
+}
+
+// Each calls fn with every user of the export, it stops at the first error.
+func (e *UserExport) Each(ctx context.Context, fn func(User) error) error {
+	return e.userRepository.ExportWithFilters(ctx, e.filter, func(v repository.User) error {
+		return fn(*transformUserPortOuttoUser(&v))
+	})
+}
+
+// GetUserHistory returns the changes made on a user, newest first. The history
+// is kept even if the user was deleted.
+func (s *Service) GetUserHistory(ctx context.Context, givenFilter UserHistoryFilter) (*UserHistoryResult, error) {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "reading user history",
+		"method", "Service.GetUserHistory",
+		"filter", givenFilter,
+	)
+	repoResult, err := s.userRepository.FindHistory(ctx, givenFilter.toRepositoryFilter())
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong reading user history",
+			"method", "Service.GetUserHistory",
+			"filter", givenFilter,
+		)
+		return nil, err
+	}
+
+	result := toUserHistoryResult(repoResult)
+
+	return &result, nil
+}
+
+// Delete soft deletes the user with the given id, it can be restored later.
+func (s *Service) Delete(ctx context.Context, userID string) error {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "deleting user",
+		"method", "Service.Delete",
+		"userID", userID)
+	err := s.userRepository.Delete(ctx, s.stateChange(ctx, userID))
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong deleting user",
+			"method", "Service.Delete", "userID", userID,
+		)
+		return err
+	}
+	log.Println(
+		"level", "INFO",
+		"msg", "user was deleted successfuly",
+		"method", "Service.Delete",
+		"userID", userID)
+	return nil
+}
+
+// Restore restores a user that was soft deleted.
+func (s *Service) Restore(ctx context.Context, userID string) error {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "restoring user",
+		"method", "Service.Restore",
+		"userID", userID)
+	err := s.userRepository.Restore(ctx, s.stateChange(ctx, userID))
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong restoring user",
+			"method", "Service.Restore", "userID", userID,
+		)
+		return err
+	}
+	log.Println(
+		"level", "INFO",
+		"msg", "user was restored successfuly",
+		"method", "Service.Restore",
+		"userID", userID)
+	return nil
+}
+
+// Purge removes permanently the user with the given id, deleted or not.
+func (s *Service) Purge(ctx context.Context, userID string) error {
+	log.Println(
+		"level", "DEBUG",
+		"msg", "purging user",
+		"method", "Service.Purge",
+		"userID", userID)
+	err := s.userRepository.Purge(ctx, s.stateChange(ctx, userID))
+	if err != nil {
+		log.Println("level", "ERROR",
+			"msg", "something goes wrong purging user",
+			"method", "Service.Purge", "userID", userID,
+		)
+		return err
+	}
+	log.Println(
+		"level", "INFO",
+		"msg", "user was purged successfuly",
+		"method", "Service.Purge",
+		"userID", userID)
+	return nil
+}
+
+// stateChange stamps the deletion, restoration or purge of the given user.
+func (s *Service) stateChange(ctx context.Context, userID string) repository.UserStateChange {
+	return repository.UserStateChange{
+		ID:        userID,
+		UpdatedAt: s.now(),
+		UpdatedBy: ActorFromContext(ctx),
+	}
+}
+
+// canonicalSkills translates the names of the given skills to their canonical
+// names, when two skills end up with the same name the first one is kept.
+// They are kept as they are if there is no skill catalog.
+func (s *Service) canonicalSkills(ctx context.Context, skills UserSkills) (UserSkills, error) {
+	if s.skillCatalog == nil || len(skills) == 0 {
+		return skills, nil
+	}
+	names, err := s.canonicalNames(ctx, skills.names())
+	if err != nil {
+		return nil, err
+	}
+	return renameSkills(skills, names), nil
+}
+
+// canonicalUsersSkills translates the skills of all the given users with a
+// single read of the catalog.
+func (s *Service) canonicalUsersSkills(ctx context.Context, newUsers []NewUser) error {
+	if s.skillCatalog == nil {
+		return nil
+	}
+	lists := make([][]string, 0, len(newUsers))
+	for _, v := range newUsers {
+		lists = append(lists, v.Skills.names())
+	}
+	canonical, err := s.canonicalLists(ctx, lists)
+	if err != nil {
+		return err
+	}
+	for i := range newUsers {
+		newUsers[i].Skills = renameSkills(newUsers[i].Skills, canonical[i])
+	}
+	return nil
+}
+
+// renameSkills gives the given names to the skills in the same position,
+// when two skills end up with the same name the first one is kept.
+func renameSkills(skills UserSkills, names []string) UserSkills {
+	result := make(UserSkills, 0, len(skills))
+	seen := make(map[string]bool, len(skills))
+	for i, skill := range skills {
+		key := strings.ToLower(names[i])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		skill.Name = names[i]
+		result = append(result, skill)
+	}
+	return result
+}
+
+// canonicalLists translates the skill names of every given list with a
+// single read of the catalog, the lists are returned in the same order.
+func (s *Service) canonicalLists(ctx context.Context, lists [][]string) ([][]string, error) {
+	names := make([]string, 0)
+	for _, v := range lists {
+		names = append(names, v...)
+	}
+	canonical, err := s.canonicalNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]string, 0, len(lists))
+	for _, v := range lists {
+		if v == nil {
+			result = append(result, nil)
+			continue
+		}
+		result = append(result, canonical[:len(v):len(v)])
+		canonical = canonical[len(v):]
+	}
+	return result, nil
+}
+
+// canonicalNames translates the given skill names to their canonical names,
+// they are kept as they are if there is no skill catalog.
+func (s *Service) canonicalNames(ctx context.Context, names []string) ([]string, error) {
+	if s.skillCatalog == nil || len(names) == 0 {
+		return names, nil
+	}
+	canonical, err := s.skillCatalog.Canonicalize(ctx, names)
+	if err != nil {
+		log.Println("level", "ERROR", "msg", "skills cannot be canonicalized", "method", "Service.canonicalNames", "error", err)
+		return nil, err
+	}
+	return canonical, nil
+}
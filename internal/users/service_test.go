@@ -2,8 +2,11 @@ package users_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
 	"github.com/fernandoocampo/users-micro/internal/users"
@@ -15,7 +18,7 @@ func TestFindUserSuccessfully(t *testing.T) {
 	expectedUser := users.User{
 		ID:        "1234",
 		City:      "Cali",
-		Skills:    users.UserSkills([]string{"jack"}),
+		Skills:    users.UserSkills{{Name: "jack"}},
 		FirstName: "Alicia",
 		LastName:  "Mendez",
 	}
@@ -25,7 +28,7 @@ func TestFindUserSuccessfully(t *testing.T) {
 	existingUser := repository.User{
 		ID:        "1234",
 		City:      "Cali",
-		Skills:    repository.Skills([]string{"jack"}),
+		Skills:    repository.Skills{{Name: "jack"}},
 		FirstName: "Alicia",
 		LastName:  "Mendez",
 	}
@@ -49,7 +52,8 @@ func TestFindUserNotFound(t *testing.T) {
 
 	userFound, err := userService.GetUserWithID(ctx, userID)
 
-	assert.NoError(t, err)
+	assert.Equal(t, users.ErrUserNotFound, err)
+	assert.Equal(t, users.ErrorKindNotFound, users.KindOf(err))
 	assert.Nil(t, userFound)
 }
 
@@ -72,7 +76,7 @@ func TestFindUserWithError(t *testing.T) {
 func TestSearchUsersSuccessfully(t *testing.T) {
 	givenFilter := users.SearchUserFilter{
 		City:        "Cali",
-		Skills:      users.UserSkills([]string{"gardener"}),
+		Skills:      []string{"gardener"},
 		Page:        1,
 		RowsPerPage: 10,
 	}
@@ -81,14 +85,14 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 			{
 				ID:        "1234",
 				City:      "Cali",
-				Skills:    users.UserSkills([]string{"jack", "gardener"}),
+				Skills:    users.UserSkills{{Name: "jack"}, {Name: "gardener"}},
 				FirstName: "Alicia",
 				LastName:  "Mendez",
 			},
 			{
 				ID:        "1240",
 				City:      "Cali",
-				Skills:    users.UserSkills([]string{"gardener", "painter"}),
+				Skills:    users.UserSkills{{Name: "gardener"}, {Name: "painter"}},
 				FirstName: "Oliver",
 				LastName:  "Vasquez",
 			},
@@ -102,14 +106,14 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 			{
 				ID:        "1234",
 				City:      "Cali",
-				Skills:    repository.Skills([]string{"jack", "gardener"}),
+				Skills:    repository.Skills{{Name: "jack"}, {Name: "gardener"}},
 				FirstName: "Alicia",
 				LastName:  "Mendez",
 			},
 			{
 				ID:        "1240",
 				City:      "Cali",
-				Skills:    repository.Skills([]string{"gardener", "painter"}),
+				Skills:    repository.Skills{{Name: "gardener"}, {Name: "painter"}},
 				FirstName: "Oliver",
 				LastName:  "Vasquez",
 			},
@@ -131,43 +135,1255 @@ func TestSearchUsersSuccessfully(t *testing.T) {
 	assert.Equal(t, &expectedResult, usersFound)
 }
 
-type userRepoMock struct {
-	err          error
-	repo         map[string]repository.User
-	searchResult repository.FindUsersResult
+func TestSearchUsersWithCanonicalSkills(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		SkillsAny:  []string{"golang", "Rust"},
+		SkillsNone: []string{"golang"},
+		SkillRequirements: []users.SkillRequirement{
+			{Name: "golang", MinLevel: users.SkillAdvanced},
+			{Name: "PostgreSQL", MinYears: 3},
+		},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	expectedFilter := repository.UserFilter{
+		SkillsAny:  repository.Skills{{Name: "Go"}, {Name: "Rust"}},
+		SkillsNone: repository.Skills{{Name: "Go"}},
+		SkillRequirements: []repository.SkillRequirement{
+			{Name: "Go", Levels: []string{users.SkillAdvanced, users.SkillExpert}},
+			{Name: "PostgreSQL", MinYears: 3},
+		},
+		Sort:        []repository.SortField{{Field: repository.SortByID}},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	catalog := countingSkillCatalog{
+		skillCatalogMock: skillCatalogMock{
+			"golang": "Go",
+		},
+	}
+	userService := users.NewService(&userRepository, users.WithSkillCatalog(&catalog))
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedFilter, userRepository.searchFilter)
+	assert.Equal(t, 1, catalog.calls)
 }
 
-func (u *userRepoMock) FindByID(_ context.Context, userID string) (*repository.User, error) {
-	if u.err != nil {
-		return nil, u.err
+func TestSearchUsersSortedWithIDTiebreaker(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Sort: []users.SortField{
+			{Field: "last_name"},
+			{Field: "updated_at", Descending: true},
+		},
+		Page:        1,
+		RowsPerPage: 10,
 	}
-	result, ok := u.repo[userID]
-	if !ok {
-		return nil, nil
+	expectedSort := []repository.SortField{
+		{Field: repository.SortByLastName},
+		{Field: repository.SortByUpdatedAt, Descending: true},
+		{Field: repository.SortByID},
 	}
-	return &result, nil
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSort, userRepository.searchFilter.Sort)
 }
 
-func (u *userRepoMock) Save(ctx context.Context, user repository.User) error {
-	if u.err != nil {
-		return u.err
+func TestSearchUsersWithNextCursor(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Sort:        []users.SortField{{Field: "last_name"}},
+		Page:        1,
+		RowsPerPage: 1,
 	}
-	u.repo[user.ID] = user
-	return nil
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Users:   []repository.User{{ID: "1", LastName: "Mendez"}},
+			Total:   2,
+			HasMore: true,
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	firstPage, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, firstPage.NextCursor)
+	assert.Empty(t, firstPage.PrevCursor)
+
+	givenFilter.Cursor = firstPage.NextCursor
+
+	_, err = userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &repository.Cursor{Values: []string{"Mendez", "1"}}, userRepository.searchFilter.Cursor)
 }
 
-func (u *userRepoMock) Update(ctx context.Context, user repository.User) error {
-	if u.err != nil {
-		return u.err
+func TestSearchUsersWithoutTotalHasNextCursor(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Sort:        []users.SortField{{Field: "last_name"}},
+		TotalMode:   users.TotalNone,
+		Page:        1,
+		RowsPerPage: 1,
 	}
-	u.repo[user.ID] = user
-	return nil
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Users:     []repository.User{{ID: "1", LastName: "Mendez"}},
+			HasMore:   true,
+			TotalMode: repository.TotalNone,
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	result, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Total)
+	assert.NotEmpty(t, result.NextCursor)
 }
 
-func (u *userRepoMock) SearchWithFilters(ctx context.Context, filter repository.UserFilter) (repository.FindUsersResult, error) {
-	var result repository.FindUsersResult
-	if u.err != nil {
-		return result, u.err
+func TestSearchUsersWithCursorOfOtherSort(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Users:   []repository.User{{ID: "1", LastName: "Mendez"}},
+			Total:   2,
+			HasMore: true,
+		},
 	}
-	return u.searchResult, nil
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	firstPage, err := userService.SearchUsers(ctx, users.SearchUserFilter{
+		Sort:        []users.SortField{{Field: "last_name"}},
+		Page:        1,
+		RowsPerPage: 1,
+	})
+	assert.NoError(t, err)
+
+	_, err = userService.SearchUsers(ctx, users.SearchUserFilter{
+		Sort:        []users.SortField{{Field: "city"}},
+		Cursor:      firstPage.NextCursor,
+		Page:        1,
+		RowsPerPage: 1,
+	})
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidCursor, users.CodeOf(err))
+}
+
+func TestSearchUsersWithMalformedCursor(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Cursor:      "not-a-cursor",
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidCursor, users.CodeOf(err))
+}
+
+func TestSearchUsersByTextSortedByRelevance(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Query:       "  Fernando Ocampo ",
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	expectedSort := []repository.SortField{
+		{Field: repository.SortByRelevance, Descending: true},
+		{Field: repository.SortByID},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Users: []repository.User{{ID: "1", FirstName: "Fernando", LastName: "Ocampo"}},
+			Total: 20,
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	result, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Fernando Ocampo", userRepository.searchFilter.Query)
+	assert.Equal(t, expectedSort, userRepository.searchFilter.Sort)
+	assert.Empty(t, result.NextCursor)
+}
+
+func TestSearchUsersByRelevanceWithoutText(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Sort:        []users.SortField{{Field: "relevance", Descending: true}},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidRequest, users.CodeOf(err))
+}
+
+func TestSearchUsersByRelevanceWithCursor(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Query:       "Fernando",
+		Cursor:      "abc",
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidCursor, users.CodeOf(err))
+}
+
+func TestSearchUsersWithFacets(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Facets:      []string{"skills", "city"},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	expectedFacets := map[string][]users.FacetValue{
+		"skills": {{Value: "Go", Count: 120}, {Value: "Python", Count: 80}},
+		"city":   {{Value: "Bogotá", Count: 45}},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Facets: map[string][]repository.FacetValue{
+				repository.FacetSkills: {{Value: "Go", Count: 120}, {Value: "Python", Count: 80}},
+				repository.FacetCity:   {{Value: "Bogotá", Count: 45}},
+			},
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	result, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{repository.FacetSkills, repository.FacetCity}, userRepository.searchFilter.Facets)
+	assert.Equal(t, expectedFacets, result.Facets)
+}
+
+func TestSearchUsersWithUnknownFacet(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Facets:      []string{"password"},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidRequest, users.CodeOf(err))
+}
+
+func TestSearchUsersWithEstimatedTotal(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		TotalMode:   users.TotalEstimate,
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Total:     48210,
+			TotalMode: repository.TotalEstimate,
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	result, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, repository.TotalEstimate, userRepository.searchFilter.TotalMode)
+	assert.Equal(t, 48210, result.Total)
+	assert.Equal(t, users.TotalEstimate, result.TotalMode)
+}
+
+func TestSearchUsersWithUnknownTotalMode(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		TotalMode:   "approximate",
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidRequest, users.CodeOf(err))
+}
+
+func TestSearchUsersSortedByUnknownField(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Sort:        []users.SortField{{Field: "password"}},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidRequest, users.CodeOf(err))
+}
+
+func TestSearchUsersWithUnknownSkillLevel(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		SkillRequirements: []users.SkillRequirement{
+			{Name: "Go", MinLevel: "guru"},
+		},
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.SearchUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+	assert.Equal(t, users.ErrorCodeInvalidRequest, users.CodeOf(err))
+}
+
+func TestGetUserHistorySuccessfully(t *testing.T) {
+	givenFilter := users.UserHistoryFilter{
+		UserID:      "1234",
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	expectedResult := users.UserHistoryResult{
+		Entries: []users.AuditEntry{
+			{
+				Operation: repository.AuditPatch,
+				Actor:     "recruiter-2",
+				ChangedAt: fixedNow,
+				Changes: []users.FieldChange{
+					{Field: "city", Before: "Cali", After: "Bogota"},
+				},
+			},
+		},
+		Total:       2,
+		Page:        1,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		history: repository.FindHistoryResult{
+			Entries: []repository.AuditEntry{
+				{
+					UserID:    "1234",
+					Operation: repository.AuditPatch,
+					Actor:     "recruiter-2",
+					ChangedAt: fixedNow,
+					Changes: repository.FieldChanges{
+						{Field: "city", Before: "Cali", After: "Bogota"},
+					},
+				},
+			},
+			Total:       2,
+			Page:        1,
+			RowsPerPage: 10,
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	history, err := userService.GetUserHistory(ctx, givenFilter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expectedResult, history)
+}
+
+func TestDeleteUserSuccessfully(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	existingUser := repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userRepository.repo[existingUser.ID] = existingUser
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	err := userService.Delete(ctx, "1234")
+	userFound, findErr := userService.GetUserWithID(ctx, "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, users.ErrUserNotFound, findErr)
+	assert.Nil(t, userFound)
+}
+
+func TestRestoreUserSuccessfully(t *testing.T) {
+	expectedUser := users.User{
+		ID:        "1234",
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	existingUser := repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userRepository.repo[existingUser.ID] = existingUser
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	deleteErr := userService.Delete(ctx, "1234")
+	err := userService.Restore(ctx, "1234")
+	userFound, findErr := userService.GetUserWithID(ctx, "1234")
+
+	assert.NoError(t, deleteErr)
+	assert.NoError(t, err)
+	assert.NoError(t, findErr)
+	assert.Equal(t, &expectedUser, userFound)
+}
+
+func TestDeleteUserWithError(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		err:  errors.New("any error"),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	err := userService.Delete(ctx, "1234")
+
+	assert.Error(t, err)
+	assert.Equal(t, errors.New("any error"), err)
+}
+
+func TestDeleteRestoreAndPurgeUserStampTheChange(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: map[string]repository.User{
+			"1234": {ID: "1234", FirstName: "Alicia", LastName: "Mendez"},
+		},
+	}
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock))
+	ctx := users.WithActor(context.TODO(), "recruiter-1")
+	expectedChange := repository.UserStateChange{ID: "1234", UpdatedAt: fixedNow, UpdatedBy: "recruiter-1"}
+
+	assert.NoError(t, userService.Delete(ctx, "1234"))
+	assert.NoError(t, userService.Restore(ctx, "1234"))
+	assert.NoError(t, userService.Purge(ctx, "1234"))
+
+	assert.Equal(t, []repository.UserStateChange{expectedChange, expectedChange, expectedChange}, userRepository.stateChanges)
+}
+
+func TestCreateUserStampsCreation(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	givenUser := users.NewUser{
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock))
+	ctx := users.WithActor(context.TODO(), "recruiter-1")
+
+	userID, err := userService.Create(ctx, givenUser)
+
+	assert.NoError(t, err)
+	savedUser := userRepository.repo[userID]
+	assert.Equal(t, fixedNow, savedUser.CreatedAt)
+	assert.Equal(t, "recruiter-1", savedUser.CreatedBy)
+	assert.Equal(t, fixedNow, savedUser.UpdatedAt)
+	assert.Equal(t, "recruiter-1", savedUser.UpdatedBy)
+}
+
+func TestImportUsers(t *testing.T) {
+	givenRows := []users.ImportRow{
+		{Row: 1, User: users.NewUser{FirstName: "Alicia", LastName: "Mendez", City: "Cali", Skills: users.UserSkills{{Name: "Go"}}}},
+		{Row: 2, User: users.NewUser{LastName: "Vasquez", City: "Cali"}},
+		{Row: 3, Err: users.NewInvalidInputError(users.ErrorCodeInvalidRequest, "row 3 is not valid json", nil)},
+		{Row: 4, User: users.NewUser{FirstName: "Oliver", LastName: "Vasquez", City: "Cali"}},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock))
+	ctx := users.WithActor(context.TODO(), "recruiter-1")
+
+	report, err := userService.ImportUsers(ctx, givenRows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 2, report.Failed)
+	assert.Len(t, userRepository.batches, 1)
+	assert.Len(t, userRepository.repo, 2)
+	assert.NotEmpty(t, report.Rows[0].ID)
+	assert.Equal(t, "Alicia", userRepository.repo[report.Rows[0].ID].FirstName)
+	assert.Equal(t, "recruiter-1", userRepository.repo[report.Rows[0].ID].CreatedBy)
+	assert.Empty(t, report.Rows[1].ID)
+	assert.IsType(t, users.ValidationErrors{}, report.Rows[1].Err)
+	assert.Equal(t, givenRows[2].Err, report.Rows[2].Err)
+	assert.Equal(t, 4, report.Rows[3].Row)
+	assert.NotEmpty(t, report.Rows[3].ID)
+}
+
+func TestImportUsersReadsCatalogOncePerBatch(t *testing.T) {
+	givenRows := make([]users.ImportRow, 0, 501)
+	for i := 1; i <= 501; i++ {
+		givenRows = append(givenRows, users.ImportRow{
+			Row:  i,
+			User: users.NewUser{FirstName: "Alicia", LastName: "Mendez", Skills: users.UserSkills{{Name: "golang"}, {Name: "Go"}, {Name: "Rust"}}},
+		})
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	catalog := countingSkillCatalog{
+		skillCatalogMock: skillCatalogMock{
+			"golang": "Go",
+		},
+	}
+	userService := users.NewService(&userRepository, users.WithSkillCatalog(&catalog))
+	ctx := context.TODO()
+
+	report, err := userService.ImportUsers(ctx, givenRows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 501, report.Created)
+	assert.Equal(t, 2, catalog.calls)
+	assert.Equal(t, repository.Skills{{Name: "Go"}, {Name: "Rust"}}, userRepository.repo[report.Rows[500].ID].Skills)
+}
+
+func TestImportUsersInBatches(t *testing.T) {
+	givenRows := make([]users.ImportRow, 0, 501)
+	for i := 1; i <= 501; i++ {
+		givenRows = append(givenRows, users.ImportRow{
+			Row:  i,
+			User: users.NewUser{FirstName: "Alicia", LastName: "Mendez", City: "Cali"},
+		})
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	report, err := userService.ImportUsers(ctx, givenRows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 501, report.Created)
+	assert.Len(t, userRepository.batches, 2)
+	assert.Len(t, userRepository.batches[0], 500)
+	assert.Len(t, userRepository.batches[1], 1)
+}
+
+func TestImportUsersReportsUsersTheRepositoryRejects(t *testing.T) {
+	givenRows := []users.ImportRow{
+		{Row: 1, User: users.NewUser{FirstName: "Alicia", LastName: "Mendez", City: "Cali"}},
+		{Row: 2, User: users.NewUser{FirstName: "Oliver", LastName: "Vasquez", City: "Cali"}},
+		{Row: 3, User: users.NewUser{FirstName: "Cecilia", LastName: "Mendez", City: "Cali"}},
+	}
+	conflictErr := users.NewConflictError(users.ErrorCodeUserAlreadyExists, "user already exists", nil)
+	userRepository := userRepoMock{
+		repo:     make(map[string]repository.User),
+		saveErrs: []error{nil, conflictErr, nil},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	report, err := userService.ImportUsers(ctx, givenRows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.NotEmpty(t, report.Rows[0].ID)
+	assert.Empty(t, report.Rows[1].ID)
+	assert.Equal(t, conflictErr, report.Rows[1].Err)
+	assert.NotEmpty(t, report.Rows[2].ID)
+}
+
+func TestImportUsersButRepositoryFails(t *testing.T) {
+	givenRows := []users.ImportRow{
+		{Row: 1, User: users.NewUser{FirstName: "Alicia", LastName: "Mendez", City: "Cali"}},
+	}
+	repositoryErr := users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "users cannot be stored", nil)
+	userRepository := userRepoMock{
+		err:  repositoryErr,
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	report, err := userService.ImportUsers(ctx, givenRows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.Empty(t, report.Rows[0].ID)
+	assert.Equal(t, repositoryErr, report.Rows[0].Err)
+}
+
+func TestImportNoUsers(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.ImportUsers(ctx, nil)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+}
+
+func TestExportUsers(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		City:        "Cali",
+		Facets:      []string{"city"},
+		Page:        3,
+		RowsPerPage: 10,
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+		searchResult: repository.FindUsersResult{
+			Users: []repository.User{
+				{ID: "1", FirstName: "Fernando", LastName: "Ocampo", City: "Cali"},
+				{ID: "2", FirstName: "Alicia", LastName: "Mendez", City: "Cali"},
+			},
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	export, err := userService.ExportUsers(ctx, givenFilter)
+	if err != nil {
+		t.Error("unexpected error", err)
+		t.FailNow()
+	}
+	var exported []string
+	err = export.Each(ctx, func(user users.User) error {
+		exported = append(exported, user.ID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, exported)
+	assert.Equal(t, "Cali", userRepository.searchFilter.City)
+	assert.Empty(t, userRepository.searchFilter.Facets)
+	assert.Equal(t, 0, userRepository.searchFilter.Page)
+	assert.Equal(t, 0, userRepository.searchFilter.RowsPerPage)
+}
+
+func TestExportUsersWithInvalidFilter(t *testing.T) {
+	givenFilter := users.SearchUserFilter{
+		Sort: []users.SortField{{Field: "password"}},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.ExportUsers(ctx, givenFilter)
+
+	assert.Equal(t, users.ErrorKindInvalidInput, users.KindOf(err))
+}
+
+func TestUpdateUserStampsChangeWithAnonymousActor(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	givenUser := users.UpdateUser{
+		ID:        "1234",
+		Version:   1,
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock))
+
+	_, err := userService.Update(context.TODO(), givenUser)
+
+	assert.NoError(t, err)
+	updatedUser := userRepository.repo["1234"]
+	assert.True(t, updatedUser.CreatedAt.IsZero())
+	assert.Equal(t, fixedNow, updatedUser.UpdatedAt)
+	assert.Equal(t, users.AnonymousActor, updatedUser.UpdatedBy)
+}
+
+func TestUpdateUserWithVersionList(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   3,
+	}
+	givenUser := users.UpdateUser{
+		ID:        "1234",
+		Versions:  []int{2, 3},
+		City:      "Bogota",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userService := users.NewService(&userRepository)
+
+	newVersion, err := userService.Update(context.TODO(), givenUser)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, newVersion)
+	assert.Equal(t, 3, userRepository.repo["1234"].Version)
+	assert.Equal(t, "Bogota", userRepository.repo["1234"].City)
+}
+
+func TestUpdateUserWithOldVersionList(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   3,
+	}
+	givenUser := users.UpdateUser{
+		ID:        "1234",
+		Versions:  []int{1, 2},
+		City:      "Bogota",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	userService := users.NewService(&userRepository)
+
+	_, err := userService.Update(context.TODO(), givenUser)
+
+	assert.Equal(t, users.ErrVersionConflict, err)
+	assert.Equal(t, "Cali", userRepository.repo["1234"].City)
+}
+
+func TestPatchUserOnlyChangesGivenFields(t *testing.T) {
+	newCity := "Bogota"
+	expectedPatches := []repository.UserPatch{
+		{
+			ID:        "1234",
+			Version:   1,
+			City:      &newCity,
+			UpdatedAt: fixedNow,
+			UpdatedBy: "recruiter-1",
+		},
+	}
+	expectedUser := users.User{
+		ID:        "1234",
+		City:      "Bogota",
+		Skills:    users.UserSkills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   2,
+		UpdatedAt: fixedNow,
+		UpdatedBy: "recruiter-1",
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		Version:   1,
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "jack"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	givenPatch, err := users.NewMergePatchUser("1234", map[string]json.RawMessage{
+		"city":       json.RawMessage(`"Bogota"`),
+		"first_name": json.RawMessage(`"Alicia"`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building merge patch: %s", err)
+	}
+	givenPatch.Version = 1
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock))
+	ctx := users.WithActor(context.TODO(), "recruiter-1")
+
+	version, err := userService.Patch(ctx, *givenPatch)
+	userFound, findErr := userService.GetUserWithID(ctx, "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.NoError(t, findErr)
+	assert.Equal(t, expectedPatches, userRepository.patches)
+	assert.Equal(t, &expectedUser, userFound)
+}
+
+func TestPatchUserWithJSONPatch(t *testing.T) {
+	expectedUser := users.User{
+		ID:        "1234",
+		City:      "",
+		Skills:    users.UserSkills{{Name: "gardener"}, {Name: "painter"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   2,
+		UpdatedAt: fixedNow,
+		UpdatedBy: "recruiter-1",
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		Version:   1,
+		City:      "Cali",
+		Skills:    repository.Skills{{Name: "jack"}, {Name: "gardener"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	givenPatch := users.PatchUser{
+		ID:      "1234",
+		Version: 1,
+		Operations: []users.PatchOperation{
+			{Op: users.PatchTest, Path: "/first_name", Value: json.RawMessage(`"Alicia"`)},
+			{Op: users.PatchRemove, Path: "/skills/0"},
+			{Op: users.PatchAdd, Path: "/skills/-", Value: json.RawMessage(`"painter"`)},
+			{Op: users.PatchRemove, Path: "/city"},
+		},
+	}
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock))
+	ctx := users.WithActor(context.TODO(), "recruiter-1")
+
+	_, err := userService.Patch(ctx, givenPatch)
+	userFound, findErr := userService.GetUserWithID(ctx, "1234")
+
+	assert.NoError(t, err)
+	assert.NoError(t, findErr)
+	assert.Equal(t, &expectedUser, userFound)
+}
+
+func TestPatchUserFailedTest(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		Version:   1,
+		City:      "Cali",
+		FirstName: "Alicia",
+	}
+	givenPatch := users.PatchUser{
+		ID:      "1234",
+		Version: 1,
+		Operations: []users.PatchOperation{
+			{Op: users.PatchTest, Path: "/first_name", Value: json.RawMessage(`"Lucia"`)},
+			{Op: users.PatchReplace, Path: "/city", Value: json.RawMessage(`"Bogota"`)},
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.Patch(ctx, givenPatch)
+
+	assert.Error(t, err)
+	assert.Empty(t, userRepository.patches)
+	assert.Equal(t, "Cali", userRepository.repo["1234"].City)
+}
+
+func TestPatchUserWithOldVersion(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   3,
+	}
+	givenPatch := users.PatchUser{
+		ID:      "1234",
+		Version: 2,
+		Operations: []users.PatchOperation{
+			{Op: users.PatchReplace, Path: "/city", Value: json.RawMessage(`"Bogota"`)},
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.Patch(ctx, givenPatch)
+
+	assert.Equal(t, users.ErrVersionConflict, err)
+	assert.Equal(t, users.ErrorKindConflict, users.KindOf(err))
+	assert.Empty(t, userRepository.patches)
+}
+
+func TestPatchUserWithVersionList(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   3,
+	}
+	givenPatch := users.PatchUser{
+		ID:       "1234",
+		Versions: []int{2, 3},
+		Operations: []users.PatchOperation{
+			{Op: users.PatchReplace, Path: "/city", Value: json.RawMessage(`"Bogota"`)},
+		},
+	}
+	userService := users.NewService(&userRepository)
+
+	newVersion, err := userService.Patch(context.TODO(), givenPatch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, newVersion)
+	assert.Equal(t, "Bogota", userRepository.repo["1234"].City)
+}
+
+func TestCreateUserWithCanonicalSkills(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	givenUser := users.NewUser{
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "golang"}, {Name: "Go"}, {Name: "gardener"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	catalog := skillCatalogMock{
+		"golang": "Go",
+		"go":     "Go",
+	}
+	userService := users.NewService(&userRepository, users.WithSkillCatalog(catalog))
+	ctx := context.TODO()
+
+	userID, err := userService.Create(ctx, givenUser)
+
+	assert.NoError(t, err)
+	assert.Equal(t, repository.Skills{{Name: "Go"}, {Name: "gardener"}}, userRepository.repo[userID].Skills)
+}
+
+func TestCreateUserWithUnavailableSkillCatalog(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	givenUser := users.NewUser{
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "golang"}},
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+	}
+	catalogErr := users.NewUnavailableError(users.ErrorCodeRepositoryUnavailable, "catalog is down", nil)
+	userService := users.NewService(&userRepository, users.WithSkillCatalog(failingSkillCatalog{err: catalogErr}))
+	ctx := context.TODO()
+
+	_, err := userService.Create(ctx, givenUser)
+
+	assert.Equal(t, catalogErr, err)
+	assert.Empty(t, userRepository.repo)
+}
+
+func TestUpdateUserWithoutVersion(t *testing.T) {
+	givenUser := users.UpdateUser{
+		ID:        "1234",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		City:      "Cali",
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.Update(ctx, givenUser)
+
+	assert.Equal(t, users.ErrVersionRequired, err)
+}
+
+func TestPatchUserInTransaction(t *testing.T) {
+	commitErr := errors.New("commit failed")
+	userRepository := userRepoMock{
+		repo: map[string]repository.User{
+			"1234": {ID: "1234", Version: 1, City: "Cali", FirstName: "Alicia", LastName: "Mendez"},
+		},
+	}
+	transactor := transactorMock{err: commitErr}
+	givenPatch, err := users.NewMergePatchUser("1234", map[string]json.RawMessage{
+		"city": json.RawMessage(`"Bogota"`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building merge patch: %s", err)
+	}
+	givenPatch.Version = 1
+	userService := users.NewService(&userRepository, users.WithClock(fixedClock), users.WithTransactor(&transactor))
+
+	_, patchErr := userService.Patch(context.TODO(), *givenPatch)
+
+	assert.Equal(t, commitErr, patchErr)
+	assert.Equal(t, 1, transactor.calls)
+	assert.Len(t, userRepository.patches, 1)
+}
+
+type userRepoMock struct {
+	err          error
+	repo         map[string]repository.User
+	deleted      map[string]repository.User
+	patches      []repository.UserPatch
+	searchResult repository.FindUsersResult
+	searchFilter repository.UserFilter
+	history      repository.FindHistoryResult
+	batches      [][]repository.User
+	// saveErrs errors of the users of a batch, by position.
+	saveErrs     []error
+	stateChanges []repository.UserStateChange
+}
+
+func (u *userRepoMock) FindByID(_ context.Context, userID string) (*repository.User, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	result, ok := u.repo[userID]
+	if !ok {
+		return nil, users.ErrUserNotFound
+	}
+	return &result, nil
+}
+
+func (u *userRepoMock) Save(ctx context.Context, user repository.User) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.repo[user.ID] = user
+	return nil
+}
+
+func (u *userRepoMock) SaveAll(ctx context.Context, newUsers []repository.User) ([]error, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	u.batches = append(u.batches, append([]repository.User(nil), newUsers...))
+	userErrs := make([]error, len(newUsers))
+	for i, v := range newUsers {
+		if i < len(u.saveErrs) && u.saveErrs[i] != nil {
+			userErrs[i] = u.saveErrs[i]
+			continue
+		}
+		u.repo[v.ID] = v
+	}
+	return userErrs, nil
+}
+
+func (u *userRepoMock) Update(ctx context.Context, user repository.User) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.repo[user.ID] = user
+	return nil
+}
+
+func (u *userRepoMock) SearchWithFilters(ctx context.Context, filter repository.UserFilter) (repository.FindUsersResult, error) {
+	var result repository.FindUsersResult
+	if u.err != nil {
+		return result, u.err
+	}
+	u.searchFilter = filter
+	return u.searchResult, nil
+}
+
+func (u *userRepoMock) ExportWithFilters(ctx context.Context, filter repository.UserFilter, fn func(repository.User) error) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.searchFilter = filter
+	for _, v := range u.searchResult.Users {
+		err := fn(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *userRepoMock) Patch(ctx context.Context, changes repository.UserPatch) error {
+	if u.err != nil {
+		return u.err
+	}
+	user, ok := u.repo[changes.ID]
+	if !ok {
+		return users.ErrUserNotFound
+	}
+	u.patches = append(u.patches, changes)
+	patched := changes.Apply(user)
+	patched.Version++
+	u.repo[changes.ID] = patched
+	return nil
+}
+
+func (u *userRepoMock) Delete(ctx context.Context, change repository.UserStateChange) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.stateChanges = append(u.stateChanges, change)
+	userID := change.ID
+	user, ok := u.repo[userID]
+	if !ok {
+		return users.ErrUserNotFound
+	}
+	if u.deleted == nil {
+		u.deleted = make(map[string]repository.User)
+	}
+	u.deleted[userID] = user
+	delete(u.repo, userID)
+	return nil
+}
+
+func (u *userRepoMock) Restore(ctx context.Context, change repository.UserStateChange) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.stateChanges = append(u.stateChanges, change)
+	userID := change.ID
+	user, ok := u.deleted[userID]
+	if !ok {
+		return users.ErrUserNotFound
+	}
+	u.repo[userID] = user
+	delete(u.deleted, userID)
+	return nil
+}
+
+func (u *userRepoMock) Purge(ctx context.Context, change repository.UserStateChange) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.stateChanges = append(u.stateChanges, change)
+	delete(u.repo, change.ID)
+	delete(u.deleted, change.ID)
+	return nil
+}
+
+func (u *userRepoMock) FindHistory(ctx context.Context, filter repository.HistoryFilter) (repository.FindHistoryResult, error) {
+	var result repository.FindHistoryResult
+	if u.err != nil {
+		return result, u.err
+	}
+	return u.history, nil
+}
+
+// fixedNow moment returned by the clock of the services under test.
+var fixedNow = time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+func fixedClock() time.Time {
+	return fixedNow
+}
+
+// transactorMock runs units of work and fails as if the commit failed when err is set.
+type transactorMock struct {
+	calls int
+	err   error
+}
+
+func (t *transactorMock) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.calls++
+	err := fn(ctx)
+	if err != nil {
+		return err
+	}
+	return t.err
+}
+
+// skillCatalogMock maps lower case skills to their canonical names.
+type skillCatalogMock map[string]string
+
+func (s skillCatalogMock) Canonicalize(ctx context.Context, names []string) ([]string, error) {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if canonical, ok := s[strings.ToLower(name)]; ok {
+			name = canonical
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// countingSkillCatalog counts how many times the catalog is read.
+type countingSkillCatalog struct {
+	skillCatalogMock
+	calls int
+}
+
+func (c *countingSkillCatalog) Canonicalize(ctx context.Context, names []string) ([]string, error) {
+	c.calls++
+	return c.skillCatalogMock.Canonicalize(ctx, names)
+}
+
+type failingSkillCatalog struct {
+	err error
+}
+
+func (f failingSkillCatalog) Canonicalize(ctx context.Context, names []string) ([]string, error) {
+	return nil, f.err
 }
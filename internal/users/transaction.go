@@ -0,0 +1,19 @@
+package users
+
+import "context"
+
+// Transactor runs a unit of work in a transaction. The repositories join the
+// transaction through the context given to the function, so every change the
+// function makes is committed if it succeeds or rolled back if it fails.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// noTransaction runs units of work without a transaction, every repository
+// operation stands on its own.
+type noTransaction struct{}
+
+// WithinTransaction calls fn with the given context.
+func (noTransaction) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
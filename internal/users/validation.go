@@ -0,0 +1,201 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+)
+
+// Validation limits for user data.
+const (
+	maxNameLength  = 100
+	maxCityLength  = 100
+	maxSkillLength = 50
+	maxSkills      = 50
+	maxSkillYears  = 80
+	maxQueryLength = 200
+)
+
+// Validation error codes, clients can rely on them.
+const (
+	ValidationRequired  = "required"
+	ValidationTooLong   = "too_long"
+	ValidationBlank     = "blank"
+	ValidationDuplicate = "duplicate"
+	ValidationTooMany   = "too_many"
+	ValidationInvalid   = "invalid"
+)
+
+// ValidationError describes a rule violation on a user field.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors contains all the violations found validating a user.
+type ValidationErrors []ValidationError
+
+// Error implements error interface.
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, violation := range v {
+		messages = append(messages, violation.Message)
+	}
+	return "invalid user data: " + strings.Join(messages, ", ")
+}
+
+func (v *ValidationErrors) add(field, code, message string) {
+	*v = append(*v, ValidationError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	})
+}
+
+// err returns nil if there are no violations.
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// validate checks the data of a user to be created.
+func (n NewUser) validate() error {
+	var violations ValidationErrors
+	validateUserData(&violations, n.FirstName, n.LastName, n.City, n.Skills)
+	return violations.err()
+}
+
+// validate checks the data of a user to be updated.
+func (u UpdateUser) validate() error {
+	var violations ValidationErrors
+	if strings.TrimSpace(u.ID) == "" {
+		violations.add("id", ValidationRequired, "id is required")
+	}
+	validateUserData(&violations, u.FirstName, u.LastName, u.City, u.Skills)
+	return violations.err()
+}
+
+// validate checks the data of a user, it is used to check patched users.
+func (u User) validate() error {
+	var violations ValidationErrors
+	validateUserData(&violations, u.FirstName, u.LastName, u.City, u.Skills)
+	return violations.err()
+}
+
+func validateUserData(violations *ValidationErrors, firstName, lastName, city string, skills UserSkills) {
+	validateRequiredText(violations, "first_name", firstName, maxNameLength)
+	validateRequiredText(violations, "last_name", lastName, maxNameLength)
+	validateText(violations, "city", city, maxCityLength)
+	validateSkills(violations, skills)
+}
+
+func validateRequiredText(violations *ValidationErrors, field, value string, maxLength int) {
+	if strings.TrimSpace(value) == "" {
+		violations.add(field, ValidationRequired, fmt.Sprintf("%s is required", field))
+		return
+	}
+	validateText(violations, field, value, maxLength)
+}
+
+func validateText(violations *ValidationErrors, field, value string, maxLength int) {
+	if utf8.RuneCountInString(value) > maxLength {
+		violations.add(field, ValidationTooLong, fmt.Sprintf("%s must have at most %d characters", field, maxLength))
+	}
+}
+
+func validateSkills(violations *ValidationErrors, skills UserSkills) {
+	if len(skills) > maxSkills {
+		violations.add("skills", ValidationTooMany, fmt.Sprintf("skills must have at most %d elements", maxSkills))
+	}
+	seen := make(map[string]bool, len(skills))
+	for i, skill := range skills {
+		field := fmt.Sprintf("skills[%d]", i)
+		validateSkillProficiency(violations, field, skill)
+		key := strings.ToLower(strings.TrimSpace(skill.Name))
+		if key == "" {
+			violations.add(field, ValidationBlank, fmt.Sprintf("%s must not be blank", field))
+			continue
+		}
+		validateText(violations, field, skill.Name, maxSkillLength)
+		if seen[key] {
+			violations.add(field, ValidationDuplicate, fmt.Sprintf("%s %q is duplicated", field, skill.Name))
+		}
+		seen[key] = true
+	}
+}
+
+func validateSkillProficiency(violations *ValidationErrors, field string, skill UserSkill) {
+	if skill.Level != "" && !isSkillLevel(skill.Level) {
+		violations.add(field+".level", ValidationInvalid, fmt.Sprintf("%s.level must be one of %s", field, strings.Join(skillLevels, ", ")))
+	}
+	if skill.Years < 0 || skill.Years > maxSkillYears {
+		violations.add(field+".years", ValidationInvalid, fmt.Sprintf("%s.years must be between 0 and %d", field, maxSkillYears))
+	}
+}
+
+// validate checks the sort fields and the skill requirements of a search.
+func (s SearchUserFilter) validate() error {
+	if utf8.RuneCountInString(s.Query) > maxQueryLength {
+		return NewInvalidInputError(ErrorCodeInvalidRequest, fmt.Sprintf("search text must have at most %d characters", maxQueryLength), nil)
+	}
+	for _, v := range s.Sort {
+		if !isSortableField(v.Field) {
+			return NewInvalidInputError(ErrorCodeInvalidRequest, fmt.Sprintf("users cannot be sorted by %q, sort fields must be %s", v.Field, strings.Join(sortableFields, ", ")), nil)
+		}
+		if v.Field == repository.SortByRelevance && s.Query == "" {
+			return NewInvalidInputError(ErrorCodeInvalidRequest, "users can only be sorted by relevance when searching by text", nil)
+		}
+	}
+	for _, v := range s.Facets {
+		if !isFacetField(v) {
+			return NewInvalidInputError(ErrorCodeInvalidRequest, fmt.Sprintf("users cannot be counted by %q, facets must be %s", v, strings.Join(facetFields, ", ")), nil)
+		}
+	}
+	if s.TotalMode != "" && !isTotalMode(s.TotalMode) {
+		return NewInvalidInputError(ErrorCodeInvalidRequest, fmt.Sprintf("users cannot be counted %q, total must be %s", s.TotalMode, strings.Join(totalModes, ", ")), nil)
+	}
+	for _, requirement := range s.SkillRequirements {
+		if strings.TrimSpace(requirement.Name) == "" {
+			return NewInvalidInputError(ErrorCodeInvalidRequest, "skill of a skill requirement is required", nil)
+		}
+		if requirement.MinLevel != "" && !isSkillLevel(requirement.MinLevel) {
+			return NewInvalidInputError(ErrorCodeInvalidRequest, fmt.Sprintf("skill level must be one of %s", strings.Join(skillLevels, ", ")), nil)
+		}
+		if requirement.MinYears < 0 {
+			return NewInvalidInputError(ErrorCodeInvalidRequest, "years of experience must not be negative", nil)
+		}
+	}
+	return nil
+}
+
+func isTotalMode(mode string) bool {
+	for _, v := range totalModes {
+		if v == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func isFacetField(field string) bool {
+	for _, v := range facetFields {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isSortableField(field string) bool {
+	for _, v := range sortableFields {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package users_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fernandoocampo/users-micro/internal/adapter/repository"
+	"github.com/fernandoocampo/users-micro/internal/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateInvalidUser(t *testing.T) {
+	expectedError := users.ValidationErrors{
+		{Field: "first_name", Code: users.ValidationRequired, Message: "first_name is required"},
+		{Field: "last_name", Code: users.ValidationTooLong, Message: "last_name must have at most 100 characters"},
+		{Field: "skills[1]", Code: users.ValidationBlank, Message: "skills[1] must not be blank"},
+		{Field: "skills[2]", Code: users.ValidationDuplicate, Message: `skills[2] "Jack " is duplicated`},
+	}
+	givenUser := users.NewUser{
+		FirstName: "  ",
+		LastName:  strings.Repeat("a", 101),
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "jack"}, {Name: " "}, {Name: "Jack "}},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	newID, err := userService.Create(ctx, givenUser)
+
+	assert.Empty(t, newID)
+	assert.Equal(t, expectedError, err)
+	assert.Empty(t, userRepository.repo)
+}
+
+func TestCreateUserWithInvalidSkillProficiency(t *testing.T) {
+	expectedError := users.ValidationErrors{
+		{Field: "skills[0].level", Code: users.ValidationInvalid, Message: "skills[0].level must be one of beginner, intermediate, advanced, expert"},
+		{Field: "skills[1].years", Code: users.ValidationInvalid, Message: "skills[1].years must be between 0 and 80"},
+	}
+	givenUser := users.NewUser{
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		City:      "Cali",
+		Skills: users.UserSkills{
+			{Name: "Go", Level: "guru", Years: 3},
+			{Name: "PostgreSQL", Level: users.SkillAdvanced, Years: -1},
+		},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.Create(ctx, givenUser)
+
+	assert.Equal(t, expectedError, err)
+	assert.Empty(t, userRepository.repo)
+}
+
+func TestUpdateUserWithoutID(t *testing.T) {
+	expectedError := users.ValidationErrors{
+		{Field: "id", Code: users.ValidationRequired, Message: "id is required"},
+	}
+	givenUser := users.UpdateUser{
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		City:      "Cali",
+		Skills:    users.UserSkills{{Name: "jack"}},
+	}
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.Update(ctx, givenUser)
+
+	assert.Equal(t, expectedError, err)
+	assert.Empty(t, userRepository.repo)
+}
+
+func TestPatchUserLeavingItInvalid(t *testing.T) {
+	userRepository := userRepoMock{
+		repo: make(map[string]repository.User),
+	}
+	userRepository.repo["1234"] = repository.User{
+		ID:        "1234",
+		City:      "Cali",
+		FirstName: "Alicia",
+		LastName:  "Mendez",
+		Version:   1,
+	}
+	givenPatch := users.PatchUser{
+		ID:      "1234",
+		Version: 1,
+		Operations: []users.PatchOperation{
+			{Op: users.PatchRemove, Path: "/first_name"},
+		},
+	}
+	userService := users.NewService(&userRepository)
+	ctx := context.TODO()
+
+	_, err := userService.Patch(ctx, givenPatch)
+
+	assert.IsType(t, users.ValidationErrors{}, err)
+	assert.Empty(t, userRepository.patches)
+}